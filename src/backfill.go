@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"solana/src/config"
+	"solana/src/monitor"
+	"solana/src/utils"
+)
+
+// runBackfill 重新索引指定的歷史 slot 範圍，可與實時監控同時運行
+func runBackfill(cfg *config.Config, logger *utils.Logger, args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fromSlot := flags.Uint64("from-slot", 0, "First slot to backfill")
+	toSlot := flags.Uint64("to-slot", 0, "Last slot to backfill (inclusive)")
+	workers := flags.Int("workers", 0, "Number of concurrent workers")
+	rate := flags.Float64("rate", 0, "RPC requests per second for the backfill")
+	checkpoint := flags.String("checkpoint", "", "Path to the backfill checkpoint file")
+	flags.Parse(args)
+
+	if *toSlot == 0 || *fromSlot > *toSlot {
+		logger.Error("Invalid slot range: --from-slot %d --to-slot %d", *fromSlot, *toSlot)
+		os.Exit(1)
+	}
+
+	backfillConfig := config.NewBackfillConfig(*fromSlot, *toSlot)
+	if *workers > 0 {
+		backfillConfig.WorkerCount = *workers
+	}
+	if *rate > 0 {
+		backfillConfig.RequestsPerSecond = *rate
+	}
+	backfillConfig.CheckpointPath = *checkpoint
+
+	kafkaConfig, err := config.LoadKafkaConfigFromEnv()
+	if err != nil {
+		logger.Error("Invalid Kafka config: %v", err)
+		os.Exit(1)
+	}
+
+	// 使用獨立的事務 ID，避免與實時監控互相隔離
+	if kafkaConfig.TransactionalID != "" {
+		kafkaConfig.TransactionalID += "-backfill"
+	}
+
+	// 本地緩存目錄由實時監控使用
+	sinkConfig := *cfg.Sink
+	sinkConfig.SpoolDir = ""
+
+	sink, err := newSink(logger, &sinkConfig, kafkaConfig)
+	if err != nil {
+		logger.Error("Failed to create sink: %v", err)
+		os.Exit(1)
+	}
+	defer sink.Close()
+
+	backfiller := monitor.NewBackfiller(cfg, backfillConfig, sink)
+
+	// 收到信號時保存進度後退出，下次運行從進度繼續
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		logger.Info("Received signal %v, saving backfill progress...", sig)
+		backfiller.Stop()
+	}()
+
+	logger.Info("Backfill started for slots %d-%d", *fromSlot, *toSlot)
+	err = backfiller.Run()
+	backfiller.Stop()
+	if err == monitor.ErrBackfillStopped {
+		logger.Info("Backfill stopped, rerun to resume")
+		return
+	}
+	if err != nil {
+		logger.Error("Backfill failed: %v", err)
+		os.Exit(1)
+	}
+	logger.Info("Backfill complete")
+}
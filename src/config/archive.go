@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 歸檔文件的時間粒度
+const (
+	ArchivePeriodDay  = "day"
+	ArchivePeriodHour = "hour"
+)
+
+type ArchiveConfig struct {
+	Dir    string        // 歸檔目錄，包含 Parquet 文件、暫存數據和清單
+	Period string        // 每個文件覆蓋的時間範圍
+	Grace  time.Duration // 時段結束後等待遲到區塊的時間，之後生成 Parquet 文件
+	S3     *S3Config     // 上傳目標，Endpoint 為空時只保存在本地
+}
+
+func NewArchiveConfig() *ArchiveConfig {
+	return &ArchiveConfig{
+		Dir:    "archive",
+		Period: ArchivePeriodDay,
+		Grace:  10 * time.Minute,
+		S3:     NewS3Config(),
+	}
+}
+
+// PeriodLength 每個時段的長度
+func (c *ArchiveConfig) PeriodLength() time.Duration {
+	if c.Period == ArchivePeriodHour {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+func (c *ArchiveConfig) Validate() error {
+	switch c.Period {
+	case ArchivePeriodDay, ArchivePeriodHour:
+	default:
+		return fmt.Errorf("unknown archive period %q", c.Period)
+	}
+	if c.S3.Endpoint != "" && c.S3.Bucket == "" {
+		return fmt.Errorf("archive upload requires a bucket")
+	}
+	return nil
+}
+
+// S3Config S3 兼容的對象存儲，例如 MinIO，使用路徑風格的地址
+type S3Config struct {
+	Endpoint  string // 例如 http://localhost:9000
+	Bucket    string
+	Prefix    string // 對象 key 的前綴
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+func NewS3Config() *S3Config {
+	return &S3Config{
+		Region: "us-east-1",
+	}
+}
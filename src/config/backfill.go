@@ -0,0 +1,28 @@
+package config
+
+import (
+	"time"
+)
+
+type BackfillConfig struct {
+	FromSlot          uint64
+	ToSlot            uint64
+	WorkerCount       int
+	BatchSize         int
+	RequestsPerSecond float64 // 獨立的 RPC 額度，避免影響實時監控
+	RangeSize         uint64  // 每次 getBlocks 列舉的 slot 數量
+	CheckpointPath    string
+	ProgressInterval  time.Duration
+}
+
+func NewBackfillConfig(fromSlot, toSlot uint64) *BackfillConfig {
+	return &BackfillConfig{
+		FromSlot:          fromSlot,
+		ToSlot:            toSlot,
+		WorkerCount:       4,
+		BatchSize:         200,
+		RequestsPerSecond: 4,
+		RangeSize:         1000,
+		ProgressInterval:  time.Second * 10,
+	}
+}
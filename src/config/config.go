@@ -4,23 +4,53 @@ import (
 	"time"
 )
 
+// 區塊來源模式
+const (
+	IngestModePoll      = "poll"
+	IngestModeWebSocket = "websocket"
+)
+
 type Config struct {
-	RPCURL         string
-	KafkaBrokers   []string
-	KafkaTopic     string
-	WorkerCount    int
-	BatchSize      int
-	MaxRetries     int
-	RetryInterval  time.Duration
-	MetricsEnabled bool
+	RPCURL                string
+	KafkaBrokers          []string
+	KafkaTopic            string
+	WorkerCount           int
+	BatchSize             int
+	MaxRetries            int
+	RetryInterval         time.Duration
+	MetricsEnabled        bool
+	IngestMode            string
+	FinalityCheckInterval time.Duration
+	ChainDepth            int
+	CheckpointPath        string
+	CheckpointInterval    time.Duration
+	SlotWindowSize        uint64
+	DeadLetterPath        string
+	DeadLetterAfter       int
+	AdminAddr             string
+	RPC                   *RPCConfig
+	WebSocket             *WebSocketConfig
+	Sink                  *SinkConfig
 }
 
 func NewConfig() *Config {
 	return &Config{
-		WorkerCount:    5,                // 並發處理區塊的 worker 數量
-		BatchSize:      100,              // 批量處理的大小
-		MaxRetries:     5,                // 最大重試次數
-		RetryInterval:  time.Second * 30, // 重試間隔
-		MetricsEnabled: true,             // 是否啟用 metrics
+		WorkerCount:           5,                    // 並發處理區塊的 worker 數量
+		BatchSize:             100,                  // 批量處理的大小
+		MaxRetries:            5,                    // 最大重試次數
+		RetryInterval:         time.Second * 30,     // 重試間隔
+		MetricsEnabled:        true,                 // 是否啟用 metrics
+		IngestMode:            IngestModePoll,       // 區塊來源模式
+		FinalityCheckInterval: time.Second * 10,     // 非 finalized 模式下重新校驗的間隔
+		ChainDepth:            512,                  // 保留用於檢測重組的最近區塊數量
+		CheckpointPath:        "checkpoint.json",    // 進度文件路徑，為空時不保存
+		CheckpointInterval:    time.Second * 5,      // 進度保存間隔
+		SlotWindowSize:        1 << 17,              // 已處理與空槽記錄保留的 slot 範圍（約 14 小時）
+		DeadLetterPath:        "dead_letters.jsonl", // 死信文件路徑
+		DeadLetterAfter:       20,                   // 失敗的 slot 重試多少次後寫入死信
+		AdminAddr:             "127.0.0.1:9100",     // 管理接口監聽地址，為空時不啟動
+		RPC:                   NewRPCConfig(nil),
+		WebSocket:             NewWebSocketConfig(""),
+		Sink:                  NewSinkConfig(),
 	}
 }
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadFromEnv 從環境變量讀取配置，未設置的項目使用默認值
+func LoadFromEnv() (*Config, error) {
+	// RPC_URLS 可用逗號分隔多個端點
+	rpcURLs := SplitList(os.Getenv("RPC_URLS"))
+	if len(rpcURLs) == 0 {
+		rpcURLs = SplitList(os.Getenv("RPC_URL"))
+	}
+	if len(rpcURLs) == 0 {
+		return nil, fmt.Errorf("RPC_URL or RPC_URLS not set in config file")
+	}
+
+	cfg := NewConfig()
+	cfg.RPC = NewRPCConfig(rpcURLs)
+
+	if err := envFloat("RPC_RATE_LIMIT", &cfg.RPC.RateLimit.RequestsPerSecond); err != nil {
+		return nil, err
+	}
+	methodLimits, err := ParseMethodLimits(os.Getenv("RPC_METHOD_LIMITS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RPC_METHOD_LIMITS: %v", err)
+	}
+	cfg.RPC.RateLimit.MethodLimits = methodLimits
+	if err := cfg.RPC.RateLimit.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rate limit config: %v", err)
+	}
+
+	if err := envInt("WORKER_COUNT", &cfg.WorkerCount); err != nil {
+		return nil, err
+	}
+	if err := envInt("BATCH_SIZE", &cfg.BatchSize); err != nil {
+		return nil, err
+	}
+
+	if path, ok := os.LookupEnv("CHECKPOINT_PATH"); ok {
+		cfg.CheckpointPath = path
+	}
+
+	if path := os.Getenv("DEAD_LETTER_PATH"); path != "" {
+		cfg.DeadLetterPath = path
+	}
+
+	if addr, ok := os.LookupEnv("ADMIN_ADDR"); ok {
+		cfg.AdminAddr = addr
+	}
+
+	if commitment := os.Getenv("COMMITMENT"); commitment != "" {
+		if !ValidCommitment(commitment) {
+			return nil, fmt.Errorf("invalid COMMITMENT: %s", commitment)
+		}
+		cfg.RPC.Commitment = commitment
+	}
+
+	if mode := os.Getenv("INGEST_MODE"); mode != "" {
+		if mode != IngestModePoll && mode != IngestModeWebSocket {
+			return nil, fmt.Errorf("invalid INGEST_MODE: %s", mode)
+		}
+		cfg.IngestMode = mode
+	}
+	if cfg.IngestMode == IngestModeWebSocket {
+		cfg.WebSocket.URL = os.Getenv("WS_URL")
+		if cfg.WebSocket.URL == "" {
+			return nil, fmt.Errorf("WS_URL not set in config file")
+		}
+		cfg.WebSocket.SubscribeBlocks = os.Getenv("WS_BLOCK_SUBSCRIBE") == "true"
+		cfg.WebSocket.Commitment = cfg.RPC.BlockCommitment()
+	}
+
+	// SINKS 可用逗號分隔多個輸出目標
+	if sinks := SplitList(os.Getenv("SINKS")); len(sinks) > 0 {
+		cfg.Sink.Types = sinks
+	}
+	cfg.Sink.PostgresDSN = os.Getenv("POSTGRES_DSN")
+	cfg.Sink.ClickHouseDSN = os.Getenv("CLICKHOUSE_DSN")
+	if err := loadArchiveConfig(cfg.Sink.Archive); err != nil {
+		return nil, err
+	}
+	if err := cfg.Sink.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid SINKS: %v", err)
+	}
+	if dir := os.Getenv("SINK_FILE_DIR"); dir != "" {
+		cfg.Sink.FileDir = dir
+	}
+	maxBytes := int(cfg.Sink.FileMaxBytes)
+	if err := envInt("SINK_FILE_MAX_BYTES", &maxBytes); err != nil {
+		return nil, err
+	}
+	cfg.Sink.FileMaxBytes = int64(maxBytes)
+
+	if dir, ok := os.LookupEnv("SPOOL_DIR"); ok {
+		cfg.Sink.SpoolDir = dir
+	}
+	quota := int(cfg.Sink.SpoolQuota)
+	if err := envInt("SPOOL_QUOTA_BYTES", &quota); err != nil {
+		return nil, err
+	}
+	cfg.Sink.SpoolQuota = int64(quota)
+
+	if err := envInt("POSTGRES_BATCH_SIZE", &cfg.Sink.PostgresBatchSize); err != nil {
+		return nil, err
+	}
+	if cfg.Sink.PostgresBatchSize <= 0 {
+		return nil, fmt.Errorf("POSTGRES_BATCH_SIZE must be positive")
+	}
+	flushMs := int(cfg.Sink.PostgresFlushInterval / time.Millisecond)
+	if err := envInt("POSTGRES_FLUSH_INTERVAL_MS", &flushMs); err != nil {
+		return nil, err
+	}
+	cfg.Sink.PostgresFlushInterval = time.Duration(flushMs) * time.Millisecond
+
+	if err := envInt("CLICKHOUSE_BATCH_SIZE", &cfg.Sink.ClickHouseBatchSize); err != nil {
+		return nil, err
+	}
+	if cfg.Sink.ClickHouseBatchSize <= 0 {
+		return nil, fmt.Errorf("CLICKHOUSE_BATCH_SIZE must be positive")
+	}
+	flushMs = int(cfg.Sink.ClickHouseFlushInterval / time.Millisecond)
+	if err := envInt("CLICKHOUSE_FLUSH_INTERVAL_MS", &flushMs); err != nil {
+		return nil, err
+	}
+	cfg.Sink.ClickHouseFlushInterval = time.Duration(flushMs) * time.Millisecond
+
+	return cfg, nil
+}
+
+// loadArchiveConfig 讀取歸檔目錄、時段和可選的 S3 上傳目標
+func loadArchiveConfig(cfg *ArchiveConfig) error {
+	if dir := os.Getenv("ARCHIVE_DIR"); dir != "" {
+		cfg.Dir = dir
+	}
+	if period := os.Getenv("ARCHIVE_PERIOD"); period != "" {
+		cfg.Period = period
+	}
+	graceSeconds := int(cfg.Grace / time.Second)
+	if err := envInt("ARCHIVE_GRACE_SECONDS", &graceSeconds); err != nil {
+		return err
+	}
+	cfg.Grace = time.Duration(graceSeconds) * time.Second
+
+	cfg.S3.Endpoint = os.Getenv("ARCHIVE_S3_ENDPOINT")
+	cfg.S3.Bucket = os.Getenv("ARCHIVE_S3_BUCKET")
+	cfg.S3.Prefix = os.Getenv("ARCHIVE_S3_PREFIX")
+	if region := os.Getenv("ARCHIVE_S3_REGION"); region != "" {
+		cfg.S3.Region = region
+	}
+	cfg.S3.AccessKey = os.Getenv("ARCHIVE_S3_ACCESS_KEY")
+	cfg.S3.SecretKey = os.Getenv("ARCHIVE_S3_SECRET_KEY")
+	return nil
+}
+
+// LoadKafkaConfigFromEnv 從環境變量讀取 Kafka 發送配置
+func LoadKafkaConfigFromEnv() (*KafkaConfig, error) {
+	cfg := NewKafkaConfig()
+	if value := os.Getenv("KAFKA_ASYNC"); value != "" {
+		cfg.Async = value == "true"
+	}
+	if value := os.Getenv("KAFKA_IDEMPOTENT"); value != "" {
+		cfg.Idempotent = value == "true"
+	}
+	if err := envInt("KAFKA_BATCH_SIZE", &cfg.BatchSize); err != nil {
+		return nil, err
+	}
+
+	lingerMs := int(cfg.Linger / time.Millisecond)
+	if err := envInt("KAFKA_LINGER_MS", &lingerMs); err != nil {
+		return nil, err
+	}
+	cfg.Linger = time.Duration(lingerMs) * time.Millisecond
+
+	cfg.TransactionalID = os.Getenv("KAFKA_TRANSACTIONAL_ID")
+	if cfg.TransactionalID != "" && cfg.Async {
+		return nil, fmt.Errorf("KAFKA_TRANSACTIONAL_ID cannot be used with KAFKA_ASYNC")
+	}
+
+	if value := os.Getenv("KAFKA_PUBLISH_MODE"); value != "" {
+		switch value {
+		case PublishBlocks, PublishTransactions, PublishBoth:
+			cfg.PublishMode = value
+		default:
+			return nil, fmt.Errorf("invalid KAFKA_PUBLISH_MODE %q (expected %s, %s or %s)", value, PublishBlocks, PublishTransactions, PublishBoth)
+		}
+	}
+
+	if value := os.Getenv("KAFKA_SERIALIZATION"); value != "" {
+		switch value {
+		case SerializationJSON, SerializationProtobuf:
+			cfg.Serialization = value
+		default:
+			return nil, fmt.Errorf("invalid KAFKA_SERIALIZATION %q (expected %s or %s)", value, SerializationJSON, SerializationProtobuf)
+		}
+	}
+	cfg.SchemaRegistryURL = os.Getenv("SCHEMA_REGISTRY_URL")
+
+	if value := os.Getenv("KAFKA_COMPRESSION"); value != "" {
+		if err := cfg.Compression.UnmarshalText([]byte(value)); err != nil {
+			return nil, fmt.Errorf("invalid KAFKA_COMPRESSION: %v", err)
+		}
+	}
+	if err := envInt("KAFKA_CHUNK_BYTES", &cfg.ChunkBytes); err != nil {
+		return nil, err
+	}
+	if cfg.ChunkBytes > cfg.MaxMessageBytes-chunkHeadroom {
+		return nil, fmt.Errorf("KAFKA_CHUNK_BYTES must be at most %d", cfg.MaxMessageBytes-chunkHeadroom)
+	}
+
+	if value := os.Getenv("KAFKA_ROUTES"); value != "" {
+		routes, err := ParseRoutes(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KAFKA_ROUTES: %v", err)
+		}
+		cfg.Routes = routes
+	}
+
+	return cfg, nil
+}
+
+// ParseRoutes 解析 "programId:topic,programId:topic" 格式的路由表，同一程序可以出現多次
+func ParseRoutes(value string) (map[string][]string, error) {
+	routes := make(map[string][]string)
+	for _, item := range SplitList(value) {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("route %q must be programId:topic", item)
+		}
+		program, topic := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if program == "" || topic == "" {
+			return nil, fmt.Errorf("route %q must be programId:topic", item)
+		}
+		routes[program] = append(routes[program], topic)
+	}
+	return routes, nil
+}
+
+// SplitList 拆分逗號分隔的列表並去除空白項
+func SplitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envInt(name string, target *int) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func envFloat(name string, target *float64) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = parsed
+	return nil
+}
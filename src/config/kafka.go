@@ -6,13 +6,38 @@ import (
 	"github.com/IBM/sarama"
 )
 
+// 區塊的發送方式
+const (
+	PublishBlocks       = "block"       // 整個區塊作為一條消息
+	PublishTransactions = "transaction" // 每筆交易作為一條消息，以簽名作為 key
+	PublishBoth         = "both"
+)
+
+// 消息的序列化格式
+const (
+	SerializationJSON     = "json"
+	SerializationProtobuf = "protobuf"
+)
+
 type KafkaConfig struct {
-	ClientID        string
-	MaxMessageBytes int
-	Timeout         time.Duration
-	RequiredAcks    sarama.RequiredAcks
-	RetryMax        int
-	Version         sarama.KafkaVersion
+	ClientID          string
+	MaxMessageBytes   int
+	Timeout           time.Duration
+	RequiredAcks      sarama.RequiredAcks
+	RetryMax          int
+	Version           sarama.KafkaVersion
+	Async             bool                    // 異步批量發送，進度只在 Kafka 確認後推進
+	Idempotent        bool                    // 冪等發送，避免重試產生重複消息
+	BatchSize         int                     // 異步模式下每批最多的消息數量
+	BatchBytes        int                     // 異步模式下每批達到此大小即發送
+	Linger            time.Duration           // 異步模式下等待湊批的最長時間
+	TransactionalID   string                  // 設置後啟用事務模式，區塊與進度在同一事務中提交
+	PublishMode       string                  // 區塊消息、交易消息或兩者都發送
+	Routes            map[string][]string     // 程序 ID 到 topic 的路由，調用了該程序的交易額外發送到這些 topic
+	Serialization     string                  // 區塊、交易與事件消息的格式
+	Compression       sarama.CompressionCodec // 發送端壓縮，zstd 需要 Kafka 2.1 以上
+	ChunkBytes        int                     // 消息超過此大小時拆分發送，為 0 時按 MaxMessageBytes 計算
+	SchemaRegistryURL string                  // protobuf 格式下註冊 schema 的地址，為空時不帶 schema ID
 }
 
 func NewKafkaConfig() *KafkaConfig {
@@ -23,9 +48,38 @@ func NewKafkaConfig() *KafkaConfig {
 		RequiredAcks:    sarama.WaitForAll,
 		RetryMax:        5,
 		Version:         sarama.V3_3_1_0,
+		Async:           false,
+		Idempotent:      true,
+		BatchSize:       500,
+		BatchBytes:      16 * 1024 * 1024, // 16MB
+		Linger:          20 * time.Millisecond,
+		PublishMode:     PublishBlocks,
+		Serialization:   SerializationJSON,
+		Compression:     sarama.CompressionNone,
 	}
 }
 
+// PublishesBlocks 是否發送整個區塊的消息
+func (c *KafkaConfig) PublishesBlocks() bool {
+	return c.PublishMode != PublishTransactions
+}
+
+// PublishesTransactions 是否發送單筆交易的消息，配置了路由時交易也會發送到默認 topic
+func (c *KafkaConfig) PublishesTransactions() bool {
+	return c.PublishMode == PublishTransactions || c.PublishMode == PublishBoth || len(c.Routes) > 0
+}
+
+// chunkHeadroom 為 key 和頭部預留的空間
+const chunkHeadroom = 64 * 1024
+
+// ChunkLimit 單條消息內容的最大字節數
+func (c *KafkaConfig) ChunkLimit() int {
+	if c.ChunkBytes > 0 {
+		return c.ChunkBytes
+	}
+	return c.MaxMessageBytes - chunkHeadroom
+}
+
 func (c *KafkaConfig) ToSaramaConfig() *sarama.Config {
 	config := sarama.NewConfig()
 
@@ -35,14 +89,32 @@ func (c *KafkaConfig) ToSaramaConfig() *sarama.Config {
 	config.Producer.RequiredAcks = c.RequiredAcks
 	config.Producer.Retry.Max = c.RetryMax
 	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	config.Producer.Idempotent = c.Idempotent
+	config.Producer.Compression = c.Compression
 	config.Version = c.Version
 
-	// 網絡配置
+	// 事務要求冪等發送
+	if c.TransactionalID != "" {
+		config.Producer.Idempotent = true
+		config.Producer.Transaction.ID = c.TransactionalID
+	}
+
+	if c.Async {
+		config.Producer.Flush.Messages = c.BatchSize
+		config.Producer.Flush.Bytes = c.BatchBytes
+		config.Producer.Flush.Frequency = c.Linger
+	}
+
+	// 網絡配置，冪等發送要求每個連接只有一個在途請求
 	config.Net.MaxOpenRequests = 1
 	config.Net.DialTimeout = time.Second * 10
 	config.Net.ReadTimeout = time.Second * 10
 	config.Net.WriteTimeout = time.Second * 10
 
+	// 讀取進度時只讀取已提交的事務
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+
 	// metadata 配置
 	config.Metadata.Retry.Max = 1
 	config.Metadata.Retry.Backoff = time.Second * 1
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RateLimitConfig struct {
+	RequestsPerSecond float64            // 每個端點未單獨配置的方法共用的速率
+	Burst             int                // 令牌桶容量
+	MethodLimits      map[string]float64 // 按 RPC 方法單獨配置的速率
+	BackoffFactor     float64            // 被限流時的降速倍數
+	MinRateRatio      float64            // 自適應降速的下限（相對配置速率）
+	RecoveryPerSecond float64            // 每秒恢復的速率（相對配置速率）
+	DefaultRetryAfter time.Duration      // 服務端未提供 Retry-After 時的暫停時間
+}
+
+func NewRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		RequestsPerSecond: 10,
+		Burst:             10,
+		MethodLimits:      make(map[string]float64),
+		BackoffFactor:     0.5,
+		MinRateRatio:      0.1,
+		RecoveryPerSecond: 0.05,
+		DefaultRetryAfter: time.Second,
+	}
+}
+
+func (c *RateLimitConfig) Validate() error {
+	if c.BackoffFactor <= 0 || c.BackoffFactor > 1 {
+		return fmt.Errorf("backoff factor must be in (0, 1], got %v", c.BackoffFactor)
+	}
+	// 下限為 0 時速率可能被降到 0，令牌永遠無法補充
+	if c.MinRateRatio <= 0 || c.MinRateRatio > 1 {
+		return fmt.Errorf("min rate ratio must be in (0, 1], got %v", c.MinRateRatio)
+	}
+	return nil
+}
+
+// MethodRate 返回指定方法的速率，以及該方法是否有獨立的額度
+func (c *RateLimitConfig) MethodRate(method string) (float64, bool) {
+	if rate, ok := c.MethodLimits[method]; ok {
+		return rate, true
+	}
+	return c.RequestsPerSecond, false
+}
+
+// ParseMethodLimits 解析 "getBlock=5,getSlot=2" 格式的方法速率配置
+func ParseMethodLimits(spec string) (map[string]float64, error) {
+	limits := make(map[string]float64)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid method limit %q", item)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate for method %s: %v", parts[0], err)
+		}
+		limits[strings.TrimSpace(parts[0])] = rate
+	}
+	return limits, nil
+}
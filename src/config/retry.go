@@ -9,6 +9,7 @@ type RetryConfig struct {
 	InitialDelay  time.Duration
 	MaxDelay      time.Duration
 	BackoffFactor float64
+	Jitter        float64 // 重試延遲的隨機浮動比例
 }
 
 func NewRetryConfig() *RetryConfig {
@@ -17,6 +18,7 @@ func NewRetryConfig() *RetryConfig {
 		InitialDelay:  time.Second,
 		MaxDelay:      time.Second * 30,
 		BackoffFactor: 2.0,
+		Jitter:        0.2,
 	}
 }
 
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"time"
+)
+
+// 承諾級別
+const (
+	CommitmentProcessed = "processed"
+	CommitmentConfirmed = "confirmed"
+	CommitmentFinalized = "finalized"
+)
+
+type RPCConfig struct {
+	Endpoints        []string
+	RequestTimeout   time.Duration
+	ProbeInterval    time.Duration
+	MaxFailures      int
+	RecoveryCooldown time.Duration
+	MaxSlotLag       uint64
+	LatencyAlpha     float64
+	SlotLagPenalty   float64
+	RateLimit        *RateLimitConfig
+	Commitment       string
+	SkippedCacheSize uint64
+}
+
+func NewRPCConfig(endpoints []string) *RPCConfig {
+	return &RPCConfig{
+		Endpoints:        endpoints,
+		RequestTimeout:   time.Second * 30, // 單次請求超時
+		ProbeInterval:    time.Second * 10, // 健康探測間隔
+		MaxFailures:      3,                // 連續失敗多少次後標記為不健康
+		RecoveryCooldown: time.Second * 30, // 不健康端點至少隔多久才重新嘗試
+		MaxSlotLag:       50,               // 落後最新 slot 超過此數量視為不新鮮
+		LatencyAlpha:     0.2,              // 延遲與錯誤率的平滑係數
+		SlotLagPenalty:   20,               // 每落後一個 slot 增加的分數（毫秒）
+		RateLimit:        NewRateLimitConfig(),
+		Commitment:       CommitmentFinalized,
+		SkippedCacheSize: 1 << 17, // 空槽緩存保留的 slot 範圍
+	}
+}
+
+// BlockCommitment 獲取區塊時使用的承諾級別，getBlock 不支援 processed，退回 confirmed
+func (c *RPCConfig) BlockCommitment() string {
+	if c.Commitment == CommitmentProcessed {
+		return CommitmentConfirmed
+	}
+	return c.Commitment
+}
+
+// ValidCommitment 檢查承諾級別是否合法
+func ValidCommitment(commitment string) bool {
+	switch commitment {
+	case CommitmentProcessed, CommitmentConfirmed, CommitmentFinalized:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 輸出目標類型
+const (
+	SinkKafka      = "kafka"
+	SinkStdout     = "stdout"
+	SinkFile       = "file" // 按大小切換文件的 JSON Lines
+	SinkMemory     = "memory"
+	SinkPostgres   = "postgres"   // 規範化的區塊、交易與餘額變化表
+	SinkClickHouse = "clickhouse" // 按天分區的交易分析表
+	SinkArchive    = "archive"    // 按時段歸檔的 Parquet 文件
+)
+
+type SinkConfig struct {
+	Types        []string // 同時發佈到的所有目標
+	FileDir      string   // file 目標的輸出目錄
+	FilePrefix   string   // file 目標的文件名前綴
+	FileMaxBytes int64    // 單個文件的最大字節數，超過後切換到新文件
+	MemoryLimit  int      // memory 目標每種數據保留的最大條數
+	SpoolDir     string   // Kafka 不可用時的本地緩存目錄，為空時不緩存
+	SpoolQuota   int64    // 本地緩存的磁盤配額
+
+	PostgresDSN           string        // postgres 目標的連接字符串
+	PostgresBatchSize     int           // 累積多少個區塊後寫入一次
+	PostgresFlushInterval time.Duration // 未滿一批時的最長等待時間
+
+	ClickHouseDSN           string        // clickhouse 目標的連接字符串
+	ClickHouseBatchSize     int           // 累積多少筆交易後寫入一次
+	ClickHouseFlushInterval time.Duration // 未滿一批時的最長等待時間
+
+	Archive *ArchiveConfig
+}
+
+func NewSinkConfig() *SinkConfig {
+	return &SinkConfig{
+		Types:        []string{SinkKafka},
+		FileDir:      "output",
+		FilePrefix:   "solana",
+		FileMaxBytes: 256 * 1024 * 1024, // 256MB
+		MemoryLimit:  1000,
+		SpoolDir:     "spool",
+		SpoolQuota:   1024 * 1024 * 1024, // 1GB
+
+		PostgresBatchSize:     100,
+		PostgresFlushInterval: time.Second,
+
+		ClickHouseBatchSize:     10000,
+		ClickHouseFlushInterval: 5 * time.Second,
+
+		Archive: NewArchiveConfig(),
+	}
+}
+
+// Validate 檢查目標類型，同一類型不能重複
+func (c *SinkConfig) Validate() error {
+	if len(c.Types) == 0 {
+		return fmt.Errorf("no sink configured")
+	}
+
+	seen := make(map[string]bool, len(c.Types))
+	for _, sinkType := range c.Types {
+		switch sinkType {
+		case SinkKafka, SinkStdout, SinkFile, SinkMemory, SinkPostgres, SinkClickHouse, SinkArchive:
+		default:
+			return fmt.Errorf("unknown sink %q", sinkType)
+		}
+		if seen[sinkType] {
+			return fmt.Errorf("sink %q configured more than once", sinkType)
+		}
+		seen[sinkType] = true
+	}
+	if seen[SinkPostgres] && c.PostgresDSN == "" {
+		return fmt.Errorf("postgres sink requires a DSN")
+	}
+	if seen[SinkClickHouse] && c.ClickHouseDSN == "" {
+		return fmt.Errorf("clickhouse sink requires a DSN")
+	}
+	if seen[SinkArchive] {
+		return c.Archive.Validate()
+	}
+	return nil
+}
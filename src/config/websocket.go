@@ -0,0 +1,27 @@
+package config
+
+import (
+	"time"
+)
+
+type WebSocketConfig struct {
+	URL               string
+	SubscribeBlocks   bool          // 是否同時訂閱 blockSubscribe
+	Commitment        string        // blockSubscribe 使用的承諾級別
+	ReconnectDelay    time.Duration // 首次重連等待時間
+	MaxReconnectDelay time.Duration
+	HealthTimeout     time.Duration // 超過此時間沒有收到消息視為不健康
+	PingInterval      time.Duration
+}
+
+func NewWebSocketConfig(url string) *WebSocketConfig {
+	return &WebSocketConfig{
+		URL:               url,
+		SubscribeBlocks:   false,
+		Commitment:        CommitmentFinalized,
+		ReconnectDelay:    time.Second,
+		MaxReconnectDelay: time.Second * 30,
+		HealthTimeout:     time.Second * 10,
+		PingInterval:      time.Second * 15,
+	}
+}
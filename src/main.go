@@ -44,41 +44,43 @@ func main() {
 		os.Exit(1)
 	}
 
-	// 獲取配置值
-	rpcURL := os.Getenv("RPC_URL")
-	if rpcURL == "" {
-		logger.Error("RPC_URL not set in config file")
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		logger.Error("Invalid config: %v", err)
 		os.Exit(1)
 	}
 
-	// 測試連接
-	checker := utils.NewTCPConnectionChecker(5 * time.Second)
-	if err := checker.TestConnection("127.0.0.1", "8998"); err != nil {
-		logger.Error("Kafka connection test failed: %v", err)
-		// 在這裡可以選擇是否退出
-		// os.Exit(1)
-	} else {
-		logger.Info("Kafka connection test successful")
+	// 子命令
+	switch flag.Arg(0) {
+	case "backfill":
+		runBackfill(cfg, logger, flag.Args()[1:])
+		return
+	case "redrive":
+		runRedrive(cfg, logger, flag.Args()[1:])
+		return
+	case "registry":
+		runRegistry(cfg, logger, flag.Args()[1:])
+		return
+	case "archive":
+		runArchive(cfg, logger, flag.Args()[1:])
+		return
 	}
 
-	// 創建 Kafka 生產者
-	kafkaConfig := config.NewKafkaConfig()
-	producer, err := services.NewKafkaProducer(
-		kafkaConfig,
-		[]string{"127.0.0.1:8998"},
-	)
+	kafkaConfig, err := config.LoadKafkaConfigFromEnv()
 	if err != nil {
-		logger.Error("Failed to create Kafka producer: %v", err)
+		logger.Error("Invalid Kafka config: %v", err)
 		os.Exit(1)
 	}
 
-	// 設置 topic
-	producer.Topic = "solana"
-
-	defer producer.Close()
+	sink, err := newSink(logger, cfg.Sink, kafkaConfig)
+	if err != nil {
+		logger.Error("Failed to create sink: %v", err)
+		os.Exit(1)
+	}
+	defer sink.Close()
 
 	// 創建並啟動監視器
-	monitor := monitor.NewBlockMonitor(rpcURL, producer, "solana")
+	monitor := monitor.NewBlockMonitor(cfg, sink, "solana")
 	defer monitor.Stop()
 
 	// 處理系統信號
@@ -103,3 +105,105 @@ func main() {
 	monitor.Stop()
 	logger.Info("Shutdown complete")
 }
+
+// newSink 按配置創建輸出目標，配置了多個目標時同時發佈到所有目標
+func newSink(logger *utils.Logger, sinkConfig *config.SinkConfig, kafkaConfig *config.KafkaConfig) (services.Sink, error) {
+	// 事務只能保證 Kafka 內的一致性
+	if kafkaConfig.TransactionalID != "" && len(sinkConfig.Types) > 1 {
+		return nil, fmt.Errorf("KAFKA_TRANSACTIONAL_ID requires kafka to be the only sink")
+	}
+
+	sinks := make([]services.Sink, 0, len(sinkConfig.Types))
+	for _, sinkType := range sinkConfig.Types {
+		var sink services.Sink
+		var err error
+		switch sinkType {
+		case config.SinkKafka:
+			sink, err = newKafkaSink(logger, sinkConfig, kafkaConfig)
+		case config.SinkStdout:
+			sink = services.NewStdoutSink(os.Stdout)
+		case config.SinkFile:
+			sink, err = services.NewFileSink(sinkConfig.FileDir, sinkConfig.FilePrefix, sinkConfig.FileMaxBytes)
+		case config.SinkMemory:
+			sink = services.NewMemorySink(sinkConfig.MemoryLimit)
+		case config.SinkPostgres:
+			sink, err = services.NewPostgresSink(sinkConfig.PostgresDSN, sinkConfig.PostgresBatchSize, sinkConfig.PostgresFlushInterval)
+		case config.SinkClickHouse:
+			sink, err = services.NewClickHouseSink(sinkConfig.ClickHouseDSN, sinkConfig.ClickHouseBatchSize, sinkConfig.ClickHouseFlushInterval)
+		case config.SinkArchive:
+			sink, err = services.NewArchiveSink(sinkConfig.Archive)
+		}
+		if err != nil {
+			for _, created := range sinks {
+				created.Close()
+			}
+			return nil, fmt.Errorf("failed to create %s sink: %v", sinkType, err)
+		}
+		logger.Info("Publishing to %s sink", sinkType)
+		sinks = append(sinks, sink)
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return services.NewMultiSink(sinks...), nil
+}
+
+// newKafkaSink 配置了緩存目錄時，Kafka 不可用期間的數據先寫入本地緩存，恢復後重放
+func newKafkaSink(logger *utils.Logger, sinkConfig *config.SinkConfig, kafkaConfig *config.KafkaConfig) (services.Sink, error) {
+	connect := func() (services.Sink, error) {
+		return newProducer(logger, kafkaConfig)
+	}
+
+	// 事務模式的進度保存在 Kafka 中，不能先寫入本地緩存
+	if sinkConfig.SpoolDir == "" || kafkaConfig.TransactionalID != "" {
+		return connect()
+	}
+	return services.NewSpoolSink(sinkConfig.SpoolDir, sinkConfig.SpoolQuota, kafkaConfig.Async, connect)
+}
+
+// newProducer 測試 Kafka 連接並創建生產者
+func newProducer(logger *utils.Logger, kafkaConfig *config.KafkaConfig) (*services.KafkaProducer, error) {
+	// 測試連接
+	checker := utils.NewTCPConnectionChecker(5 * time.Second)
+	if err := checker.TestConnection("127.0.0.1", "8998"); err != nil {
+		return nil, fmt.Errorf("Kafka connection test failed: %v", err)
+	}
+	logger.Info("Kafka connection test successful")
+
+	producer, err := services.NewKafkaProducer(
+		kafkaConfig,
+		[]string{"127.0.0.1:8998"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// 設置 topic
+	producer.Topic = os.Getenv("KAFKA_TOPIC")
+	if producer.Topic == "" {
+		producer.Topic = "solana"
+	}
+	producer.EventTopic = os.Getenv("KAFKA_EVENT_TOPIC")
+	if producer.EventTopic == "" {
+		producer.EventTopic = "solana-events"
+	}
+	producer.TransactionTopic = os.Getenv("KAFKA_TRANSACTION_TOPIC")
+	if producer.TransactionTopic == "" {
+		producer.TransactionTopic = "solana-transactions"
+	}
+	producer.DeadLetterTopic = os.Getenv("KAFKA_DEAD_LETTER_TOPIC")
+	producer.CheckpointTopic = os.Getenv("KAFKA_CHECKPOINT_TOPIC")
+	if producer.CheckpointTopic == "" {
+		producer.CheckpointTopic = "solana-checkpoints"
+	}
+
+	// 事務模式的進度只保存在壓縮 topic 中
+	if producer.Transactional() {
+		if err := services.NewKafkaCheckpointStore(producer).EnsureTopic(); err != nil {
+			producer.Close()
+			return nil, err
+		}
+	}
+	return producer, nil
+}
@@ -10,10 +10,24 @@ type BlockResponse struct {
 		PreviousBlockhash string        `json:"previousBlockhash"`
 		Transactions      []Transaction `json:"transactions"`
 	} `json:"result"`
-	Id int `json:"id"`
+	Id         int    `json:"id"`
+	Slot       uint64 `json:"-"` // 由客戶端填入，RPC 響應本身不含 slot
+	Commitment string `json:"-"`
+}
+
+// BlockSummary 不含交易的區塊頭信息
+type BlockSummary struct {
+	Slot              uint64  `json:"-"`
+	BlockHeight       uint64  `json:"blockHeight"`
+	BlockTime         *uint64 `json:"blockTime"`
+	Blockhash         string  `json:"blockhash"`
+	ParentSlot        uint64  `json:"parentSlot"`
+	PreviousBlockhash string  `json:"previousBlockhash"`
 }
 
 type BlockMessage struct {
+	Slot              uint64            `json:"slot"`
+	Commitment        string            `json:"commitment"`
 	BlockHeight       uint64            `json:"blockHeight"`
 	BlockTime         *uint64           `json:"blockTime"`
 	Blockhash         string            `json:"blockhash"`
@@ -0,0 +1,11 @@
+package models
+
+// Checkpoint 監控器的持久化進度
+type Checkpoint struct {
+	LastContiguousSlot uint64   `json:"lastContiguousSlot"` // 此 slot 及之前的 slot 都已處理或記錄在下列列表中
+	PendingSlots       []uint64 `json:"pendingSlots"`
+	MissingSlots       []uint64 `json:"missingSlots"`
+	FromSlot           uint64   `json:"fromSlot,omitempty"` // 僅回填任務使用
+	ToSlot             uint64   `json:"toSlot,omitempty"`
+	UpdatedAt          int64    `json:"updatedAt"`
+}
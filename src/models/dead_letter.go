@@ -0,0 +1,29 @@
+package models
+
+// 死信原因
+const (
+	DeadLetterRetriesExhausted = "retries_exhausted" // 多輪重試後仍無法獲取或發送
+	DeadLetterNotAvailable     = "not_available"     // 區塊長時間處於不可用狀態
+	DeadLetterTooLarge         = "too_large"         // 消息超過 Kafka 允許的大小，重試無法成功
+	DeadLetterRejected         = "rejected"          // 數據被輸出目標拒絕，單獨寫入仍然失敗
+)
+
+// DeadLetterAttempt 單次獲取區塊的嘗試記錄
+type DeadLetterAttempt struct {
+	Attempt  int    `json:"attempt"`
+	Error    string `json:"error"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Time     int64  `json:"time"`
+}
+
+// DeadLetter 永久失敗的 slot，寫入死信文件與 topic，之後可重新投遞
+type DeadLetter struct {
+	Slot          uint64              `json:"slot"`
+	Reason        string              `json:"reason"`
+	ErrorChain    []string            `json:"errorChain"`
+	Attempts      []DeadLetterAttempt `json:"attempts"`
+	RawResponse   string              `json:"rawResponse,omitempty"` // 最後一次錯誤的原始 RPC 響應
+	FirstFailedAt int64               `json:"firstFailedAt"`
+	FailedAt      int64               `json:"failedAt"`
+	Redrives      int                 `json:"redrives,omitempty"`
+}
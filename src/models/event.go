@@ -0,0 +1,23 @@
+package models
+
+// 事件類型
+const (
+	EventFinalized  = "finalized"   // 已發送的區塊被確認為最終狀態
+	EventRolledBack = "rolled_back" // 已發送的區塊未能進入最終鏈
+	EventReorg      = "reorg"       // 新區塊的父鏈與已發送的區塊不一致
+	EventSkipped    = "skipped"     // 節點確認該 slot 沒有產出區塊
+)
+
+// SlotEvent 與某個 slot 相關的事件，發送到事件 topic
+type SlotEvent struct {
+	Type               string   `json:"type"`
+	Slot               uint64   `json:"slot"`
+	Blockhash          string   `json:"blockhash,omitempty"`
+	FinalizedBlockhash string   `json:"finalizedBlockhash,omitempty"`
+	ParentSlot         uint64   `json:"parentSlot,omitempty"`
+	PreviousBlockhash  string   `json:"previousBlockhash,omitempty"`
+	OrphanedSlots      []uint64 `json:"orphanedSlots,omitempty"`
+	Leader             string   `json:"leader,omitempty"` // 應出塊的節點，未知時為空
+	Commitment         string   `json:"commitment,omitempty"`
+	Timestamp          int64    `json:"timestamp"`
+}
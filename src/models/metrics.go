@@ -11,11 +11,13 @@ type Metrics struct {
 	totalFailed        uint64
 	totalRetried       uint64
 	totalMissed        uint64
+	totalDeadLettered  uint64
 	processingTime     time.Duration
 	lastProcessedSlot  uint64
 	processedPerSecond float64
 	getEmptySlots      func() int
 	getPendingSlots    func() int
+	sources            map[string]func() interface{}
 	mutex              sync.RWMutex
 }
 
@@ -24,6 +26,7 @@ type MetricsStats struct {
 	TotalFailed       uint64  `json:"total_failed"`
 	TotalRetried      uint64  `json:"total_retried"`
 	TotalMissed       uint64  `json:"total_missed"`
+	TotalDeadLettered uint64  `json:"total_dead_lettered"`
 	BlocksPerSecond   float64 `json:"blocks_per_second"`
 	AvgProcessingTime float64 `json:"avg_processing_time"`
 	SuccessRate       float64 `json:"success_rate"`
@@ -38,9 +41,17 @@ func NewMetrics(getEmptySlots, getPendingSlots func() int) *Metrics {
 		startTime:       time.Now(),
 		getEmptySlots:   getEmptySlots,
 		getPendingSlots: getPendingSlots,
+		sources:         make(map[string]func() interface{}),
 	}
 }
 
+// AddSource 註冊額外的統計來源，其結果會以 name 為鍵出現在 GetStats 中
+func (m *Metrics) AddSource(name string, source func() interface{}) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sources[name] = source
+}
+
 func (m *Metrics) UpdateMetrics(slot uint64, processTime time.Duration, isRetry bool) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -71,6 +82,13 @@ func (m *Metrics) RecordMissed() {
 	m.totalMissed++
 }
 
+// RecordDeadLetter 記錄寫入死信的 slot
+func (m *Metrics) RecordDeadLetter() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.totalDeadLettered++
+}
+
 // GetStats 獲取統計信息
 func (m *Metrics) GetStats() map[string]interface{} {
 	m.mutex.RLock()
@@ -84,11 +102,12 @@ func (m *Metrics) GetStats() map[string]interface{} {
 		successRate = float64(m.totalProcessed-m.totalFailed) / float64(m.totalProcessed) * 100
 	}
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"total_processed":     m.totalProcessed,
 		"total_failed":        m.totalFailed,
 		"total_retried":       m.totalRetried,
 		"total_missed":        m.totalMissed,
+		"total_dead_lettered": m.totalDeadLettered,
 		"blocks_per_second":   m.processedPerSecond,
 		"avg_processing_time": avgProcessingTime,
 		"success_rate":        successRate,
@@ -97,4 +116,10 @@ func (m *Metrics) GetStats() map[string]interface{} {
 		"empty_slots":         m.getEmptySlots(),
 		"pending_slots":       m.getPendingSlots(),
 	}
+
+	for name, source := range m.sources {
+		stats[name] = source()
+	}
+
+	return stats
 }
@@ -11,10 +11,11 @@ type Transaction struct {
 		InnerInstructions []struct {
 			Index        uint32 `json:"index"`
 			Instructions []struct {
-				Accounts    []uint64 `json:"accounts"`
-				Data        string   `json:"data"`
-				ProgramId   string   `json:"programId"`
-				StackHeight *uint32  `json:"stackHeight,omitempty"`
+				Accounts       []uint64 `json:"accounts"`
+				Data           string   `json:"data"`
+				ProgramId      string   `json:"programId"`
+				ProgramIdIndex uint8    `json:"programIdIndex"` // json 編碼下只有索引
+				StackHeight    *uint32  `json:"stackHeight,omitempty"`
 			} `json:"instructions"`
 		} `json:"innerInstructions"`
 		LogMessages []string `json:"logMessages"`
@@ -59,6 +60,7 @@ type TransactionInfo struct {
 	Fee            uint64          `json:"fee"`
 	AccountKeys    []string        `json:"accountKeys"`
 	Instructions   []Instruction   `json:"instructions"`
+	Programs       []string        `json:"programs"` // 調用過的所有程序，包括內部指令
 	BalanceChanges []BalanceChange `json:"balanceChanges"`
 	TokenBalances  []TokenBalance  `json:"tokenBalances"`
 	ComputeUnits   uint64          `json:"computeUnits"`
@@ -90,3 +92,15 @@ type TokenBalance struct {
 		UIAmountString string  `json:"uiAmountString"`
 	} `json:"uiTokenAmount"`
 }
+
+// TransactionMessage 單筆交易消息，以簽名作為 key 發送，附帶所在區塊的信息
+type TransactionMessage struct {
+	Slot        uint64  `json:"slot"`
+	BlockHeight uint64  `json:"blockHeight"`
+	BlockTime   *uint64 `json:"blockTime"`
+	Blockhash   string  `json:"blockhash"`
+	Index       int     `json:"index"` // 在區塊中的位置
+	Commitment  string  `json:"commitment"`
+	TransactionInfo
+	Timestamp int64 `json:"timestamp"`
+}
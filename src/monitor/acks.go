@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+// ackTracker 按提交順序跟蹤各 slot 的消息確認，只有某個 slot 及之前所有 slot 的消息
+// 都被 Kafka 確認後才調用 advance 推進進度。同步發送時消息在提交前已確認，進度立即推進
+type ackTracker struct {
+	order       []uint64       // 已提交、等待確認的 slot，按提交順序
+	outstanding map[uint64]int // 各 slot 尚未確認的消息數量
+	advance     func(slot uint64)
+	failed      func(slot uint64, err error)
+	mutex       sync.Mutex
+}
+
+func newAckTracker(advance func(slot uint64), failed func(slot uint64, err error)) *ackTracker {
+	return &ackTracker{
+		outstanding: make(map[uint64]int),
+		advance:     advance,
+		failed:      failed,
+	}
+}
+
+// Sent 實現 services.DeliveryTracker
+func (t *ackTracker) Sent(slot uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.outstanding[slot]++
+}
+
+// Delivered 實現 services.DeliveryTracker，發送失敗的 slot 先交給 failed 處理再計為已確認，
+// 保證進度推進時該 slot 已被記錄在重試隊列中
+func (t *ackTracker) Delivered(slot uint64, err error) {
+	if err != nil {
+		t.failed(slot, err)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.outstanding[slot] <= 1 {
+		delete(t.outstanding, slot)
+	} else {
+		t.outstanding[slot]--
+	}
+	t.drain()
+}
+
+// committed slot 的消息已全部發出，按提交順序調用
+func (t *ackTracker) committed(slot uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.order = append(t.order, slot)
+	t.drain()
+}
+
+// drain 調用前需持有 mutex
+func (t *ackTracker) drain() {
+	advanced := 0
+	for _, slot := range t.order {
+		if t.outstanding[slot] > 0 {
+			break
+		}
+		t.advance(slot)
+		advanced++
+	}
+	if advanced > 0 {
+		t.order = append(t.order[:0], t.order[advanced:]...)
+	}
+}
+
+// waiting 等待確認的 slot 數量
+func (t *ackTracker) waiting() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.order)
+}
+
+// wait 等待所有已提交的 slot 被確認，stop 關閉時返回 false
+func (t *ackTracker) wait(stop <-chan struct{}) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		t.mutex.Lock()
+		idle := len(t.order) == 0 && len(t.outstanding) == 0
+		t.mutex.Unlock()
+		if idle {
+			return true
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return false
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// startAdminServer 啟動管理接口，提供 metrics 與重試隊列的查詢
+func (bm *BlockMonitor) startAdminServer() {
+	if bm.config.AdminAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, bm.metrics.GetStats())
+	})
+	mux.HandleFunc("/retries", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, bm.scheduler.snapshot())
+	})
+
+	bm.adminServer = &http.Server{
+		Addr:    bm.config.AdminAddr,
+		Handler: mux,
+	}
+	go func() {
+		if err := bm.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Admin server error: %v", err)
+		}
+	}()
+	log.Printf("Admin server listening on %s", bm.config.AdminAddr)
+}
+
+func (bm *BlockMonitor) stopAdminServer() {
+	if bm.adminServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bm.adminServer.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down admin server: %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error writing admin response: %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"solana/src/config"
+	"solana/src/models"
+	"solana/src/services"
+)
+
+// ErrBackfillStopped 回填在完成前被停止，進度已保存，重新運行可繼續
+var ErrBackfillStopped = errors.New("backfill stopped")
+
+// Backfiller 重新索引指定的歷史 slot 範圍，使用獨立的 RPC 客戶端與額度
+type Backfiller struct {
+	config       *config.BackfillConfig
+	solanaClient *services.SolanaClient
+	sink         services.Sink
+	retryConfig  *config.RetryConfig
+	store        services.CheckpointStore
+	pipeline     *pipeline
+	leaders      *leaderCache
+	acks         *ackTracker
+	nextSlot     uint64          // 此 slot 之前的範圍都已處理
+	failedSlots  map[uint64]bool // 重試後仍失敗的 slot
+	blocks       uint64
+	skipped      uint64
+	inFlight     sync.WaitGroup
+	mutex        sync.Mutex
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	wg           sync.WaitGroup
+}
+
+func NewBackfiller(cfg *config.Config, backfillConfig *config.BackfillConfig, sink services.Sink) *Backfiller {
+	// 複製 RPC 配置，回填只讀取已最終確認的區塊，並使用自己的速率額度
+	rpcConfig := *cfg.RPC
+	rateLimit := *cfg.RPC.RateLimit
+	rateLimit.RequestsPerSecond = backfillConfig.RequestsPerSecond
+	rateLimit.MethodLimits = make(map[string]float64)
+	rpcConfig.RateLimit = &rateLimit
+	rpcConfig.Commitment = config.CommitmentFinalized
+
+	checkpointPath := backfillConfig.CheckpointPath
+	if checkpointPath == "" {
+		checkpointPath = fmt.Sprintf("backfill-%d-%d.json", backfillConfig.FromSlot, backfillConfig.ToSlot)
+	}
+
+	b := &Backfiller{
+		config:       backfillConfig,
+		solanaClient: services.NewSolanaClient(&rpcConfig),
+		sink:         sink,
+		retryConfig:  config.NewRetryConfig(),
+		store:        services.NewFileCheckpointStore(checkpointPath),
+		nextSlot:     backfillConfig.FromSlot,
+		failedSlots:  make(map[uint64]bool),
+		stopChan:     make(chan struct{}),
+	}
+	b.leaders = newLeaderCache(b.solanaClient)
+	b.acks = newAckTracker(b.advance, b.deliveryFailed)
+	if asyncSink, ok := sink.(services.AsyncSink); ok && asyncSink.Async() {
+		asyncSink.SetTracker(b.acks)
+	}
+	b.pipeline = newPipeline(backfillConfig.WorkerCount, backfillConfig.BatchSize, b.fetchSlot, b.commitSlot)
+	return b
+}
+
+// Run 處理整個範圍直到完成或被停止，被停止時返回 ErrBackfillStopped。
+// 重新運行時從保存的進度繼續並重試之前失敗的 slot
+func (b *Backfiller) Run() error {
+	err := b.run()
+	if err == errMonitorStopped {
+		b.mutex.Lock()
+		log.Printf("Backfill stopped before slot %d", b.nextSlot)
+		b.mutex.Unlock()
+		return ErrBackfillStopped
+	}
+	return err
+}
+
+func (b *Backfiller) run() error {
+	if b.config.FromSlot > b.config.ToSlot {
+		return fmt.Errorf("invalid slot range %d-%d", b.config.FromSlot, b.config.ToSlot)
+	}
+
+	retrySlots, err := b.restore()
+	if err != nil {
+		return err
+	}
+
+	b.pipeline.start()
+	b.startProgressReporter()
+
+	startSlot := b.nextSlot
+	startTime := time.Now()
+	log.Printf("Backfill %d-%d starting from slot %d (%d failed slots to retry)",
+		b.config.FromSlot, b.config.ToSlot, startSlot, len(retrySlots))
+
+	for _, slot := range retrySlots {
+		if !b.submit(slot, true) {
+			return errMonitorStopped
+		}
+	}
+
+	for rangeStart := startSlot; rangeStart <= b.config.ToSlot; rangeStart += b.config.RangeSize {
+		rangeEnd := rangeStart + b.config.RangeSize - 1
+		if rangeEnd > b.config.ToSlot {
+			rangeEnd = b.config.ToSlot
+		}
+
+		produced, err := b.getBlocks(rangeStart, rangeEnd)
+		if err != nil {
+			return err
+		}
+
+		for slot := rangeStart; slot <= rangeEnd; slot++ {
+			if !b.submit(slot, produced[slot]) {
+				return errMonitorStopped
+			}
+		}
+
+		// 防止 rangeStart 溢出
+		if rangeEnd == b.config.ToSlot {
+			break
+		}
+	}
+
+	// 等待所有在途的 slot 發布完成並被 Kafka 確認
+	done := make(chan struct{})
+	go func() {
+		b.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-b.stopChan:
+		return errMonitorStopped
+	}
+	if !b.acks.wait(b.stopChan) {
+		return errMonitorStopped
+	}
+
+	b.saveCheckpoint()
+	b.mutex.Lock()
+	failed := len(b.failedSlots)
+	b.mutex.Unlock()
+
+	log.Printf("Backfill %d-%d finished in %v: %d blocks, %d skipped, %d failed",
+		b.config.FromSlot, b.config.ToSlot, time.Since(startTime).Truncate(time.Second), b.blocks, b.skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d slots failed, rerun to retry them", failed)
+	}
+	return nil
+}
+
+func (b *Backfiller) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopChan)
+		b.wg.Wait()
+		b.pipeline.close()
+		b.saveCheckpoint()
+		b.solanaClient.Close()
+	})
+}
+
+// restore 讀取同一範圍的進度，返回需要重試的失敗 slot
+func (b *Backfiller) restore() ([]uint64, error) {
+	checkpoint, err := b.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == nil {
+		return nil, nil
+	}
+	if checkpoint.FromSlot != b.config.FromSlot || checkpoint.ToSlot != b.config.ToSlot {
+		return nil, fmt.Errorf("checkpoint is for range %d-%d", checkpoint.FromSlot, checkpoint.ToSlot)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.nextSlot = checkpoint.LastContiguousSlot + 1
+	for _, slot := range checkpoint.MissingSlots {
+		b.failedSlots[slot] = true
+	}
+	return checkpoint.MissingSlots, nil
+}
+
+// getBlocks 列舉範圍內已產出的區塊，失敗時持續重試直到成功或被停止
+func (b *Backfiller) getBlocks(startSlot, endSlot uint64) (map[uint64]bool, error) {
+	for attempt := 0; ; attempt++ {
+		blocks, err := b.solanaClient.GetBlocks(startSlot, endSlot)
+		if err == nil {
+			produced := make(map[uint64]bool, len(blocks))
+			for _, slot := range blocks {
+				produced[slot] = true
+			}
+			return produced, nil
+		}
+
+		log.Printf("Error getting blocks %d-%d: %v", startSlot, endSlot, err)
+		select {
+		case <-time.After(b.retryConfig.Backoff(attempt)):
+		case <-b.stopChan:
+			return nil, errMonitorStopped
+		}
+	}
+}
+
+func (b *Backfiller) submit(slot uint64, produced bool) bool {
+	b.inFlight.Add(1)
+	if !b.pipeline.submit(slot, produced) {
+		b.inFlight.Done()
+		return false
+	}
+	return true
+}
+
+func (b *Backfiller) fetchSlot(job slotJob) slotResult {
+	return fetchWithRetry(job, b.retryConfig, b.solanaClient.GetBlock)
+}
+
+func (b *Backfiller) commitSlot(result slotResult) {
+	defer b.inFlight.Done()
+	slot := result.job.slot
+	b.leaders.prefetch(slot)
+
+	var failure error
+	skipped := false
+	switch {
+	case !result.job.produced, errors.Is(result.err, services.ErrSlotSkipped):
+		skipped = true
+		event := newSkippedEvent(slot, b.leaders.leader(slot), config.CommitmentFinalized)
+		failure = b.sink.PublishEvent(event)
+	case result.err != nil:
+		failure = result.err
+	default:
+		failure = b.sink.PublishBlock(result.message)
+	}
+
+	b.mutex.Lock()
+	switch {
+	case failure != nil:
+		b.failedSlots[slot] = true
+		log.Printf("Backfill failed for slot %d: %v", slot, failure)
+	case skipped:
+		b.skipped++
+		delete(b.failedSlots, slot)
+	default:
+		b.blocks++
+		delete(b.failedSlots, slot)
+	}
+	b.mutex.Unlock()
+
+	b.acks.committed(slot)
+}
+
+// advance slot 的消息已被確認，推進進度
+func (b *Backfiller) advance(slot uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// 重試的失敗 slot 位於已處理範圍內，不影響進度
+	if slot >= b.nextSlot {
+		b.nextSlot = slot + 1
+	}
+}
+
+func (b *Backfiller) deliveryFailed(slot uint64, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failedSlots[slot] = true
+	log.Printf("Backfill delivery failed for slot %d: %v", slot, err)
+}
+
+func (b *Backfiller) snapshotCheckpoint() *models.Checkpoint {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	failed := make([]uint64, 0, len(b.failedSlots))
+	for slot := range b.failedSlots {
+		failed = append(failed, slot)
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i] < failed[j] })
+
+	return &models.Checkpoint{
+		LastContiguousSlot: b.nextSlot - 1,
+		MissingSlots:       failed,
+		FromSlot:           b.config.FromSlot,
+		ToSlot:             b.config.ToSlot,
+		UpdatedAt:          time.Now().Unix(),
+	}
+}
+
+func (b *Backfiller) saveCheckpoint() {
+	// 尚未處理任何 slot 時不寫入
+	b.mutex.Lock()
+	started := b.nextSlot > b.config.FromSlot
+	b.mutex.Unlock()
+	if !started {
+		return
+	}
+
+	checkpoint := b.snapshotCheckpoint()
+	if err := b.sink.Flush(); err != nil {
+		log.Printf("Error flushing sink, backfill checkpoint not saved: %v", err)
+		return
+	}
+	if err := b.store.Save(checkpoint); err != nil {
+		log.Printf("Error saving backfill checkpoint: %v", err)
+	}
+}
+
+// startProgressReporter 定期輸出進度與預計剩餘時間，並保存進度
+func (b *Backfiller) startProgressReporter() {
+	b.mutex.Lock()
+	startSlot := b.nextSlot
+	b.mutex.Unlock()
+	startTime := time.Now()
+	total := b.config.ToSlot - b.config.FromSlot + 1
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(b.config.ProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				b.mutex.Lock()
+				nextSlot, blocks, skipped, failed := b.nextSlot, b.blocks, b.skipped, len(b.failedSlots)
+				b.mutex.Unlock()
+
+				done := nextSlot - b.config.FromSlot
+				rate := float64(nextSlot-startSlot) / time.Since(startTime).Seconds()
+				eta := "unknown"
+				if rate > 0 {
+					eta = (time.Duration(float64(total-done)/rate) * time.Second).String()
+				}
+				log.Printf("Backfill progress: %d/%d slots (%.2f%%), %d blocks, %d skipped, %d failed, %.1f slots/s, ETA %s",
+					done, total, float64(done)/float64(total)*100, blocks, skipped, failed, rate, eta)
+
+				b.saveCheckpoint()
+			case <-b.stopChan:
+				return
+			}
+		}
+	}()
+}
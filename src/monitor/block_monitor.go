@@ -1,57 +1,137 @@
 package monitor
 
 import (
+	"errors"
+	"net/http"
 	"sync"
 
 	"solana/src/config"
 	"solana/src/models"
 	"solana/src/services"
+	"solana/src/utils"
 )
 
+var errMonitorStopped = errors.New("monitor stopped")
+
 type BlockMonitor struct {
-	rpcURL         string
-	producer       *services.KafkaProducer
-	topic          string
-	currentSlot    uint64
-	processedSlots map[uint64]bool
-	missingSlots   []uint64
-	metrics        *models.Metrics
-	retryConfig    *config.RetryConfig
-	stopChan       chan struct{}
-	wg             sync.WaitGroup
-	emptySlots     map[uint64]*SlotStatus // 記錄空槽
-	pendingSlots   map[uint64]*SlotStatus // 記錄未確認的區塊
-	pendingMutex   sync.RWMutex           // 保護 maps 的互斥鎖
-	solanaClient   *services.SolanaClient
+	config          *config.Config
+	sink            services.Sink
+	txnSink         services.TransactionalSink // 事務模式下區塊與進度在同一事務中寫入
+	topic           string
+	currentSlot     uint64
+	processedSlots  *utils.SlotWindow
+	metrics         *models.Metrics
+	retryConfig     *config.RetryConfig
+	stopChan        chan struct{}
+	stopOnce        sync.Once
+	wg              sync.WaitGroup
+	emptySlots      *utils.SlotWindow      // 記錄空槽
+	pendingSlots    map[uint64]*SlotStatus // 記錄未確認的區塊
+	pendingMutex    sync.RWMutex           // 保護 maps 的互斥鎖
+	solanaClient    *services.SolanaClient
+	wsClient        *services.WebSocketClient        // 僅在 websocket 模式下使用
+	streamedBlocks  map[uint64]*models.BlockResponse // blockSubscribe 推送的區塊
+	streamMutex     sync.Mutex
+	unfinalized     map[uint64]string // 等待最終確認的 slot 與其 blockhash
+	finalityMutex   sync.Mutex
+	chain           *blockChain // 最近發送區塊的鏈，用於檢測重組
+	leaders         *leaderCache
+	pipeline        *pipeline
+	committedSlot   uint64 // 管線已按順序處理到的 slot
+	checkpointStore services.CheckpointStore
+	failures        map[uint64]*slotFailure // 尚未成功的 slot 的失敗記錄
+	deadSlots       *utils.SlotWindow       // 已寫入死信的 slot
+	deadLetterStore services.DeadLetterStore
+	scheduler       *retryScheduler // 失敗 slot 的延遲重試隊列
+	acks            *ackTracker     // 只在 Kafka 確認後推進進度
+	adminServer     *http.Server
 }
 
-func NewBlockMonitor(rpcURL string, producer *services.KafkaProducer, topic string) *BlockMonitor {
+func NewBlockMonitor(cfg *config.Config, sink services.Sink, topic string) *BlockMonitor {
 	bm := &BlockMonitor{
-		rpcURL:         rpcURL,
-		producer:       producer,
+		config:         cfg,
+		sink:           sink,
 		topic:          topic,
-		processedSlots: make(map[uint64]bool),
-		missingSlots:   make([]uint64, 0),
-		emptySlots:     make(map[uint64]*SlotStatus),
+		processedSlots: utils.NewSlotWindow("processed_slots", cfg.SlotWindowSize),
+		emptySlots:     utils.NewSlotWindow("empty_slots", cfg.SlotWindowSize),
 		pendingSlots:   make(map[uint64]*SlotStatus),
 		retryConfig:    config.NewRetryConfig(),
 		stopChan:       make(chan struct{}),
-		solanaClient:   services.NewSolanaClient(rpcURL),
+		solanaClient:   services.NewSolanaClient(cfg.RPC),
+		streamedBlocks: make(map[uint64]*models.BlockResponse),
+		unfinalized:    make(map[uint64]string),
+		failures:       make(map[uint64]*slotFailure),
+		deadSlots:      utils.NewSlotWindow("dead_slots", cfg.SlotWindowSize),
+		chain:          newBlockChain(cfg.ChainDepth),
+	}
+	bm.leaders = newLeaderCache(bm.solanaClient)
+	// 事務模式下進度與區塊在同一事務中寫入 Kafka
+	if txnSink, ok := sink.(services.TransactionalSink); ok && txnSink.Transactional() {
+		bm.txnSink = txnSink
+		bm.checkpointStore = txnSink.CheckpointStore()
+	} else if cfg.CheckpointPath != "" {
+		bm.checkpointStore = services.NewFileCheckpointStore(cfg.CheckpointPath)
+	}
+	if cfg.DeadLetterPath != "" {
+		bm.deadLetterStore = services.NewFileDeadLetterStore(cfg.DeadLetterPath)
+	}
+	bm.acks = newAckTracker(bm.markCommitted, bm.deliveryFailed)
+	if asyncSink, ok := sink.(services.AsyncSink); ok && asyncSink.Async() {
+		asyncSink.SetTracker(bm.acks)
+	}
+	bm.pipeline = newPipeline(cfg.WorkerCount, cfg.BatchSize, bm.fetchSlot, bm.commitSlot)
+	bm.pipeline.retry = bm.retrySlot
+	bm.scheduler = newRetryScheduler(bm.retryConfig.Jitter, bm.pipeline.submitRetry)
+
+	if cfg.IngestMode == config.IngestModeWebSocket {
+		bm.wsClient = services.NewWebSocketClient(cfg.WebSocket)
 	}
 
 	// 初始化 metrics
 	bm.metrics = models.NewMetrics(
-		func() int {
-			bm.pendingMutex.RLock()
-			defer bm.pendingMutex.RUnlock()
-			return len(bm.emptySlots)
-		},
+		bm.emptySlots.Len,
 		func() int {
 			bm.pendingMutex.RLock()
 			defer bm.pendingMutex.RUnlock()
 			return len(bm.pendingSlots)
 		},
 	)
+	bm.metrics.AddSource("rpc_endpoints", func() interface{} {
+		return bm.solanaClient.EndpointStats()
+	})
+	bm.metrics.AddSource("rate_limits", func() interface{} {
+		return bm.solanaClient.RateLimitStats()
+	})
+	bm.metrics.AddSource("in_flight_slots", func() interface{} {
+		return bm.pipeline.inFlight()
+	})
+	bm.metrics.AddSource("slot_windows", func() interface{} {
+		return []utils.SlotWindowStats{
+			bm.processedSlots.Stats(),
+			bm.emptySlots.Stats(),
+			bm.deadSlots.Stats(),
+			bm.solanaClient.SkippedCacheStats(),
+		}
+	})
+	bm.metrics.AddSource("retry_queue", func() interface{} {
+		return bm.scheduler.stats()
+	})
+	bm.metrics.AddSource("awaiting_ack", func() interface{} {
+		return bm.acks.waiting()
+	})
+	bm.metrics.AddSource("chain_depth", func() interface{} {
+		return bm.chain.size()
+	})
+	if spool := services.FindSpool(sink); spool != nil {
+		bm.metrics.AddSource("spool", func() interface{} {
+			return spool.Stats()
+		})
+	}
+	if bm.wsClient != nil {
+		bm.metrics.AddSource("websocket", func() interface{} {
+			return bm.wsClient.Stats()
+		})
+	}
 
 	return bm
 }
@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+)
+
+// chainLink 已發送區塊在鏈上的位置
+type chainLink struct {
+	Slot              uint64
+	ParentSlot        uint64
+	Blockhash         string
+	PreviousBlockhash string
+}
+
+// blockChain 保存最近發送的區塊，用於校驗新區塊是否接在已發送的鏈上
+type blockChain struct {
+	links    map[uint64]chainLink
+	capacity int
+	mutex    sync.Mutex
+}
+
+func newBlockChain(capacity int) *blockChain {
+	return &blockChain{
+		links:    make(map[uint64]chainLink),
+		capacity: capacity,
+	}
+}
+
+// validate 返回與新區塊衝突的已發送 slot：父區塊 hash 不符時父區塊本身，
+// 以及父區塊與新區塊之間已發送但不在新區塊祖先鏈上的 slot。
+// parentMismatch 表示已發送的父區塊已被替換，需要重新獲取
+func (c *blockChain) validate(link chainLink) (orphaned []uint64, parentMismatch bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if parent, ok := c.links[link.ParentSlot]; ok && parent.Blockhash != link.PreviousBlockhash {
+		orphaned = append(orphaned, link.ParentSlot)
+		parentMismatch = true
+	}
+
+	for slot := range c.links {
+		if slot > link.ParentSlot && slot < link.Slot {
+			orphaned = append(orphaned, slot)
+		}
+	}
+
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i] < orphaned[j] })
+	return orphaned, parentMismatch
+}
+
+// record 記錄已發送的區塊並移除被孤立的 slot，父區塊可能已被重新發送，不在此移除
+func (c *blockChain) record(link chainLink, orphaned []uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, slot := range orphaned {
+		if slot != link.ParentSlot {
+			delete(c.links, slot)
+		}
+	}
+	c.links[link.Slot] = link
+
+	// 超過容量時淘汰最舊的記錄
+	for len(c.links) > c.capacity {
+		oldest := link.Slot
+		for slot := range c.links {
+			if slot < oldest {
+				oldest = slot
+			}
+		}
+		delete(c.links, oldest)
+	}
+}
+
+func (c *blockChain) remove(slot uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.links, slot)
+}
+
+func (c *blockChain) size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.links)
+}
@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"solana/src/models"
+)
+
+// restoreCheckpoint 從持久化的進度恢復，返回應開始處理的 slot
+func (bm *BlockMonitor) restoreCheckpoint() (uint64, bool) {
+	if bm.checkpointStore == nil {
+		return 0, false
+	}
+
+	checkpoint, err := bm.checkpointStore.Load()
+	if err != nil {
+		log.Printf("Error loading checkpoint: %v", err)
+		return 0, false
+	}
+	if checkpoint == nil {
+		return 0, false
+	}
+
+	// 未完成的 slot 交給重試隊列立即重試
+	for _, slot := range checkpoint.PendingSlots {
+		bm.handlePendingSlot(slot, "NOT_AVAILABLE")
+		bm.scheduler.schedule(slot, 0, 0, nil)
+	}
+	for _, slot := range checkpoint.MissingSlots {
+		bm.scheduler.schedule(slot, 0, 0, nil)
+	}
+
+	bm.pendingMutex.Lock()
+	bm.committedSlot = checkpoint.LastContiguousSlot
+	bm.pendingMutex.Unlock()
+
+	log.Printf("Restored checkpoint: last slot %d, %d pending, %d missing",
+		checkpoint.LastContiguousSlot, len(checkpoint.PendingSlots), len(checkpoint.MissingSlots))
+	return checkpoint.LastContiguousSlot + 1, true
+}
+
+// markCommitted 記錄管線已按順序處理到的 slot，並以此為錨點淘汰窗口之外的舊記錄
+func (bm *BlockMonitor) markCommitted(slot uint64) {
+	bm.pendingMutex.Lock()
+	if slot > bm.committedSlot {
+		bm.committedSlot = slot
+	}
+	committed := bm.committedSlot
+	bm.pendingMutex.Unlock()
+
+	if committed >= bm.config.SlotWindowSize {
+		base := committed - bm.config.SlotWindowSize + 1
+		bm.processedSlots.Advance(base)
+		bm.emptySlots.Advance(base)
+	}
+}
+
+func (bm *BlockMonitor) snapshotCheckpoint() *models.Checkpoint {
+	bm.pendingMutex.RLock()
+	defer bm.pendingMutex.RUnlock()
+
+	pending := make([]uint64, 0, len(bm.pendingSlots))
+	for slot := range bm.pendingSlots {
+		pending = append(pending, slot)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+
+	missing := bm.scheduler.slots()
+
+	return &models.Checkpoint{
+		LastContiguousSlot: bm.committedSlot,
+		PendingSlots:       pending,
+		MissingSlots:       missing,
+		UpdatedAt:          time.Now().Unix(),
+	}
+}
+
+// checkpointWith 返回假設 slot 已成功發送後的進度，用於與區塊在同一事務中提交
+func (bm *BlockMonitor) checkpointWith(slot uint64) *models.Checkpoint {
+	checkpoint := bm.snapshotCheckpoint()
+	if slot == checkpoint.LastContiguousSlot+1 {
+		checkpoint.LastContiguousSlot = slot
+	}
+	checkpoint.PendingSlots = removeSlot(checkpoint.PendingSlots, slot)
+	checkpoint.MissingSlots = removeSlot(checkpoint.MissingSlots, slot)
+	return checkpoint
+}
+
+func removeSlot(slots []uint64, slot uint64) []uint64 {
+	remaining := slots[:0]
+	for _, s := range slots {
+		if s != slot {
+			remaining = append(remaining, s)
+		}
+	}
+	return remaining
+}
+
+func (bm *BlockMonitor) saveCheckpoint() {
+	if bm.checkpointStore == nil {
+		return
+	}
+
+	// 尚未開始處理時不覆蓋已有的進度
+	checkpoint := bm.snapshotCheckpoint()
+	if checkpoint.LastContiguousSlot == 0 {
+		return
+	}
+	// 進度包含的數據需要先寫出
+	if err := bm.sink.Flush(); err != nil {
+		log.Printf("Error flushing sink, checkpoint not saved: %v", err)
+		return
+	}
+	if err := bm.checkpointStore.Save(checkpoint); err != nil {
+		log.Printf("Error saving checkpoint: %v", err)
+	}
+}
+
+func (bm *BlockMonitor) startCheckpointWriter() {
+	if bm.checkpointStore == nil {
+		return
+	}
+
+	bm.wg.Add(1)
+	go func() {
+		defer bm.wg.Done()
+		ticker := time.NewTicker(bm.config.CheckpointInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				bm.saveCheckpoint()
+			case <-bm.stopChan:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"errors"
+	"log"
+
+	"solana/src/models"
+	"solana/src/services"
+)
+
+// 每個 slot 最多保留的嘗試記錄數量
+const maxAttemptHistory = 50
+
+// slotFailure 尚未成功的 slot 的失敗記錄
+type slotFailure struct {
+	retries  int // 已安排的重試次數
+	attempts []models.DeadLetterAttempt
+}
+
+// recordAttempts 累積 slot 的失敗嘗試記錄
+func (bm *BlockMonitor) recordAttempts(slot uint64, attempts []models.DeadLetterAttempt) {
+	if len(attempts) == 0 {
+		return
+	}
+
+	bm.pendingMutex.Lock()
+	defer bm.pendingMutex.Unlock()
+
+	failure := bm.failure(slot)
+	failure.attempts = append(failure.attempts, attempts...)
+	if excess := len(failure.attempts) - maxAttemptHistory; excess > 0 {
+		failure.attempts = failure.attempts[excess:]
+	}
+}
+
+// retryLater 將失敗的 slot 交給重試隊列按退避時間重試，超過重試次數後寫入死信
+func (bm *BlockMonitor) retryLater(slot uint64, err error) {
+	bm.pendingMutex.Lock()
+	failure := bm.failure(slot)
+	failure.retries++
+	retries := failure.retries
+	bm.pendingMutex.Unlock()
+
+	// 消息過大時重試不會成功
+	if errors.Is(err, services.ErrMessageTooLarge) {
+		bm.deadLetter(slot, models.DeadLetterTooLarge, err)
+		return
+	}
+
+	if retries > bm.config.DeadLetterAfter {
+		reason := models.DeadLetterRetriesExhausted
+		if errors.Is(err, services.ErrBlockNotAvailable) || errors.Is(err, services.ErrLongTermStorage) {
+			reason = models.DeadLetterNotAvailable
+		}
+		bm.deadLetter(slot, reason, err)
+		return
+	}
+
+	delay := bm.retryConfig.Backoff(retries - 1)
+	// 被限流時至少等待服務端要求的時間
+	if retryAfter := services.RetryAfter(err); retryAfter > delay {
+		delay = retryAfter
+	}
+	bm.scheduler.schedule(slot, retries, delay, err)
+}
+
+// clearFailure slot 處理成功後清除失敗記錄並移出重試隊列
+func (bm *BlockMonitor) clearFailure(slot uint64) {
+	bm.pendingMutex.Lock()
+	delete(bm.failures, slot)
+	bm.pendingMutex.Unlock()
+	bm.scheduler.done(slot)
+	bm.deadSlots.Remove(slot)
+}
+
+// failure 調用前需持有 pendingMutex
+func (bm *BlockMonitor) failure(slot uint64) *slotFailure {
+	failure, ok := bm.failures[slot]
+	if !ok {
+		failure = &slotFailure{}
+		bm.failures[slot] = failure
+	}
+	return failure
+}
+
+// deadLetter 將 slot 移出待確認列表與重試隊列並寫入死信，寫入失敗時稍後再重試
+func (bm *BlockMonitor) deadLetter(slot uint64, reason string, err error) {
+	bm.pendingMutex.Lock()
+	delete(bm.pendingSlots, slot)
+	var attempts []models.DeadLetterAttempt
+	if failure, ok := bm.failures[slot]; ok {
+		attempts = failure.attempts
+	}
+	bm.pendingMutex.Unlock()
+
+	letter := services.NewDeadLetter(slot, reason, err, attempts)
+	if bm.deadLetterStore != nil {
+		if err := bm.deadLetterStore.Append(letter); err != nil {
+			log.Printf("Error writing dead letter for slot %d: %v", slot, err)
+			bm.scheduler.schedule(slot, 0, bm.retryConfig.MaxDelay, err)
+			return
+		}
+	}
+	if err := bm.sink.PublishDeadLetter(letter); err != nil {
+		log.Printf("Error sending dead letter for slot %d: %v", slot, err)
+		if bm.deadLetterStore == nil {
+			bm.scheduler.schedule(slot, 0, bm.retryConfig.MaxDelay, err)
+			return
+		}
+	}
+
+	bm.clearFailure(slot)
+	bm.deadSlots.Add(slot)
+	bm.metrics.RecordDeadLetter()
+	log.Printf("Slot %d moved to dead letter queue (%s): %v", slot, reason, err)
+}
@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"solana/src/config"
+	"solana/src/models"
+	"solana/src/services"
+)
+
+// trackFinality 記錄以非 finalized 承諾級別發送的區塊，等待之後重新校驗
+func (bm *BlockMonitor) trackFinality(slot uint64, blockhash string) {
+	if bm.config.RPC.BlockCommitment() == config.CommitmentFinalized {
+		return
+	}
+
+	bm.finalityMutex.Lock()
+	defer bm.finalityMutex.Unlock()
+	bm.unfinalized[slot] = blockhash
+}
+
+func (bm *BlockMonitor) startFinalityChecker() {
+	if bm.config.RPC.BlockCommitment() == config.CommitmentFinalized {
+		return
+	}
+
+	bm.wg.Add(1)
+	go func() {
+		defer bm.wg.Done()
+		ticker := time.NewTicker(bm.config.FinalityCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				bm.checkFinality()
+			case <-bm.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// checkFinality 以 finalized 重新校驗已進入最終確認範圍的區塊，發送確認或回滾事件
+func (bm *BlockMonitor) checkFinality() {
+	finalizedSlot, err := bm.solanaClient.GetSlotAt(config.CommitmentFinalized)
+	if err != nil {
+		log.Printf("Error getting finalized slot: %v", err)
+		return
+	}
+
+	bm.finalityMutex.Lock()
+	slots := make([]uint64, 0)
+	for slot := range bm.unfinalized {
+		if slot <= finalizedSlot {
+			slots = append(slots, slot)
+		}
+	}
+	bm.finalityMutex.Unlock()
+
+	if len(slots) == 0 {
+		return
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	blocks, err := bm.solanaClient.GetBlocksAt(slots[0], slots[len(slots)-1], config.CommitmentFinalized)
+	if err != nil {
+		log.Printf("Error getting finalized blocks: %v", err)
+		return
+	}
+	finalized := make(map[uint64]bool, len(blocks))
+	for _, slot := range blocks {
+		finalized[slot] = true
+	}
+
+	for _, slot := range slots {
+		bm.finalityMutex.Lock()
+		blockhash := bm.unfinalized[slot]
+		bm.finalityMutex.Unlock()
+
+		event := &models.SlotEvent{
+			Slot:       slot,
+			Blockhash:  blockhash,
+			Commitment: config.CommitmentFinalized,
+			Timestamp:  time.Now().Unix(),
+		}
+
+		if !finalized[slot] {
+			// 該 slot 在最終鏈上被跳過
+			event.Type = models.EventRolledBack
+		} else {
+			summary, err := bm.solanaClient.GetBlockSummary(slot, config.CommitmentFinalized)
+			if errors.Is(err, services.ErrSlotSkipped) {
+				event.Type = models.EventRolledBack
+			} else if err != nil {
+				log.Printf("Error verifying finality of slot %d: %v", slot, err)
+				continue
+			} else if summary.Blockhash == blockhash {
+				event.Type = models.EventFinalized
+			} else {
+				event.Type = models.EventRolledBack
+				event.FinalizedBlockhash = summary.Blockhash
+			}
+		}
+
+		if err := bm.sink.PublishEvent(event); err != nil {
+			log.Printf("Error sending %s event for slot %d: %v", event.Type, slot, err)
+			continue
+		}
+
+		bm.finalityMutex.Lock()
+		delete(bm.unfinalized, slot)
+		bm.finalityMutex.Unlock()
+
+		if event.Type == models.EventRolledBack {
+			log.Printf("Slot %d rolled back (blockhash %s, finalized %s)", slot, blockhash, event.FinalizedBlockhash)
+			if event.FinalizedBlockhash != "" {
+				bm.reprocessSlot(slot)
+			}
+		}
+	}
+}
+
+// reprocessSlot 清除已處理標記並交給重試隊列重新發送該 slot 的區塊。
+// 由 worker 獲取，不在提交路徑上同步請求，避免阻塞之後的 slot
+func (bm *BlockMonitor) reprocessSlot(slot uint64) {
+	bm.processedSlots.Remove(slot)
+	bm.scheduler.schedule(slot, 0, 0, nil)
+}
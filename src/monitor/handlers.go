@@ -1,23 +1,51 @@
 package monitor
 
 import (
+	"fmt"
 	"log"
 	"time"
+
+	"solana/src/models"
 )
 
-func (bm *BlockMonitor) handleEmptySlot(slot uint64) {
-	bm.pendingMutex.Lock()
-	defer bm.pendingMutex.Unlock()
+// handleEmptySlot 記錄空槽並發送跳過事件，事件發送失敗時不記錄，以便之後重試
+func (bm *BlockMonitor) handleEmptySlot(slot uint64) error {
+	if bm.isEmptySlot(slot) {
+		return nil
+	}
+
+	event := newSkippedEvent(slot, bm.leaders.leader(slot), bm.config.RPC.BlockCommitment())
+	if err := bm.sendSkipped(event); err != nil {
+		return fmt.Errorf("failed to send skipped event: %v", err)
+	}
+	bm.emptySlots.Add(slot)
 
-	bm.emptySlots[slot] = &SlotStatus{
-		Slot:      slot,
-		Status:    "EMPTY",
-		CheckTime: time.Now(),
+	log.Printf("Empty slot detected: %d (leader %s)", slot, event.Leader)
+	return nil
+}
+
+// sendSkipped 事務模式下跳過事件與包含該 slot 的進度在同一事務中寫入，與 sendBlock 相同
+func (bm *BlockMonitor) sendSkipped(event *models.SlotEvent) error {
+	if bm.txnSink == nil {
+		return bm.sink.PublishEvent(event)
 	}
+	return bm.txnSink.PublishEventWithCheckpoint(event, func() *models.Checkpoint {
+		return bm.checkpointWith(event.Slot)
+	})
+}
 
-	log.Printf("Empty slot detected: %d", slot)
+func newSkippedEvent(slot uint64, leader, commitment string) *models.SlotEvent {
+	return &models.SlotEvent{
+		Type:       models.EventSkipped,
+		Slot:       slot,
+		Leader:     leader,
+		Commitment: commitment,
+		Timestamp:  time.Now().Unix(),
+	}
 }
 
+// handlePendingSlot 記錄暫時不可用的 slot，重試由重試隊列負責，
+// 無法確認是否為空槽時不會當作空槽丟棄，重試次數用盡後寫入死信
 func (bm *BlockMonitor) handlePendingSlot(slot uint64, status string) {
 	bm.pendingMutex.Lock()
 	defer bm.pendingMutex.Unlock()
@@ -25,22 +53,12 @@ func (bm *BlockMonitor) handlePendingSlot(slot uint64, status string) {
 	if existing, exists := bm.pendingSlots[slot]; exists {
 		existing.RetryCount++
 		existing.CheckTime = time.Now()
-
-		if existing.RetryCount >= bm.retryConfig.MaxRetries {
-			bm.emptySlots[slot] = &SlotStatus{
-				Slot:      slot,
-				Status:    "EMPTY",
-				CheckTime: time.Now(),
-			}
-			delete(bm.pendingSlots, slot)
-			log.Printf("Slot %d marked as empty after %d retries", slot, bm.retryConfig.MaxRetries)
-		}
-	} else {
-		bm.pendingSlots[slot] = &SlotStatus{
-			Slot:       slot,
-			Status:     status,
-			CheckTime:  time.Now(),
-			RetryCount: 1,
-		}
+		return
+	}
+	bm.pendingSlots[slot] = &SlotStatus{
+		Slot:       slot,
+		Status:     status,
+		CheckTime:  time.Now(),
+		RetryCount: 1,
 	}
 }
@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"solana/src/services"
+)
+
+const (
+	leaderChunkSize     = 1000             // 每次 getSlotLeaders 查詢的 slot 數量
+	leaderMaxChunks     = 8                // 最多緩存的區段數量
+	leaderRetryInterval = 30 * time.Second // 查詢失敗的區段在此時間後才重新查詢
+	leaderWaitTimeout   = 2 * time.Second  // 區段仍在查詢時最多等待的時間
+)
+
+// leaderChunk 一個區段的出塊節點，ready 在查詢完成後關閉
+type leaderChunk struct {
+	leaders []string
+	failed  time.Time // 查詢失敗的時間，只在持有 mutex 時訪問
+	ready   chan struct{}
+}
+
+// leaderCache 按區段緩存 slot 的出塊節點。查詢在背景進行並預取下一個區段，
+// 提交路徑不需要等待 RPC；查詢失敗不長期緩存，一段時間後重新查詢
+type leaderCache struct {
+	client *services.SolanaClient
+	chunks map[uint64]*leaderChunk
+	mutex  sync.Mutex
+}
+
+func newLeaderCache(client *services.SolanaClient) *leaderCache {
+	return &leaderCache{
+		client: client,
+		chunks: make(map[uint64]*leaderChunk),
+	}
+}
+
+// leader 返回 slot 的出塊節點，未知或查詢未及時完成時返回空字符串
+func (c *leaderCache) leader(slot uint64) string {
+	start := slot - slot%leaderChunkSize
+	chunk := c.load(start)
+	c.load(start + leaderChunkSize)
+
+	select {
+	case <-chunk.ready:
+	case <-time.After(leaderWaitTimeout):
+		return ""
+	}
+	if offset := slot - start; offset < uint64(len(chunk.leaders)) {
+		return chunk.leaders[offset]
+	}
+	return ""
+}
+
+// prefetch 在背景查詢 slot 所在及下一個區段，之後的 leader 調用不需要等待
+func (c *leaderCache) prefetch(slot uint64) {
+	start := slot - slot%leaderChunkSize
+	c.load(start)
+	c.load(start + leaderChunkSize)
+}
+
+// load 返回區段的緩存，不存在或失敗已超過重試間隔時在背景重新查詢
+func (c *leaderCache) load(start uint64) *leaderChunk {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if chunk, ok := c.chunks[start]; ok {
+		if chunk.failed.IsZero() || time.Since(chunk.failed) < leaderRetryInterval {
+			return chunk
+		}
+	}
+
+	chunk := &leaderChunk{ready: make(chan struct{})}
+	c.store(start, chunk)
+	go c.fetch(start, chunk)
+	return chunk
+}
+
+func (c *leaderCache) fetch(start uint64, chunk *leaderChunk) {
+	defer close(chunk.ready)
+
+	leaders, err := c.client.GetSlotLeaders(start, leaderChunkSize)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err != nil {
+		log.Printf("Error getting slot leaders from %d: %v", start, err)
+		chunk.failed = time.Now()
+		return
+	}
+	chunk.leaders = leaders
+}
+
+// store 調用前需持有 mutex，超出上限時淘汰最舊的區段
+func (c *leaderCache) store(start uint64, chunk *leaderChunk) {
+	c.chunks[start] = chunk
+	if len(c.chunks) <= leaderMaxChunks {
+		return
+	}
+
+	starts := make([]uint64, 0, len(c.chunks))
+	for s := range c.chunks {
+		starts = append(starts, s)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for _, s := range starts[:len(starts)-leaderMaxChunks] {
+		delete(c.chunks, s)
+	}
+}
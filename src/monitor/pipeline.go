@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+
+	"solana/src/models"
+)
+
+// slotJob 提交給 worker 的 slot
+type slotJob struct {
+	seq        uint64
+	slot       uint64
+	produced   bool // getBlocks 確認該 slot 已產出區塊
+	attempt    int  // 重試次數，首次處理為 0
+	submitTime time.Time
+}
+
+// slotResult worker 的處理結果，由提交順序依次交給 commit
+type slotResult struct {
+	job      slotJob
+	block    *models.BlockResponse
+	message  *models.BlockMessage
+	retries  int
+	attempts []models.DeadLetterAttempt // 失敗的嘗試記錄
+	err      error
+}
+
+// pipeline 由多個 worker 並行獲取與轉換區塊，再經重排緩衝區按提交順序發布
+type pipeline struct {
+	workers int
+	jobs    chan slotJob
+	results chan slotResult
+	window  chan struct{} // 限制在途 slot 數量
+	process func(job slotJob) slotResult
+	commit  func(result slotResult)
+	retries chan slotJob      // 重試的 slot，不經過重排緩衝區
+	retry   func(job slotJob) // 處理重試的 slot，由 worker 調用
+	nextSeq uint64
+	mutex   sync.Mutex
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newPipeline(workers, window int, process func(job slotJob) slotResult, commit func(result slotResult)) *pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	if window < workers {
+		window = workers
+	}
+
+	return &pipeline{
+		workers: workers,
+		jobs:    make(chan slotJob, window),
+		results: make(chan slotResult, window),
+		window:  make(chan struct{}, window),
+		process: process,
+		commit:  commit,
+		retries: make(chan slotJob),
+		stop:    make(chan struct{}),
+	}
+}
+
+func (p *pipeline) start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case job := <-p.jobs:
+					result := p.process(job)
+					select {
+					case p.results <- result:
+					case <-p.stop:
+						return
+					}
+				case job := <-p.retries:
+					p.retry(job)
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.reorder()
+	}()
+}
+
+// reorder 緩存提前完成的結果，保證 commit 按提交順序調用
+func (p *pipeline) reorder() {
+	pending := make(map[uint64]slotResult)
+	var next uint64
+
+	for {
+		select {
+		case result := <-p.results:
+			pending[result.job.seq] = result
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				p.commit(ready)
+				next++
+				<-p.window
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// submit 按順序提交 slot，在途數量達到上限時阻塞，停止後返回 false
+func (p *pipeline) submit(slot uint64, produced bool) bool {
+	select {
+	case p.window <- struct{}{}:
+	case <-p.stop:
+		return false
+	}
+
+	p.mutex.Lock()
+	job := slotJob{
+		seq:        p.nextSeq,
+		slot:       slot,
+		produced:   produced,
+		submitTime: time.Now(),
+	}
+	p.nextSeq++
+	p.mutex.Unlock()
+
+	select {
+	case p.jobs <- job:
+		return true
+	case <-p.stop:
+		return false
+	}
+}
+
+// submitRetry 將重試的 slot 交給空閒的 worker，停止後返回 false
+func (p *pipeline) submitRetry(slot uint64, attempt int) bool {
+	job := slotJob{
+		slot:       slot,
+		produced:   true,
+		attempt:    attempt,
+		submitTime: time.Now(),
+	}
+
+	select {
+	case p.retries <- job:
+		return true
+	case <-p.stop:
+		return false
+	}
+}
+
+// inFlight 已提交但尚未發布的 slot 數量
+func (p *pipeline) inFlight() int {
+	return len(p.window)
+}
+
+func (p *pipeline) close() {
+	close(p.stop)
+	p.wg.Wait()
+}
@@ -1,76 +1,226 @@
 package monitor
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
+
+	"solana/src/config"
+	"solana/src/models"
+	"solana/src/services"
 )
 
-func (bm *BlockMonitor) processBlock(slot uint64) error {
-	// 檢查是否已處理
-	if bm.processedSlots[slot] {
-		return nil
+// processRange 通過 getBlocks 列舉 [startSlot, endSlot] 內已產出的區塊並按順序提交到處理管線，
+// 兩個區塊之間的空缺直接作為空槽提交，返回下一次應開始的 slot
+func (bm *BlockMonitor) processRange(startSlot, endSlot uint64) (uint64, error) {
+	blocks, err := bm.solanaClient.GetBlocks(startSlot, endSlot)
+	if err != nil {
+		return startSlot, fmt.Errorf("failed to get blocks: %v", err)
 	}
 
-	// 檢查區塊狀態
-	status, err := bm.solanaClient.CheckSlotStatus(slot)
-	if err != nil {
-		bm.handlePendingSlot(slot, "NOT_AVAILABLE")
-		return fmt.Errorf("failed to check slot status: %v", err)
+	// 節點只會返回已確認的區塊，最後一個區塊之後的 slot 可能尚未確認，留待下一輪處理
+	if len(blocks) == 0 {
+		return startSlot, nil
 	}
 
-	switch status {
-	case "EMPTY":
-		bm.handleEmptySlot(slot)
-		return nil
-	case "NOT_AVAILABLE":
-		bm.handlePendingSlot(slot, status)
-		return fmt.Errorf("block not available yet")
-	case "CONFIRMED":
-		return bm.processConfirmedBlock(slot)
+	next := startSlot
+	for _, blockSlot := range blocks {
+		if blockSlot < next {
+			continue
+		}
+
+		for slot := next; slot < blockSlot; slot++ {
+			if !bm.pipeline.submit(slot, false) {
+				return slot, errMonitorStopped
+			}
+		}
+
+		if !bm.isProcessed(blockSlot) && !bm.pipeline.submit(blockSlot, true) {
+			return blockSlot, errMonitorStopped
+		}
+		next = blockSlot + 1
 	}
 
-	return nil
+	return next, nil
+}
+
+// isProcessed 已離開窗口的 slot 視為未處理，重新處理時可能重複發送
+func (bm *BlockMonitor) isProcessed(slot uint64) bool {
+	return bm.processedSlots.Has(slot)
+}
+
+// retrySlot 由 worker 調用，處理重試隊列中到期的 slot
+func (bm *BlockMonitor) retrySlot(job slotJob) {
+	if bm.isProcessed(job.slot) {
+		bm.clearFailure(job.slot)
+		return
+	}
+
+	result := bm.fetchSlot(job)
+	if err := bm.publishResult(result); err != nil {
+		log.Printf("Retry %d for slot %d failed: %v", job.attempt, job.slot, err)
+		bm.recordAttempts(job.slot, result.attempts)
+		bm.retryLater(job.slot, err)
+		return
+	}
+	bm.clearFailure(job.slot)
+}
+
+// fetchSlot 由 worker 並行調用，只嘗試一次，失敗的 slot 由重試隊列稍後處理，不阻塞 worker
+func (bm *BlockMonitor) fetchSlot(job slotJob) slotResult {
+	result := slotResult{job: job}
+	if job.produced {
+		fetchOnce(&result, job.attempt, bm.fetchBlock)
+	}
+	return result
 }
 
-func (bm *BlockMonitor) processConfirmedBlock(slot uint64) error {
-	startTime := time.Now()
-	var lastErr error
+// fetchOnce 獲取區塊並轉換為消息，失敗時記錄嘗試
+func fetchOnce(result *slotResult, attempt int, fetch func(slot uint64) (*models.BlockResponse, error)) {
+	block, err := fetch(result.job.slot)
+	result.retries = attempt
+	if err != nil {
+		result.err = err
+		result.attempts = append(result.attempts, services.NewAttempt(attempt, err))
+		return
+	}
+
+	message := services.ConvertToBlockMessage(block)
+	result.block = block
+	result.message = &message
+	result.err = nil
+}
+
+// fetchWithRetry 獲取區塊並轉換為消息，失敗時按退避策略重試，用於回填與重新投遞等離線任務
+func fetchWithRetry(job slotJob, retryConfig *config.RetryConfig, fetch func(slot uint64) (*models.BlockResponse, error)) slotResult {
+	result := slotResult{job: job}
+	if !job.produced {
+		return result
+	}
 
 	// 重試邏輯
-	for retry := 0; retry <= bm.retryConfig.MaxRetries; retry++ {
+	for retry := 0; retry <= retryConfig.MaxRetries; retry++ {
 		if retry > 0 {
-			delay := bm.retryConfig.Backoff(retry)
+			delay := retryConfig.Backoff(retry)
+			// 被限流時至少等待服務端要求的時間
+			if retryAfter := services.RetryAfter(result.err); retryAfter > delay {
+				delay = retryAfter
+			}
 			time.Sleep(delay)
 		}
 
-		// 獲取區塊數據
-		block, err := bm.solanaClient.GetBlock(slot)
-		if err != nil {
-			lastErr = fmt.Errorf("attempt %d: failed to get block details: %v", retry+1, err)
-			continue
+		fetchOnce(&result, retry, fetch)
+		if result.err == nil || !retryable(result.err) {
+			return result
 		}
+	}
 
-		// 發送到 Kafka
-		if err := bm.producer.SendBlockMessage(block); err != nil {
-			lastErr = fmt.Errorf("attempt %d: failed to send to kafka: %v", retry+1, err)
-			continue
-		}
+	return result
+}
+
+// retryable 空槽與暫不可用是明確的結果，立即重試無意義
+func retryable(err error) bool {
+	return !errors.Is(err, services.ErrSlotSkipped) &&
+		!errors.Is(err, services.ErrBlockNotAvailable) &&
+		!errors.Is(err, services.ErrLongTermStorage)
+}
+
+// commitSlot 由管線按 slot 順序調用
+func (bm *BlockMonitor) commitSlot(result slotResult) {
+	bm.leaders.prefetch(result.job.slot)
+	if err := bm.publishResult(result); err != nil {
+		log.Printf("Error processing block %d: %v", result.job.slot, err)
+		bm.recordAttempts(result.job.slot, result.attempts)
+		bm.retryLater(result.job.slot, err)
+		bm.metrics.RecordMissed()
+	} else {
+		bm.clearFailure(result.job.slot)
+	}
+	// 失敗的 slot 已記錄在重試隊列中，隨進度一起保存；異步發送時等 Kafka 確認後才推進進度
+	bm.acks.committed(result.job.slot)
+}
+
+// deliveryFailed 異步發送最終失敗時調用，清除已處理標記並重新安排該 slot
+func (bm *BlockMonitor) deliveryFailed(slot uint64, err error) {
+	bm.metrics.RecordFailure()
+	bm.processedSlots.Remove(slot)
+	bm.emptySlots.Remove(slot)
+	bm.recordAttempts(slot, []models.DeadLetterAttempt{services.NewAttempt(0, err)})
 
-		// 處理成功
-		processTime := time.Since(startTime)
-		bm.metrics.UpdateMetrics(slot, processTime, retry > 0)
+	// 先放入重試隊列，保證進度推進時該 slot 不會遺漏；
+	// 死信判斷可能需要發送消息，放到背景進行，避免在確認回調中阻塞發送隊列
+	bm.scheduler.schedule(slot, 0, bm.retryConfig.InitialDelay, err)
+	go bm.retryLater(slot, err)
+}
+
+// publishResult 根據處理結果更新狀態，並在校驗父鏈後發送區塊
+func (bm *BlockMonitor) publishResult(result slotResult) error {
+	slot := result.job.slot
+	if !result.job.produced {
+		return bm.handleEmptySlot(slot)
+	}
 
-		// 更新狀態
+	switch err := result.err; {
+	case err == nil:
+	case errors.Is(err, services.ErrSlotSkipped):
 		bm.pendingMutex.Lock()
 		delete(bm.pendingSlots, slot)
-		bm.processedSlots[slot] = true
 		bm.pendingMutex.Unlock()
+		return bm.handleEmptySlot(slot)
+	case errors.Is(err, services.ErrBlockNotAvailable), errors.Is(err, services.ErrLongTermStorage):
+		// 區塊暫時不可用，交給待確認檢查器稍後處理
+		bm.handlePendingSlot(slot, "NOT_AVAILABLE")
+		return fmt.Errorf("block not available yet: %w", err)
+	default:
+		bm.metrics.RecordFailure()
+		return fmt.Errorf("failed to get block details after %d attempts: %w", result.retries+1, err)
+	}
 
-		log.Printf("Successfully processed block %d (retry: %d, time: %v)", slot, retry, processTime)
-		return nil
+	// 校驗父鏈，發現分叉時先發送重組事件
+	block := result.block
+	link := chainLink{
+		Slot:              slot,
+		ParentSlot:        block.Result.ParentSlot,
+		Blockhash:         block.Result.Blockhash,
+		PreviousBlockhash: block.Result.PreviousBlockhash,
+	}
+	orphaned, err := bm.checkChain(link)
+	if err != nil {
+		bm.metrics.RecordFailure()
+		return fmt.Errorf("failed to handle reorg: %v", err)
 	}
 
-	bm.metrics.RecordFailure()
-	return lastErr
+	// 發送到 Kafka
+	if err := bm.sendBlock(result.message); err != nil {
+		bm.metrics.RecordFailure()
+		return fmt.Errorf("failed to send to kafka: %v", err)
+	}
+	bm.chain.record(link, orphaned)
+
+	// 處理成功
+	processTime := time.Since(result.job.submitTime)
+	bm.metrics.UpdateMetrics(slot, processTime, result.retries > 0)
+
+	// 更新狀態
+	bm.pendingMutex.Lock()
+	delete(bm.pendingSlots, slot)
+	bm.pendingMutex.Unlock()
+	bm.processedSlots.Add(slot)
+
+	bm.trackFinality(slot, block.Result.Blockhash)
+
+	log.Printf("Successfully processed block %d (retry: %d, time: %v)", slot, result.retries, processTime)
+	return nil
+}
+
+// sendBlock 發送區塊消息，事務模式下在同一事務中寫入包含該 slot 的進度，
+// 重啟後從進度繼續時不會重複發送，read_committed 的消費者每個 slot 只會看到一次
+func (bm *BlockMonitor) sendBlock(message *models.BlockMessage) error {
+	if bm.txnSink == nil {
+		return bm.sink.PublishBlock(message)
+	}
+	return bm.txnSink.PublishBlockWithCheckpoint(message, func() *models.Checkpoint {
+		return bm.checkpointWith(message.Slot)
+	})
 }
@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"solana/src/config"
+	"solana/src/services"
+)
+
+// RedriveDeadLetters 重新獲取並發送死信中的 slot，成功的項目從死信中移除，
+// 仍失敗的項目追加本次的嘗試記錄後保留，返回成功與剩餘的數量
+func RedriveDeadLetters(cfg *config.Config, sink services.Sink, store services.DeadLetterStore) (int, int, error) {
+	letters, err := store.Load()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(letters) == 0 {
+		return 0, 0, nil
+	}
+
+	client := services.NewSolanaClient(cfg.RPC)
+	defer client.Close()
+	leaders := newLeaderCache(client)
+	retryConfig := config.NewRetryConfig()
+
+	redriven := 0
+	remaining := letters[:0]
+	for _, letter := range letters {
+		result := fetchWithRetry(slotJob{
+			slot:       letter.Slot,
+			produced:   true,
+			submitTime: time.Now(),
+		}, retryConfig, client.GetBlock)
+
+		switch {
+		case errors.Is(result.err, services.ErrSlotSkipped):
+			// 節點已確認為空槽
+			event := newSkippedEvent(letter.Slot, leaders.leader(letter.Slot), cfg.RPC.BlockCommitment())
+			err = sink.PublishEvent(event)
+		case result.err != nil:
+			err = result.err
+		default:
+			err = sink.PublishBlock(result.message)
+		}
+
+		if err != nil {
+			log.Printf("Redrive failed for slot %d: %v", letter.Slot, err)
+			if result.err == nil {
+				// 獲取成功但發送失敗
+				result.attempts = append(result.attempts, services.NewAttempt(0, err))
+			}
+			letter.Attempts = append(letter.Attempts, result.attempts...)
+			if excess := len(letter.Attempts) - maxAttemptHistory; excess > 0 {
+				letter.Attempts = letter.Attempts[excess:]
+			}
+			letter.Redrives++
+			letter.FailedAt = time.Now().Unix()
+			remaining = append(remaining, letter)
+			continue
+		}
+
+		redriven++
+		log.Printf("Redrove slot %d", letter.Slot)
+	}
+
+	// 保留重新投遞期間新寫入的死信
+	current, err := store.Load()
+	if err != nil {
+		return redriven, len(remaining), fmt.Errorf("failed to reload dead letters: %v", err)
+	}
+	if len(current) > len(letters) {
+		remaining = append(remaining, current[len(letters):]...)
+	}
+
+	if err := store.Replace(remaining); err != nil {
+		return redriven, len(remaining), err
+	}
+	return redriven, len(remaining), nil
+}
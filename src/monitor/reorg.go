@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"solana/src/models"
+)
+
+// checkChain 校驗新區塊是否接在已發送的鏈上，發現分叉時發送重組事件並返回被孤立的 slot
+func (bm *BlockMonitor) checkChain(link chainLink) ([]uint64, error) {
+	// 父區塊曾被判定為空槽，說明之前的判斷有誤，交給重試隊列補發，補發的父區塊會在子區塊之後發送
+	if bm.isEmptySlot(link.ParentSlot) {
+		log.Printf("Parent slot %d of block %d was marked empty, reprocessing", link.ParentSlot, link.Slot)
+		bm.emptySlots.Remove(link.ParentSlot)
+		bm.reprocessSlot(link.ParentSlot)
+	}
+
+	orphaned, parentMismatch := bm.chain.validate(link)
+	if len(orphaned) == 0 {
+		return nil, nil
+	}
+
+	event := &models.SlotEvent{
+		Type:              models.EventReorg,
+		Slot:              link.Slot,
+		Blockhash:         link.Blockhash,
+		ParentSlot:        link.ParentSlot,
+		PreviousBlockhash: link.PreviousBlockhash,
+		OrphanedSlots:     orphaned,
+		Commitment:        bm.config.RPC.BlockCommitment(),
+		Timestamp:         time.Now().Unix(),
+	}
+	if err := bm.sink.PublishEvent(event); err != nil {
+		return nil, fmt.Errorf("failed to send reorg event: %v", err)
+	}
+	log.Printf("Reorg detected at slot %d (parent %d), orphaned slots: %v", link.Slot, link.ParentSlot, orphaned)
+
+	// 被孤立的 slot 不再需要最終確認校驗
+	bm.finalityMutex.Lock()
+	for _, slot := range orphaned {
+		delete(bm.unfinalized, slot)
+	}
+	bm.finalityMutex.Unlock()
+
+	// 父區塊已被替換，交給重試隊列補發新的父區塊
+	if parentMismatch {
+		bm.chain.remove(link.ParentSlot)
+		bm.reprocessSlot(link.ParentSlot)
+	}
+
+	return orphaned, nil
+}
+
+func (bm *BlockMonitor) isEmptySlot(slot uint64) bool {
+	return bm.emptySlots.Has(slot)
+}
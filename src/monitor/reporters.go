@@ -5,6 +5,9 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"solana/src/services"
+	"solana/src/utils"
 )
 
 func (bm *BlockMonitor) startMetricsReporter() {
@@ -26,39 +29,6 @@ func (bm *BlockMonitor) startMetricsReporter() {
 	}()
 }
 
-func (bm *BlockMonitor) startPendingSlotsChecker() {
-	bm.wg.Add(1)
-	go func() {
-		defer bm.wg.Done()
-		ticker := time.NewTicker(time.Second * 30)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				bm.checkPendingSlots()
-			case <-bm.stopChan:
-				return
-			}
-		}
-	}()
-}
-
-func (bm *BlockMonitor) checkPendingSlots() {
-	bm.pendingMutex.RLock()
-	slots := make([]uint64, 0, len(bm.pendingSlots))
-	for slot := range bm.pendingSlots {
-		slots = append(slots, slot)
-	}
-	bm.pendingMutex.RUnlock()
-
-	for _, slot := range slots {
-		if err := bm.processBlock(slot); err != nil {
-			log.Printf("Retry processing slot %d failed: %v", slot, err)
-		}
-	}
-}
-
 func formatMetrics(stats map[string]interface{}) string {
 	var sb strings.Builder
 	sb.WriteString("\n=== Block Processing Metrics ===\n")
@@ -66,6 +36,7 @@ func formatMetrics(stats map[string]interface{}) string {
 	sb.WriteString(fmt.Sprintf("Total Failed: %d\n", stats["total_failed"]))
 	sb.WriteString(fmt.Sprintf("Total Retried: %d\n", stats["total_retried"]))
 	sb.WriteString(fmt.Sprintf("Total Missed: %d\n", stats["total_missed"]))
+	sb.WriteString(fmt.Sprintf("Total Dead Lettered: %d\n", stats["total_dead_lettered"]))
 	sb.WriteString(fmt.Sprintf("Blocks/Second: %.2f\n", stats["blocks_per_second"]))
 	sb.WriteString(fmt.Sprintf("Avg Processing Time: %.2fms\n", stats["avg_processing_time"]))
 	sb.WriteString(fmt.Sprintf("Success Rate: %.2f%%\n", stats["success_rate"]))
@@ -73,6 +44,40 @@ func formatMetrics(stats map[string]interface{}) string {
 	sb.WriteString(fmt.Sprintf("Last Processed Slot: %d\n", stats["last_processed_slot"]))
 	sb.WriteString(fmt.Sprintf("Empty Slots: %d\n", stats["empty_slots"]))
 	sb.WriteString(fmt.Sprintf("Pending Slots: %d\n", stats["pending_slots"]))
+	sb.WriteString(fmt.Sprintf("Awaiting Kafka Ack: %d\n", stats["awaiting_ack"]))
+	if queue, ok := stats["retry_queue"].(RetryQueueStats); ok {
+		sb.WriteString(fmt.Sprintf("Retry Queue: scheduled=%d in_flight=%d due=%d dispatched=%d next=%s\n",
+			queue.Scheduled, queue.InFlight, queue.Due, queue.Dispatched, queue.NextRetry))
+	}
+	if endpoints, ok := stats["rpc_endpoints"].([]services.EndpointStats); ok {
+		sb.WriteString("--- RPC Endpoints ---\n")
+		for _, ep := range endpoints {
+			sb.WriteString(fmt.Sprintf("%s healthy=%v latency=%.1fms errors=%.2f slot=%d lag=%d score=%.1f\n",
+				ep.URL, ep.Healthy, ep.LatencyMs, ep.ErrorRate, ep.LastSlot, ep.SlotLag, ep.Score))
+		}
+	}
+	if windows, ok := stats["slot_windows"].([]utils.SlotWindowStats); ok {
+		sb.WriteString("--- Slot Windows ---\n")
+		for _, w := range windows {
+			sb.WriteString(fmt.Sprintf("%s base=%d size=%d count=%d evicted=%d rejected=%d\n",
+				w.Name, w.Base, w.Size, w.Count, w.Evicted, w.Rejected))
+		}
+	}
+	if spool, ok := stats["spool"].(services.SpoolStats); ok {
+		sb.WriteString(fmt.Sprintf("Spool: connected=%v records=%d bytes=%d/%d segments=%d spooled=%d replayed=%d\n",
+			spool.Connected, spool.Records, spool.Bytes, spool.QuotaBytes, spool.Segments, spool.Spooled, spool.Replayed))
+	}
+	if ws, ok := stats["websocket"].(services.WebSocketStats); ok {
+		sb.WriteString(fmt.Sprintf("WebSocket: connected=%v healthy=%v reconnects=%d last_slot=%d last_message=%s\n",
+			ws.Connected, ws.Healthy, ws.Reconnects, ws.LastSlot, ws.LastMessageAgo))
+	}
+	if limits, ok := stats["rate_limits"].([]services.RateLimitStats); ok {
+		sb.WriteString("--- Rate Limits ---\n")
+		for _, l := range limits {
+			sb.WriteString(fmt.Sprintf("%s %s rate=%.1f/%.1f tokens=%.1f paused=%s throttled=%d\n",
+				l.Endpoint, l.Method, l.CurrentRate, l.ConfiguredRate, l.Tokens, l.PausedFor, l.Throttled))
+		}
+	}
 	sb.WriteString("=============================")
 	return sb.String()
 }
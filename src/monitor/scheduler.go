@@ -0,0 +1,231 @@
+package monitor
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetryItem 等待重試的 slot
+type RetryItem struct {
+	Slot       uint64    `json:"slot"`
+	Attempt    int       `json:"attempt"`
+	NextRetry  time.Time `json:"next_retry"`
+	LastError  string    `json:"last_error"`
+	Dispatched bool      `json:"dispatched"` // 已交給 worker，等待結果
+	index      int
+}
+
+// RetryQueueStats 重試隊列狀態快照
+type RetryQueueStats struct {
+	Scheduled  int    `json:"scheduled"`
+	InFlight   int    `json:"in_flight"`
+	Due        int    `json:"due"`
+	Dispatched uint64 `json:"dispatched"`
+	NextRetry  string `json:"next_retry"`
+}
+
+// retryHeap 按下次重試時間排序的最小堆
+type retryHeap []*RetryItem
+
+func (h retryHeap) Len() int           { return len(h) }
+func (h retryHeap) Less(i, j int) bool { return h[i].NextRetry.Before(h[j].NextRetry) }
+func (h retryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *retryHeap) Push(x interface{}) {
+	item := x.(*RetryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *retryHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	item.index = -1
+	*h = old[:len(old)-1]
+	return item
+}
+
+// retryScheduler 延遲隊列，到期的 slot 交給 dispatch 處理，等待期間不佔用 worker
+type retryScheduler struct {
+	items      map[uint64]*RetryItem
+	queue      retryHeap
+	dispatch   func(slot uint64, attempt int) bool // 停止後返回 false
+	jitter     float64
+	dispatched uint64
+	wake       chan struct{}
+	mutex      sync.Mutex
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+func newRetryScheduler(jitter float64, dispatch func(slot uint64, attempt int) bool) *retryScheduler {
+	return &retryScheduler{
+		items:    make(map[uint64]*RetryItem),
+		dispatch: dispatch,
+		jitter:   jitter,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// schedule 安排 slot 在 delay 之後重試，實際延遲會加上隨機抖動
+func (s *retryScheduler) schedule(slot uint64, attempt int, delay time.Duration, lastErr error) {
+	if s.jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + s.jitter*(2*rand.Float64()-1)))
+	}
+
+	s.mutex.Lock()
+	item, ok := s.items[slot]
+	if !ok {
+		item = &RetryItem{Slot: slot, index: -1}
+		s.items[slot] = item
+	}
+	item.Attempt = attempt
+	item.NextRetry = time.Now().Add(delay)
+	item.Dispatched = false
+	if lastErr != nil {
+		item.LastError = lastErr.Error()
+	}
+	if item.index >= 0 {
+		heap.Fix(&s.queue, item.index)
+	} else {
+		heap.Push(&s.queue, item)
+	}
+	s.mutex.Unlock()
+
+	s.notify()
+}
+
+// done 結束 slot 的重試，成功或寫入死信後調用
+func (s *retryScheduler) done(slot uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.items[slot]
+	if !ok {
+		return
+	}
+	if item.index >= 0 {
+		heap.Remove(&s.queue, item.index)
+	}
+	delete(s.items, slot)
+}
+
+func (s *retryScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *retryScheduler) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		timer := time.NewTimer(time.Hour)
+		defer timer.Stop()
+
+		for {
+			s.mutex.Lock()
+			var wait time.Duration = -1
+			var due *RetryItem
+			if len(s.queue) > 0 {
+				next := s.queue[0]
+				if wait = time.Until(next.NextRetry); wait <= 0 {
+					due = heap.Pop(&s.queue).(*RetryItem)
+					due.Dispatched = true
+					s.dispatched++
+				}
+			}
+			s.mutex.Unlock()
+
+			if due != nil {
+				if !s.dispatch(due.Slot, due.Attempt) {
+					return
+				}
+				continue
+			}
+
+			// 隊列為空時只等待新的項目
+			var timeout <-chan time.Time
+			if wait > 0 {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(wait)
+				timeout = timer.C
+			}
+
+			select {
+			case <-timeout:
+			case <-s.wake:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *retryScheduler) close() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// slots 返回所有等待或正在重試的 slot，用於保存進度
+func (s *retryScheduler) slots() []uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	slots := make([]uint64, 0, len(s.items))
+	for slot := range s.items {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots
+}
+
+// snapshot 返回按下次重試時間排序的隊列內容
+func (s *retryScheduler) snapshot() []RetryItem {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := make([]RetryItem, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].NextRetry.Before(items[j].NextRetry) })
+	return items
+}
+
+func (s *retryScheduler) stats() RetryQueueStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	stats := RetryQueueStats{
+		Scheduled:  len(s.queue),
+		InFlight:   len(s.items) - len(s.queue),
+		Dispatched: s.dispatched,
+		NextRetry:  "none",
+	}
+	for _, item := range s.queue {
+		if !item.NextRetry.After(now) {
+			stats.Due++
+		}
+	}
+	if len(s.queue) > 0 {
+		stats.NextRetry = time.Until(s.queue[0].NextRetry).Truncate(time.Millisecond).String()
+	}
+	return stats
+}
@@ -0,0 +1,144 @@
+package monitor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type scheduled struct {
+	slot    uint64
+	attempt int
+	delay   time.Duration
+}
+
+func TestRetrySchedulerDeduplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule []scheduled
+		want     []RetryItem
+	}{
+		{
+			name: "single slot",
+			schedule: []scheduled{
+				{slot: 10, attempt: 1, delay: time.Hour},
+			},
+			want: []RetryItem{{Slot: 10, Attempt: 1}},
+		},
+		{
+			name: "same slot scheduled twice keeps one item with the latest attempt",
+			schedule: []scheduled{
+				{slot: 10, attempt: 1, delay: time.Hour},
+				{slot: 10, attempt: 2, delay: 2 * time.Hour},
+			},
+			want: []RetryItem{{Slot: 10, Attempt: 2}},
+		},
+		{
+			name: "duplicates among other slots",
+			schedule: []scheduled{
+				{slot: 10, attempt: 1, delay: time.Hour},
+				{slot: 11, attempt: 1, delay: 3 * time.Hour},
+				{slot: 10, attempt: 3, delay: 2 * time.Hour},
+				{slot: 11, attempt: 2, delay: time.Hour},
+			},
+			want: []RetryItem{{Slot: 11, Attempt: 2}, {Slot: 10, Attempt: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRetryScheduler(0, func(uint64, int) bool { return true })
+			for _, item := range tt.schedule {
+				s.schedule(item.slot, item.attempt, item.delay, errors.New("retry"))
+			}
+
+			if got := s.stats().Scheduled; got != len(tt.want) {
+				t.Fatalf("scheduled = %d, want %d", got, len(tt.want))
+			}
+			snapshot := s.snapshot()
+			got := make([]RetryItem, len(snapshot))
+			for i, item := range snapshot {
+				got[i] = RetryItem{Slot: item.Slot, Attempt: item.Attempt}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("items = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetrySchedulerFiresInOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule []scheduled
+		want     []uint64
+	}{
+		{
+			name: "ordered by delay not by schedule order",
+			schedule: []scheduled{
+				{slot: 3, delay: 30 * time.Millisecond},
+				{slot: 1, delay: 10 * time.Millisecond},
+				{slot: 2, delay: 20 * time.Millisecond},
+			},
+			want: []uint64{1, 2, 3},
+		},
+		{
+			name: "rescheduling moves the slot",
+			schedule: []scheduled{
+				{slot: 1, delay: 10 * time.Millisecond},
+				{slot: 2, delay: 20 * time.Millisecond},
+				{slot: 1, delay: 30 * time.Millisecond},
+			},
+			want: []uint64{2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := make(chan uint64, len(tt.schedule))
+			s := newRetryScheduler(0, func(slot uint64, attempt int) bool {
+				fired <- slot
+				return true
+			})
+			// 先排好隊列再啟動，避免調度過程中提前觸發
+			for _, item := range tt.schedule {
+				s.schedule(item.slot, item.attempt, item.delay, nil)
+			}
+			s.start()
+			defer s.close()
+
+			var got []uint64
+			timeout := time.After(5 * time.Second)
+			for len(got) < len(tt.want) {
+				select {
+				case slot := <-fired:
+					got = append(got, slot)
+				case <-timeout:
+					t.Fatalf("fired %v before timeout, want %v", got, tt.want)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fired %v, want %v", got, tt.want)
+			}
+
+			select {
+			case slot := <-fired:
+				t.Errorf("slot %d fired more than once", slot)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestRetrySchedulerDone(t *testing.T) {
+	s := newRetryScheduler(0, func(uint64, int) bool { return true })
+	s.schedule(1, 1, time.Hour, nil)
+	s.schedule(2, 1, time.Hour, nil)
+	s.done(1)
+	s.done(3)
+
+	if got := s.slots(); !reflect.DeepEqual(got, []uint64{2}) {
+		t.Errorf("slots = %v, want [2]", got)
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"solana/src/config"
+	"solana/src/services"
 )
 
 func (bm *BlockMonitor) Start() error {
 	// 啟動監控報告器
 	bm.startMetricsReporter()
-	bm.startPendingSlotsChecker()
+	bm.startFinalityChecker()
 
 	// 獲取最新的 slot
 	latestSlot, err := bm.solanaClient.GetLatestSlot()
@@ -17,8 +20,31 @@ func (bm *BlockMonitor) Start() error {
 		return fmt.Errorf("failed to get latest slot: %v", err)
 	}
 
-	bm.currentSlot = latestSlot
-	log.Printf("Starting from slot: %d", latestSlot)
+	// 有保存的進度時從中斷處繼續，中間的 slot 由正常流程補齊
+	if resumeSlot, ok := bm.restoreCheckpoint(); ok {
+		bm.currentSlot = resumeSlot
+		if latestSlot >= resumeSlot {
+			log.Printf("Resuming from slot: %d (%d slots behind)", resumeSlot, latestSlot-resumeSlot+1)
+		}
+	} else {
+		bm.currentSlot = latestSlot
+		bm.markCommitted(latestSlot - 1)
+		log.Printf("Starting from slot: %d", latestSlot)
+	}
+	bm.startCheckpointWriter()
+
+	bm.pipeline.start()
+	bm.scheduler.start()
+	bm.startAdminServer()
+
+	// websocket 模式下由 slot 推送驅動處理，輪詢僅在連接不健康時作為後備
+	var slotUpdates <-chan services.SlotUpdate
+	var blockUpdates <-chan services.BlockUpdate
+	if bm.wsClient != nil {
+		bm.wsClient.Start()
+		slotUpdates = bm.wsClient.Slots()
+		blockUpdates = bm.wsClient.Blocks()
+	}
 
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
@@ -26,25 +52,27 @@ func (bm *BlockMonitor) Start() error {
 	for {
 		select {
 		case <-ticker.C:
+			if bm.wsClient != nil && bm.wsClient.Healthy() {
+				continue
+			}
+
 			newLatestSlot, err := bm.solanaClient.GetLatestSlot()
 			if err != nil {
 				log.Printf("Error getting latest slot: %v", err)
 				continue
 			}
+			bm.catchUp(newLatestSlot)
 
-			for slot := bm.currentSlot; slot <= newLatestSlot; slot++ {
-				if err := bm.processBlock(slot); err != nil {
-					log.Printf("Error processing block %d: %v", slot, err)
-					bm.missingSlots = append(bm.missingSlots, slot)
-					bm.metrics.RecordMissed()
-				}
-			}
-
-			if len(bm.missingSlots) > 0 {
-				bm.processMissingSlots()
+		case update := <-slotUpdates:
+			// finalized 模式下只處理到 root，之前的 slot 已被節點確認，不會再回滾
+			if bm.config.RPC.Commitment == config.CommitmentFinalized {
+				bm.catchUp(update.Root)
+			} else {
+				bm.catchUp(update.Slot)
 			}
 
-			bm.currentSlot = newLatestSlot
+		case update := <-blockUpdates:
+			bm.cacheStreamedBlock(update)
 
 		case <-bm.stopChan:
 			return nil
@@ -52,16 +80,17 @@ func (bm *BlockMonitor) Start() error {
 	}
 }
 
-func (bm *BlockMonitor) processMissingSlots() {
-	log.Printf("Processing %d missing blocks", len(bm.missingSlots))
-	for i := 0; i < len(bm.missingSlots); i++ {
-		slot := bm.missingSlots[i]
-		if err := bm.processBlock(slot); err != nil {
-			log.Printf("Still failed to process missing block %d: %v", slot, err)
-			continue
-		}
-		// 成功處理後從列表中移除
-		bm.missingSlots = append(bm.missingSlots[:i], bm.missingSlots[i+1:]...)
-		i-- // 調整索引
+// catchUp 處理從 currentSlot 到 latestSlot 之間的所有 slot
+func (bm *BlockMonitor) catchUp(latestSlot uint64) {
+	if latestSlot < bm.currentSlot {
+		return
 	}
+
+	next, err := bm.processRange(bm.currentSlot, latestSlot)
+	if err != nil {
+		log.Printf("Error processing slots %d-%d: %v", bm.currentSlot, latestSlot, err)
+		return
+	}
+
+	bm.currentSlot = next
 }
@@ -1,6 +1,16 @@
 package monitor
 
 func (bm *BlockMonitor) Stop() {
-	close(bm.stopChan)
-	bm.wg.Wait()
+	bm.stopOnce.Do(func() {
+		close(bm.stopChan)
+		bm.wg.Wait()
+		bm.stopAdminServer()
+		bm.pipeline.close()
+		bm.scheduler.close()
+		bm.saveCheckpoint()
+		if bm.wsClient != nil {
+			bm.wsClient.Stop()
+		}
+		bm.solanaClient.Close()
+	})
 }
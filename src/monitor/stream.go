@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"solana/src/models"
+	"solana/src/services"
+)
+
+// 最多暫存的推送區塊數量
+const maxStreamedBlocks = 1000
+
+// cacheStreamedBlock 暫存 blockSubscribe 推送的區塊，處理到該 slot 時無需再調用 getBlock
+func (bm *BlockMonitor) cacheStreamedBlock(update services.BlockUpdate) {
+	bm.streamMutex.Lock()
+	defer bm.streamMutex.Unlock()
+
+	if bm.isProcessed(update.Slot) {
+		return
+	}
+
+	// 超過上限時淘汰最舊的區塊
+	if len(bm.streamedBlocks) >= maxStreamedBlocks {
+		oldest := update.Slot
+		for slot := range bm.streamedBlocks {
+			if slot < oldest {
+				oldest = slot
+			}
+		}
+		delete(bm.streamedBlocks, oldest)
+	}
+	bm.streamedBlocks[update.Slot] = update.Block
+}
+
+// takeStreamedBlock 取出並移除暫存的推送區塊
+func (bm *BlockMonitor) takeStreamedBlock(slot uint64) (*models.BlockResponse, bool) {
+	bm.streamMutex.Lock()
+	defer bm.streamMutex.Unlock()
+
+	block, ok := bm.streamedBlocks[slot]
+	if ok {
+		delete(bm.streamedBlocks, slot)
+	}
+	return block, ok
+}
+
+// fetchBlock 優先使用推送的區塊，否則通過 RPC 獲取
+func (bm *BlockMonitor) fetchBlock(slot uint64) (*models.BlockResponse, error) {
+	if block, ok := bm.takeStreamedBlock(slot); ok {
+		return block, nil
+	}
+	return bm.solanaClient.GetBlock(slot)
+}
@@ -0,0 +1,232 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenColumns 覆蓋 Writer 支持的所有列類型
+var goldenColumns = []Column{
+	Uint64Column("slot"),
+	Int32Column("index"),
+	Int64Column("change"),
+	TimestampColumn("block_time"),
+	StringColumn("signature"),
+	StringListColumn("programs"),
+}
+
+// goldenRows 包含邊界值、空字符串、非 ASCII 字符串和空列表
+var goldenRows = [][]interface{}{
+	{int64(1), int32(0), int64(-5), int64(1767225600000), "sig-1", []string{"vote"}},
+	{int64(2), int32(2147483647), int64(0), int64(1767225601000), "", []string{}},
+	{int64(3), int32(-2147483648), int64(9223372036854775807), int64(0), "交易", []string{"a", "bb", "ccc"}},
+	{int64(4), int32(7), int64(-9223372036854775808), int64(1767229199999), "sig-4", []string{""}},
+	{int64(5), int32(1), int64(42), int64(1767225600001), "sig-5", []string{}},
+}
+
+// goldenRowGroupSize 足夠小，使每一兩行就生成一個行組
+const goldenRowGroupSize = 64
+
+const goldenPath = "testdata/golden.parquet"
+
+func writeGolden(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, goldenColumns, goldenRowGroupSize, "solana archive test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range goldenRows {
+		if err := w.Write(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterMatchesGolden(t *testing.T) {
+	data := writeGolden(t)
+	if *update {
+		if err := os.WriteFile(goldenPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, golden) {
+		t.Errorf("writer output differs from %s, run with -update after checking it with a real reader", goldenPath)
+	}
+}
+
+func TestReadGolden(t *testing.T) {
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]interface{}
+	rows, err := Read(bytes.NewReader(golden), int64(len(golden)), goldenColumns, func(values []interface{}) error {
+		got = append(got, append([]interface{}(nil), values...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != int64(len(goldenRows)) {
+		t.Errorf("rows = %d, want %d", rows, len(goldenRows))
+	}
+	if !reflect.DeepEqual(got, goldenRows) {
+		t.Errorf("rows = %v, want %v", got, goldenRows)
+	}
+
+	// 行組大小使文件包含多個行組
+	metadata, err := footerOf(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups := len(list(metadata, 4)); groups < 2 {
+		t.Errorf("%d row groups, want several", groups)
+	}
+}
+
+// TestGoldenLayout 按 Parquet 規範和 Thrift compact 協議手工解碼文件開頭的第一個列塊，
+// 不依賴本包的讀取代碼
+func TestGoldenLayout(t *testing.T) {
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		'P', 'A', 'R', '1',
+		// PageHeader：字段增量 1 為 i32 (0x15)，值為 zigzag varint
+		0x15, 0x00, // type = DATA_PAGE (0)
+		0x15, 0x20, // uncompressed_page_size = 16
+		0x15, 0x24, // compressed_page_size = 18
+		0x2c,       // 字段 5 data_page_header，增量 2 的 struct
+		0x15, 0x04, // num_values = 2
+		0x15, 0x00, // encoding = PLAIN
+		0x15, 0x06, // definition_level_encoding = RLE (3)
+		0x15, 0x06, // repetition_level_encoding = RLE (3)
+		0x00, 0x00, // DataPageHeader 和 PageHeader 結束
+		// snappy：未壓縮長度 16，一個 16 字節的字面量 ((16-1)<<2)
+		0x10, 0x3c,
+		// 第一個行組的 slot 列：PLAIN 編碼的小端 int64 1 和 2
+		0x01, 0, 0, 0, 0, 0, 0, 0,
+		0x02, 0, 0, 0, 0, 0, 0, 0,
+	}
+	if len(golden) < len(want) || !bytes.Equal(golden[:len(want)], want) {
+		t.Errorf("first column chunk = % x, want % x", golden[:len(want)], want)
+	}
+}
+
+func TestVerifyRejectsDamagedFiles(t *testing.T) {
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		columns []Column
+	}{
+		{name: "truncated", data: golden[:len(golden)-1], columns: goldenColumns},
+		{name: "bad magic", data: append([]byte("PAR0"), golden[4:]...), columns: goldenColumns},
+		{name: "schema mismatch", data: golden, columns: append([]Column{Int64Column("slot")}, goldenColumns[1:]...)},
+		{name: "missing column", data: golden, columns: goldenColumns[:len(goldenColumns)-1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(bytes.NewReader(tt.data), int64(len(tt.data)), tt.columns); err == nil {
+				t.Error("Verify() = nil, want an error")
+			}
+		})
+	}
+}
+
+// pyarrowScript 按列輸出 JSON，時間戳和無符號整數轉為物理的 int64
+const pyarrowScript = `
+import json, sys
+import pyarrow as pa
+import pyarrow.parquet as pq
+
+table = pq.read_table(sys.argv[1])
+out = {}
+for name in table.column_names:
+    column = table.column(name)
+    if pa.types.is_timestamp(column.type) or pa.types.is_unsigned_integer(column.type):
+        column = column.cast(pa.int64(), safe=False)
+    out[name] = column.to_pylist()
+print(json.dumps(out))
+`
+
+// TestGoldenWithPyArrow 用獨立的實現讀取金標文件，環境中沒有 pyarrow 時跳過
+func TestGoldenWithPyArrow(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	if err := exec.Command(python, "-c", "import pyarrow").Run(); err != nil {
+		t.Skip("pyarrow not installed")
+	}
+
+	path, err := filepath.Abs(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(python, "-c", pyarrowScript, path).Output()
+	if err != nil {
+		t.Fatalf("pyarrow failed to read %s: %v", goldenPath, err)
+	}
+
+	expected := make(map[string][]interface{})
+	for _, row := range goldenRows {
+		for i, column := range goldenColumns {
+			expected[column.Name] = append(expected[column.Name], row[i])
+		}
+	}
+	want, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(t, output, want) {
+		t.Errorf("pyarrow read %s, want %s", output, want)
+	}
+}
+
+func footerOf(data []byte) (map[int16]interface{}, error) {
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	return (&compactReader{data: data[len(data)-8-size : len(data)-8]}).structValue()
+}
+
+// jsonEqual 按值比較兩段 JSON，整數保持精度
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	decode := func(data []byte) interface{} {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	return reflect.DeepEqual(decode(a), decode(b))
+}
@@ -0,0 +1,403 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/golang/snappy"
+)
+
+// maxChunkSize 讀取列塊的大小上限，避免損壞的元數據導致過大的分配
+const maxChunkSize = 1 << 31
+
+// Verify 按 Parquet 格式重新讀取整個文件：檢查 schema 與 columns 一致，解壓並解碼每個數據頁，
+// 核對各列的行數和值數量，返回文件的行數。解碼只按格式規範進行，不使用寫入端的編碼代碼，
+// 用於在文件歸檔前發現寫入錯誤
+func Verify(r io.ReaderAt, size int64, columns []Column) (int64, error) {
+	return Read(r, size, columns, nil)
+}
+
+// Read 與 Verify 做相同的檢查，並按行調用 handle，值的類型與 Writer.Write 接受的一致。
+// 每次解碼一個行組，handle 不能保留 values
+func Read(r io.ReaderAt, size int64, columns []Column, handle func(values []interface{}) error) (int64, error) {
+	if size < int64(2*len(magic)+4) {
+		return 0, fmt.Errorf("file too small: %d bytes", size)
+	}
+	head := make([]byte, len(magic))
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return 0, err
+	}
+	trailer := make([]byte, 8)
+	if _, err := r.ReadAt(trailer, size-8); err != nil {
+		return 0, err
+	}
+	if string(head) != magic || string(trailer[4:]) != magic {
+		return 0, fmt.Errorf("missing parquet magic")
+	}
+
+	footerSize := int64(binary.LittleEndian.Uint32(trailer[:4]))
+	if footerSize > size-int64(len(magic))-8 {
+		return 0, fmt.Errorf("invalid footer size %d", footerSize)
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-8-footerSize); err != nil {
+		return 0, err
+	}
+	metadata, err := (&compactReader{data: footer}).structValue()
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode footer: %v", err)
+	}
+
+	if err := verifySchema(list(metadata, 2), columns); err != nil {
+		return 0, err
+	}
+
+	rows := integer(metadata, 3)
+	var groupRows int64
+	for g, group := range list(metadata, 4) {
+		if err := verifyRowGroup(r, structOf(group), columns, handle); err != nil {
+			return 0, fmt.Errorf("row group %d: %v", g, err)
+		}
+		groupRows += integer(structOf(group), 3)
+	}
+	if groupRows != rows {
+		return 0, fmt.Errorf("row groups contain %d rows, footer says %d", groupRows, rows)
+	}
+	return rows, nil
+}
+
+// verifySchema 比較 SchemaElement 列表與期望的列
+func verifySchema(elements []interface{}, columns []Column) error {
+	type element struct {
+		name      string
+		physical  int64 // -1 表示沒有物理類型
+		repeated  bool
+		children  int64
+		converted int64
+	}
+
+	expected := []element{{name: "schema", physical: -1, children: int64(len(columns)), converted: -1}}
+	for _, column := range columns {
+		leaf := element{name: column.Name, physical: int64(column.Type), converted: int64(column.Converted)}
+		if column.List {
+			expected = append(expected,
+				element{name: column.Name, physical: -1, children: 1, converted: convertedList},
+				element{name: "list", physical: -1, repeated: true, children: 1, converted: -1})
+			leaf.name = "element"
+		}
+		expected = append(expected, leaf)
+	}
+
+	if len(elements) != len(expected) {
+		return fmt.Errorf("schema has %d elements, expected %d", len(elements), len(expected))
+	}
+	for i, value := range elements {
+		fields := structOf(value)
+		actual := element{
+			name:      string(binaryOf(fields, 4)),
+			physical:  optional(fields, 1),
+			repeated:  integer(fields, 3) == repetitionRepeated,
+			children:  integer(fields, 5),
+			converted: optional(fields, 6),
+		}
+		// 根節點的名稱不影響讀取
+		if i == 0 {
+			actual.name = expected[0].name
+		}
+		if actual != expected[i] {
+			return fmt.Errorf("schema element %d is %+v, expected %+v", i, actual, expected[i])
+		}
+	}
+	return nil
+}
+
+// verifyRowGroup 檢查行組的每個列塊，handle 不為空時解碼值並按行調用
+func verifyRowGroup(r io.ReaderAt, group map[int16]interface{}, columns []Column, handle func(values []interface{}) error) error {
+	chunks := list(group, 1)
+	if len(chunks) != len(columns) {
+		return fmt.Errorf("%d column chunks, expected %d", len(chunks), len(columns))
+	}
+
+	rows := integer(group, 3)
+	decoded := make([][]interface{}, len(columns))
+	for i, chunk := range chunks {
+		column := columns[i]
+		meta := structOf(structOf(chunk)[3])
+		if integer(meta, 1) != int64(column.Type) {
+			return fmt.Errorf("column %s has type %d", column.Name, integer(meta, 1))
+		}
+
+		var path []string
+		for _, part := range list(meta, 3) {
+			name, _ := part.([]byte)
+			path = append(path, string(name))
+		}
+		if fmt.Sprint(path) != fmt.Sprint(column.path()) {
+			return fmt.Errorf("column %s has path %v", column.Name, path)
+		}
+
+		offset, length := integer(meta, 9), integer(meta, 7)
+		if offset < int64(len(magic)) || length < 0 || length > maxChunkSize {
+			return fmt.Errorf("column %s has invalid chunk offset %d and size %d", column.Name, offset, length)
+		}
+		data := make([]byte, length)
+		if _, err := r.ReadAt(data, offset); err != nil {
+			return fmt.Errorf("column %s: failed to read chunk: %v", column.Name, err)
+		}
+		chunkRows, values, err := verifyChunk(data, integer(meta, 4), column, handle != nil, &decoded[i])
+		if err != nil {
+			return fmt.Errorf("column %s: %v", column.Name, err)
+		}
+		if chunkRows != rows {
+			return fmt.Errorf("column %s has %d rows, expected %d", column.Name, chunkRows, rows)
+		}
+		if values != integer(meta, 5) {
+			return fmt.Errorf("column %s has %d values, metadata says %d", column.Name, values, integer(meta, 5))
+		}
+	}
+
+	if handle == nil {
+		return nil
+	}
+	for c, column := range columns {
+		if int64(len(decoded[c])) != rows {
+			return fmt.Errorf("column %s decoded %d rows, expected %d", column.Name, len(decoded[c]), rows)
+		}
+	}
+	row := make([]interface{}, len(columns))
+	for i := int64(0); i < rows; i++ {
+		for c := range columns {
+			row[c] = decoded[c][i]
+		}
+		if err := handle(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyChunk 解碼列塊中的所有數據頁，返回行數和層級數量。collect 為 true 時把每行的值追加到 out
+func verifyChunk(data []byte, codec int64, column Column, collect bool, out *[]interface{}) (int64, int64, error) {
+	var rows, values int64
+	for offset := 0; offset < len(data); {
+		reader := &compactReader{data: data[offset:]}
+		header, err := reader.structValue()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to decode page header: %v", err)
+		}
+		offset += reader.pos
+
+		if integer(header, 1) != pageData {
+			return 0, 0, fmt.Errorf("unexpected page type %d", integer(header, 1))
+		}
+		compressedSize := int(integer(header, 3))
+		if compressedSize < 0 || compressedSize > len(data)-offset {
+			return 0, 0, fmt.Errorf("page extends past the column chunk")
+		}
+		page := data[offset : offset+compressedSize]
+		offset += compressedSize
+
+		switch codec {
+		case 0:
+		case codecSnappy:
+			if page, err = snappy.Decode(nil, page); err != nil {
+				return 0, 0, fmt.Errorf("failed to decompress page: %v", err)
+			}
+		default:
+			return 0, 0, fmt.Errorf("unsupported codec %d", codec)
+		}
+		if len(page) != int(integer(header, 2)) {
+			return 0, 0, fmt.Errorf("page decompressed to %d bytes, header says %d", len(page), integer(header, 2))
+		}
+
+		dataHeader := structOf(header[5])
+		if integer(dataHeader, 2) != encodingPlain {
+			return 0, 0, fmt.Errorf("unsupported encoding %d", integer(dataHeader, 2))
+		}
+		count := int(integer(dataHeader, 1))
+		if count < 0 {
+			return 0, 0, fmt.Errorf("invalid value count %d", count)
+		}
+		pageRows, err := verifyPage(page, count, column, collect, out)
+		if err != nil {
+			return 0, 0, err
+		}
+		rows += pageRows
+		values += int64(count)
+	}
+	return rows, values, nil
+}
+
+// verifyPage 解碼層級和 PLAIN 編碼的值，確認頁的內容被完整使用，返回頁中的行數。
+// collect 為 true 時把每行的值追加到 out，列表列的一行為 []string
+func verifyPage(page []byte, count int, column Column, collect bool, out *[]interface{}) (int64, error) {
+	rows, present := int64(count), count
+	var repetitions, definitions []byte
+	if column.List {
+		var rest []byte
+		var err error
+		if repetitions, rest, err = readLevels(page, count); err != nil {
+			return 0, fmt.Errorf("repetition levels: %v", err)
+		}
+		if definitions, rest, err = readLevels(rest, count); err != nil {
+			return 0, fmt.Errorf("definition levels: %v", err)
+		}
+		page = rest
+
+		rows, present = 0, 0
+		for i := range repetitions {
+			if repetitions[i] == 0 {
+				rows++
+			}
+			if definitions[i] == 1 {
+				present++
+			}
+		}
+	}
+
+	var values []interface{}
+	pos := 0
+	for i := 0; i < present; i++ {
+		switch column.Type {
+		case Int32:
+			if collect && pos+4 <= len(page) {
+				values = append(values, int32(binary.LittleEndian.Uint32(page[pos:])))
+			}
+			pos += 4
+		case Int64:
+			if collect && pos+8 <= len(page) {
+				values = append(values, int64(binary.LittleEndian.Uint64(page[pos:])))
+			}
+			pos += 8
+		case ByteArray:
+			if pos+4 > len(page) {
+				return 0, fmt.Errorf("value %d is truncated", i)
+			}
+			length := int(binary.LittleEndian.Uint32(page[pos:]))
+			pos += 4
+			if length > len(page)-pos {
+				return 0, fmt.Errorf("value %d is truncated", i)
+			}
+			if column.Converted == UTF8 && !utf8.Valid(page[pos:pos+length]) {
+				return 0, fmt.Errorf("value %d is not valid UTF-8", i)
+			}
+			if collect {
+				values = append(values, string(page[pos:pos+length]))
+			}
+			pos += length
+		}
+		if pos > len(page) {
+			return 0, fmt.Errorf("value %d is truncated", i)
+		}
+	}
+	if pos != len(page) {
+		return 0, fmt.Errorf("%d bytes left after %d values", len(page)-pos, present)
+	}
+
+	if !collect {
+		return rows, nil
+	}
+	if !column.List {
+		*out = append(*out, values...)
+		return rows, nil
+	}
+	// 重複層級為 0 時開始新的一行，定義層級為 1 時該位置有一個元素
+	var elements []string
+	for i := range repetitions {
+		if repetitions[i] == 0 {
+			if i > 0 {
+				*out = append(*out, elements)
+			}
+			elements = []string{}
+		}
+		if definitions[i] == 1 {
+			elements = append(elements, values[0].(string))
+			values = values[1:]
+		}
+	}
+	if len(repetitions) > 0 {
+		*out = append(*out, elements)
+	}
+	return rows, nil
+}
+
+// readLevels 讀取 4 字節長度加 RLE/bit-packed 混合編碼的層級，最大層級為 1，位寬為 1
+func readLevels(data []byte, count int) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("missing length")
+	}
+	length := int(binary.LittleEndian.Uint32(data))
+	if length > len(data)-4 {
+		return nil, nil, fmt.Errorf("length %d exceeds page", length)
+	}
+	encoded, rest := data[4:4+length], data[4+length:]
+
+	var levels []byte
+	for len(levels) < count {
+		header, n := binary.Uvarint(encoded)
+		if n <= 0 {
+			return nil, nil, fmt.Errorf("truncated run header after %d levels", len(levels))
+		}
+		encoded = encoded[n:]
+
+		if header&1 == 0 {
+			// RLE：重複次數加一個字節的值
+			if len(encoded) < 1 || encoded[0] > 1 || header>>1 > uint64(count-len(levels)) {
+				return nil, nil, fmt.Errorf("invalid RLE run")
+			}
+			for i := uint64(0); i < header>>1; i++ {
+				levels = append(levels, encoded[0])
+			}
+			encoded = encoded[1:]
+			continue
+		}
+
+		// bit-packed：每組 8 個值，位寬為 1 時每組一個字節，低位在前
+		groups := int(header >> 1)
+		if groups > len(encoded) {
+			return nil, nil, fmt.Errorf("truncated bit-packed run")
+		}
+		for _, b := range encoded[:groups] {
+			for bit := 0; bit < 8; bit++ {
+				levels = append(levels, b>>bit&1)
+			}
+		}
+		encoded = encoded[groups:]
+	}
+	if len(levels) > count {
+		levels = levels[:count]
+	}
+	return levels, rest, nil
+}
+
+// 以下按字段 ID 讀取解碼後的結構，字段缺失或類型不符時返回零值
+
+func integer(fields map[int16]interface{}, id int16) int64 {
+	value, _ := fields[id].(int64)
+	return value
+}
+
+// optional 字段缺失時返回 -1
+func optional(fields map[int16]interface{}, id int16) int64 {
+	if value, ok := fields[id].(int64); ok {
+		return value
+	}
+	return -1
+}
+
+func binaryOf(fields map[int16]interface{}, id int16) []byte {
+	value, _ := fields[id].([]byte)
+	return value
+}
+
+func list(fields map[int16]interface{}, id int16) []interface{} {
+	value, _ := fields[id].([]interface{})
+	return value
+}
+
+func structOf(value interface{}) map[int16]interface{} {
+	fields, _ := value.(map[int16]interface{})
+	return fields
+}
@@ -0,0 +1,84 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact 協議的類型
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter 按 Thrift compact 協議編碼 Parquet 的元數據，字段需按 ID 遞增的順序寫入
+type compactWriter struct {
+	buf    []byte
+	lastID int16
+	stack  []int16 // 外層結構的上一個字段 ID
+}
+
+func (c *compactWriter) field(id int16, fieldType byte) {
+	delta := id - c.lastID
+	if delta > 0 && delta <= 15 {
+		c.buf = append(c.buf, byte(delta)<<4|fieldType)
+	} else {
+		c.buf = append(c.buf, fieldType)
+		c.buf = binary.AppendVarint(c.buf, int64(id))
+	}
+	c.lastID = id
+}
+
+func (c *compactWriter) i32(id int16, value int32) {
+	c.field(id, compactI32)
+	c.buf = binary.AppendVarint(c.buf, int64(value))
+}
+
+func (c *compactWriter) i64(id int16, value int64) {
+	c.field(id, compactI64)
+	c.buf = binary.AppendVarint(c.buf, value)
+}
+
+func (c *compactWriter) string(id int16, value string) {
+	c.field(id, compactBinary)
+	c.appendString(value)
+}
+
+// structField 開始一個結構字段，之後以 end 結束
+func (c *compactWriter) structField(id int16) {
+	c.field(id, compactStruct)
+	c.begin()
+}
+
+// list 寫入列表頭，之後依次寫入 size 個元素
+func (c *compactWriter) list(id int16, elementType byte, size int) {
+	c.field(id, compactList)
+	if size < 15 {
+		c.buf = append(c.buf, byte(size)<<4|elementType)
+	} else {
+		c.buf = append(c.buf, 0xf0|elementType)
+		c.buf = binary.AppendUvarint(c.buf, uint64(size))
+	}
+}
+
+func (c *compactWriter) appendI32(value int32) {
+	c.buf = binary.AppendVarint(c.buf, int64(value))
+}
+
+func (c *compactWriter) appendString(value string) {
+	c.buf = binary.AppendUvarint(c.buf, uint64(len(value)))
+	c.buf = append(c.buf, value...)
+}
+
+// begin 開始一個結構，包括頂層結構和列表中的結構
+func (c *compactWriter) begin() {
+	c.stack = append(c.stack, c.lastID)
+	c.lastID = 0
+}
+
+// end 寫入結構結束標記
+func (c *compactWriter) end() {
+	c.buf = append(c.buf, 0)
+	c.lastID = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
@@ -0,0 +1,167 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errTruncated 元數據在結構結束前被截斷
+var errTruncated = errors.New("truncated thrift data")
+
+// compactReader 通用的 Thrift compact 協議解碼器，不依賴寫入端的結構定義。
+// 結構解碼為字段 ID 到值的映射，整數統一為 int64，二進制為 []byte，列表為 []interface{}
+type compactReader struct {
+	data []byte
+	pos  int
+}
+
+func (c *compactReader) readByte() (byte, error) {
+	if c.pos >= len(c.data) {
+		return 0, errTruncated
+	}
+	b := c.data[c.pos]
+	c.pos++
+	return b, nil
+}
+
+func (c *compactReader) uvarint() (uint64, error) {
+	value, n := binary.Uvarint(c.data[c.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	c.pos += n
+	return value, nil
+}
+
+func (c *compactReader) varint() (int64, error) {
+	value, n := binary.Varint(c.data[c.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	c.pos += n
+	return value, nil
+}
+
+func (c *compactReader) structValue() (map[int16]interface{}, error) {
+	fields := make(map[int16]interface{})
+	var lastID int16
+	for {
+		header, err := c.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+
+		fieldType := header & 0x0f
+		id := lastID + int16(header>>4)
+		if header>>4 == 0 {
+			long, err := c.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(long)
+		}
+		lastID = id
+
+		// 字段中的布爾值直接編碼在類型中
+		switch fieldType {
+		case 1:
+			fields[id] = true
+			continue
+		case 2:
+			fields[id] = false
+			continue
+		}
+		if fields[id], err = c.value(fieldType); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *compactReader) value(valueType byte) (interface{}, error) {
+	switch valueType {
+	case 1, 2: // 列表中的布爾值佔一個字節
+		b, err := c.readByte()
+		return b == 1, err
+	case 3:
+		b, err := c.readByte()
+		return int64(int8(b)), err
+	case 4, 5, 6:
+		return c.varint()
+	case 7:
+		if c.pos+8 > len(c.data) {
+			return nil, errTruncated
+		}
+		bits := binary.LittleEndian.Uint64(c.data[c.pos:])
+		c.pos += 8
+		return math.Float64frombits(bits), nil
+	case 8:
+		length, err := c.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(len(c.data)-c.pos) {
+			return nil, errTruncated
+		}
+		value := c.data[c.pos : c.pos+int(length)]
+		c.pos += int(length)
+		return value, nil
+	case 9, 10:
+		return c.listValue()
+	case 11:
+		return c.mapValue()
+	case 12:
+		return c.structValue()
+	}
+	return nil, fmt.Errorf("unknown thrift type %d", valueType)
+}
+
+func (c *compactReader) listValue() ([]interface{}, error) {
+	header, err := c.readByte()
+	if err != nil {
+		return nil, err
+	}
+	size := uint64(header >> 4)
+	if size == 15 {
+		if size, err = c.uvarint(); err != nil {
+			return nil, err
+		}
+	}
+	// 每個元素至少一個字節，避免損壞的長度導致過大的分配
+	if size > uint64(len(c.data)-c.pos) {
+		return nil, errTruncated
+	}
+
+	values := make([]interface{}, size)
+	for i := range values {
+		if values[i], err = c.value(header & 0x0f); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// mapValue Parquet 元數據不使用映射，只解碼以跳過
+func (c *compactReader) mapValue() (interface{}, error) {
+	size, err := c.uvarint()
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	types, err := c.readByte()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < size; i++ {
+		if _, err := c.value(types >> 4); err != nil {
+			return nil, err
+		}
+		if _, err := c.value(types & 0x0f); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,406 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+)
+
+// magic Parquet 文件開頭和結尾的標記
+const magic = "PAR1"
+
+// Type 列的物理類型
+type Type int32
+
+const (
+	Int32     Type = 1
+	Int64     Type = 2
+	ByteArray Type = 6
+)
+
+// ConvertedType 列的邏輯類型，None 表示沒有
+type ConvertedType int32
+
+const (
+	None            ConvertedType = -1
+	UTF8            ConvertedType = 0
+	TimestampMillis ConvertedType = 9
+	Uint64          ConvertedType = 14
+)
+
+// 元數據中使用的枚舉值
+const (
+	repetitionRequired = 0
+	repetitionRepeated = 2
+	convertedList      = 3
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecSnappy        = 1
+	pageData           = 0
+)
+
+// Column 一個頂層列。List 為 true 時是元素不可為空的列表，使用三層的 LIST 結構
+type Column struct {
+	Name      string
+	Type      Type
+	Converted ConvertedType
+	List      bool
+}
+
+func StringColumn(name string) Column {
+	return Column{Name: name, Type: ByteArray, Converted: UTF8}
+}
+
+func StringListColumn(name string) Column {
+	return Column{Name: name, Type: ByteArray, Converted: UTF8, List: true}
+}
+
+func Int32Column(name string) Column {
+	return Column{Name: name, Type: Int32, Converted: None}
+}
+
+func Int64Column(name string) Column {
+	return Column{Name: name, Type: Int64, Converted: None}
+}
+
+func Uint64Column(name string) Column {
+	return Column{Name: name, Type: Int64, Converted: Uint64}
+}
+
+func TimestampColumn(name string) Column {
+	return Column{Name: name, Type: Int64, Converted: TimestampMillis}
+}
+
+// path 列在 schema 中的路徑
+func (c Column) path() []string {
+	if c.List {
+		return []string{c.Name, "list", "element"}
+	}
+	return []string{c.Name}
+}
+
+// columnBuffer 當前行組中一列的數據，值按 PLAIN 編碼
+type columnBuffer struct {
+	values      bytes.Buffer
+	repetitions []byte // 只有列表列使用
+	definitions []byte
+	count       int64 // 層級的數量，即頁中的值數量
+}
+
+// chunkMeta 已寫出的列塊
+type chunkMeta struct {
+	offset           int64
+	values           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type rowGroupMeta struct {
+	chunks []chunkMeta
+	rows   int64
+	bytes  int64
+}
+
+// Writer 逐行寫入 Parquet 文件。數據按行組緩存在內存中，每列每個行組寫一個 snappy 壓縮的數據頁。
+// 只支持必填列和字符串列表，滿足歸檔的需要
+type Writer struct {
+	writer       io.Writer
+	offset       int64
+	columns      []Column
+	buffers      []*columnBuffer
+	rowGroupSize int
+	buffered     int
+	rows         int64
+	rowGroups    []rowGroupMeta
+	createdBy    string
+}
+
+// NewWriter 寫入文件頭，rowGroupSize 為行組的大致字節數
+func NewWriter(w io.Writer, columns []Column, rowGroupSize int, createdBy string) (*Writer, error) {
+	pw := &Writer{
+		writer:       w,
+		columns:      columns,
+		buffers:      make([]*columnBuffer, len(columns)),
+		rowGroupSize: rowGroupSize,
+		createdBy:    createdBy,
+	}
+	for i := range pw.buffers {
+		pw.buffers[i] = &columnBuffer{}
+	}
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// Rows 已寫入的行數
+func (w *Writer) Rows() int64 {
+	return w.rows
+}
+
+// Write 寫入一行，值的順序與列一致，類型為 int32、int64、string 或 []string
+func (w *Writer) Write(values ...interface{}) error {
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("expected %d values, got %d", len(w.columns), len(values))
+	}
+
+	for i, column := range w.columns {
+		if err := w.buffers[i].append(column, values[i]); err != nil {
+			return fmt.Errorf("column %s: %v", column.Name, err)
+		}
+	}
+	w.rows++
+
+	w.buffered = 0
+	for _, buffer := range w.buffers {
+		w.buffered += buffer.values.Len() + len(buffer.definitions)
+	}
+	if w.buffered >= w.rowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// Close 寫出最後一個行組和文件尾，不關閉底層的 writer
+func (w *Writer) Close() error {
+	if err := w.flushRowGroup(); err != nil {
+		return err
+	}
+
+	footer := w.footer()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[:4], uint32(len(footer)))
+	copy(trailer[4:], magic)
+	return w.write(trailer[:])
+}
+
+func (w *Writer) write(data []byte) error {
+	n, err := w.writer.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+func (b *columnBuffer) append(column Column, value interface{}) error {
+	if column.List {
+		elements, ok := value.([]string)
+		if !ok {
+			return fmt.Errorf("expected []string, got %T", value)
+		}
+		// 空列表只寫層級，不寫值
+		if len(elements) == 0 {
+			b.repetitions = append(b.repetitions, 0)
+			b.definitions = append(b.definitions, 0)
+			b.count++
+			return nil
+		}
+		for i, element := range elements {
+			repetition := byte(1)
+			if i == 0 {
+				repetition = 0
+			}
+			b.repetitions = append(b.repetitions, repetition)
+			b.definitions = append(b.definitions, 1)
+			b.appendBytes(element)
+			b.count++
+		}
+		return nil
+	}
+
+	switch column.Type {
+	case Int32:
+		v, ok := value.(int32)
+		if !ok {
+			return fmt.Errorf("expected int32, got %T", value)
+		}
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], uint32(v))
+		b.values.Write(buf[:])
+	case Int64:
+		v, ok := value.(int64)
+		if !ok {
+			return fmt.Errorf("expected int64, got %T", value)
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		b.values.Write(buf[:])
+	case ByteArray:
+		v, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", value)
+		}
+		b.appendBytes(v)
+	default:
+		return fmt.Errorf("unsupported type %d", column.Type)
+	}
+	b.count++
+	return nil
+}
+
+func (b *columnBuffer) appendBytes(value string) {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(value)))
+	b.values.Write(length[:])
+	b.values.WriteString(value)
+}
+
+// flushRowGroup 將緩存的每一列寫成一個數據頁
+func (w *Writer) flushRowGroup() error {
+	groupRows := w.rows
+	for _, group := range w.rowGroups {
+		groupRows -= group.rows
+	}
+	if groupRows == 0 {
+		return nil
+	}
+
+	group := rowGroupMeta{rows: groupRows}
+	for i, column := range w.columns {
+		buffer := w.buffers[i]
+
+		var page []byte
+		if column.List {
+			page = appendLevels(page, buffer.repetitions)
+			page = appendLevels(page, buffer.definitions)
+		}
+		page = append(page, buffer.values.Bytes()...)
+		compressed := snappy.Encode(nil, page)
+
+		header := pageHeader(len(page), len(compressed), buffer.count)
+		chunk := chunkMeta{
+			offset:           w.offset,
+			values:           buffer.count,
+			uncompressedSize: int64(len(header) + len(page)),
+			compressedSize:   int64(len(header) + len(compressed)),
+		}
+		if err := w.write(header); err != nil {
+			return err
+		}
+		if err := w.write(compressed); err != nil {
+			return err
+		}
+
+		group.chunks = append(group.chunks, chunk)
+		group.bytes += chunk.uncompressedSize
+		w.buffers[i] = &columnBuffer{}
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.buffered = 0
+	return nil
+}
+
+// appendLevels 以 4 字節長度加 RLE 編碼寫入層級，層級最大為 1，位寬為 1
+func appendLevels(page []byte, levels []byte) []byte {
+	var encoded []byte
+	for start := 0; start < len(levels); {
+		end := start
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		encoded = binary.AppendUvarint(encoded, uint64(end-start)<<1)
+		encoded = append(encoded, levels[start])
+		start = end
+	}
+	page = binary.LittleEndian.AppendUint32(page, uint32(len(encoded)))
+	return append(page, encoded...)
+}
+
+func pageHeader(uncompressedSize, compressedSize int, values int64) []byte {
+	c := &compactWriter{}
+	c.begin()
+	c.i32(1, pageData)
+	c.i32(2, int32(uncompressedSize))
+	c.i32(3, int32(compressedSize))
+	c.structField(5)
+	c.i32(1, int32(values))
+	c.i32(2, encodingPlain)
+	c.i32(3, encodingRLE)
+	c.i32(4, encodingRLE)
+	c.end()
+	c.end()
+	return c.buf
+}
+
+// footer 編碼 FileMetaData
+func (w *Writer) footer() []byte {
+	c := &compactWriter{}
+	c.begin()
+	c.i32(1, 1)
+
+	elements := 1
+	for _, column := range w.columns {
+		elements += len(column.path())
+	}
+	c.list(2, compactStruct, elements)
+	c.begin()
+	c.string(4, "schema")
+	c.i32(5, int32(len(w.columns)))
+	c.end()
+	for _, column := range w.columns {
+		name := column.Name
+		if column.List {
+			c.begin()
+			c.i32(3, repetitionRequired)
+			c.string(4, column.Name)
+			c.i32(5, 1)
+			c.i32(6, convertedList)
+			c.end()
+
+			c.begin()
+			c.i32(3, repetitionRepeated)
+			c.string(4, "list")
+			c.i32(5, 1)
+			c.end()
+			name = "element"
+		}
+		c.begin()
+		c.i32(1, int32(column.Type))
+		c.i32(3, repetitionRequired)
+		c.string(4, name)
+		if column.Converted != None {
+			c.i32(6, int32(column.Converted))
+		}
+		c.end()
+	}
+
+	c.i64(3, w.rows)
+	c.list(4, compactStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		c.begin()
+		c.list(1, compactStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			column := w.columns[i]
+			c.begin()
+			c.i64(2, chunk.offset)
+			c.structField(3)
+			c.i32(1, int32(column.Type))
+			c.list(2, compactI32, 2)
+			c.appendI32(encodingPlain)
+			c.appendI32(encodingRLE)
+			path := column.path()
+			c.list(3, compactBinary, len(path))
+			for _, part := range path {
+				c.appendString(part)
+			}
+			c.i32(4, codecSnappy)
+			c.i64(5, chunk.values)
+			c.i64(6, chunk.uncompressedSize)
+			c.i64(7, chunk.compressedSize)
+			c.i64(9, chunk.offset)
+			c.end()
+			c.end()
+		}
+		c.i64(2, group.bytes)
+		c.i64(3, group.rows)
+		c.end()
+	}
+	c.string(6, w.createdBy)
+	c.end()
+	return c.buf
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"solana/src/config"
+	"solana/src/monitor"
+	"solana/src/services"
+	"solana/src/utils"
+)
+
+// runRedrive 重新投遞死信文件中的 slot
+func runRedrive(cfg *config.Config, logger *utils.Logger, args []string) {
+	flags := flag.NewFlagSet("redrive", flag.ExitOnError)
+	path := flags.String("dead-letters", cfg.DeadLetterPath, "Path to the dead letter file")
+	flags.Parse(args)
+
+	kafkaConfig, err := config.LoadKafkaConfigFromEnv()
+	if err != nil {
+		logger.Error("Invalid Kafka config: %v", err)
+		os.Exit(1)
+	}
+	// 重新投遞需要逐條確認結果
+	kafkaConfig.Async = false
+
+	// 使用獨立的事務 ID，避免與實時監控互相隔離
+	if kafkaConfig.TransactionalID != "" {
+		kafkaConfig.TransactionalID += "-redrive"
+	}
+
+	// 本地緩存目錄由實時監控使用
+	sinkConfig := *cfg.Sink
+	sinkConfig.SpoolDir = ""
+
+	sink, err := newSink(logger, &sinkConfig, kafkaConfig)
+	if err != nil {
+		logger.Error("Failed to create sink: %v", err)
+		os.Exit(1)
+	}
+	defer sink.Close()
+
+	store := services.NewFileDeadLetterStore(*path)
+	redriven, remaining, err := monitor.RedriveDeadLetters(cfg, sink, store)
+	if err != nil {
+		logger.Error("Redrive failed: %v", err)
+		os.Exit(1)
+	}
+	logger.Info("Redrive complete: %d slots redriven, %d remaining", redriven, remaining)
+}
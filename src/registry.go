@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"solana/src/config"
+	"solana/src/services"
+	"solana/src/utils"
+)
+
+// runRegistry 在本地運行 Confluent 兼容的 schema registry，用於開發和測試 protobuf 格式
+func runRegistry(cfg *config.Config, logger *utils.Logger, args []string) {
+	flags := flag.NewFlagSet("registry", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8081", "Address to listen on")
+	path := flags.String("data", "schema_registry.json", "Path to persist registered schemas, empty to keep them in memory")
+	flags.Parse(args)
+
+	registry, err := services.NewSchemaRegistryServer(*path)
+	if err != nil {
+		logger.Error("Failed to load schema registry: %v", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{Addr: *addr, Handler: registry.Handler()}
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		server.Close()
+	}()
+
+	logger.Info("Schema registry listening on %s", *addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Error("Schema registry error: %v", err)
+		os.Exit(1)
+	}
+}
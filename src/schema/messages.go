@@ -0,0 +1,296 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+
+	"solana/src/models"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MarshalBlock 將區塊消息編碼為 solana.v1.BlockMessage
+func MarshalBlock(m *models.BlockMessage) []byte {
+	var b []byte
+	b = appendUint(b, 1, m.Slot)
+	b = appendString(b, 2, m.Commitment)
+	b = appendUint(b, 3, m.BlockHeight)
+	b = appendOptionalUint(b, 4, m.BlockTime)
+	b = appendString(b, 5, m.Blockhash)
+	b = appendUint(b, 6, m.ParentSlot)
+	b = appendString(b, 7, m.PreviousBlockhash)
+	for i := range m.Transactions {
+		b = appendMessage(b, 8, marshalTransactionInfo(&m.Transactions[i]))
+	}
+	b = appendInt(b, 9, m.Timestamp)
+	return b
+}
+
+// UnmarshalBlock 解碼 solana.v1.BlockMessage
+func UnmarshalBlock(data []byte) (*models.BlockMessage, error) {
+	m := &models.BlockMessage{Transactions: []models.TransactionInfo{}}
+	err := fields(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			m.Slot = v.u
+		case 2:
+			m.Commitment = string(v.bytes)
+		case 3:
+			m.BlockHeight = v.u
+		case 4:
+			blockTime := v.u
+			m.BlockTime = &blockTime
+		case 5:
+			m.Blockhash = string(v.bytes)
+		case 6:
+			m.ParentSlot = v.u
+		case 7:
+			m.PreviousBlockhash = string(v.bytes)
+		case 8:
+			tx, err := unmarshalTransactionInfo(v.bytes)
+			if err != nil {
+				return err
+			}
+			m.Transactions = append(m.Transactions, *tx)
+		case 9:
+			m.Timestamp = int64(v.u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", BlockMessage, err)
+	}
+	return m, nil
+}
+
+// MarshalTransaction 將交易消息編碼為 solana.v1.TransactionMessage
+func MarshalTransaction(m *models.TransactionMessage) []byte {
+	var b []byte
+	b = appendUint(b, 1, m.Slot)
+	b = appendUint(b, 2, m.BlockHeight)
+	b = appendOptionalUint(b, 3, m.BlockTime)
+	b = appendString(b, 4, m.Blockhash)
+	b = appendUint(b, 5, uint64(m.Index))
+	b = appendString(b, 6, m.Commitment)
+	b = appendMessage(b, 7, marshalTransactionInfo(&m.TransactionInfo))
+	b = appendInt(b, 8, m.Timestamp)
+	return b
+}
+
+// UnmarshalTransaction 解碼 solana.v1.TransactionMessage
+func UnmarshalTransaction(data []byte) (*models.TransactionMessage, error) {
+	m := &models.TransactionMessage{}
+	err := fields(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			m.Slot = v.u
+		case 2:
+			m.BlockHeight = v.u
+		case 3:
+			blockTime := v.u
+			m.BlockTime = &blockTime
+		case 4:
+			m.Blockhash = string(v.bytes)
+		case 5:
+			m.Index = int(v.u)
+		case 6:
+			m.Commitment = string(v.bytes)
+		case 7:
+			tx, err := unmarshalTransactionInfo(v.bytes)
+			if err != nil {
+				return err
+			}
+			m.TransactionInfo = *tx
+		case 8:
+			m.Timestamp = int64(v.u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", TransactionMessage, err)
+	}
+	return m, nil
+}
+
+// MarshalEvent 將 slot 事件編碼為 solana.v1.SlotEvent
+func MarshalEvent(e *models.SlotEvent) []byte {
+	var b []byte
+	b = appendString(b, 1, e.Type)
+	b = appendUint(b, 2, e.Slot)
+	b = appendString(b, 3, e.Blockhash)
+	b = appendString(b, 4, e.FinalizedBlockhash)
+	b = appendUint(b, 5, e.ParentSlot)
+	b = appendString(b, 6, e.PreviousBlockhash)
+	b = appendPackedUints(b, 7, e.OrphanedSlots)
+	b = appendString(b, 8, e.Leader)
+	b = appendString(b, 9, e.Commitment)
+	b = appendInt(b, 10, e.Timestamp)
+	return b
+}
+
+// UnmarshalEvent 解碼 solana.v1.SlotEvent
+func UnmarshalEvent(data []byte) (*models.SlotEvent, error) {
+	e := &models.SlotEvent{}
+	err := fields(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			e.Type = string(v.bytes)
+		case 2:
+			e.Slot = v.u
+		case 3:
+			e.Blockhash = string(v.bytes)
+		case 4:
+			e.FinalizedBlockhash = string(v.bytes)
+		case 5:
+			e.ParentSlot = v.u
+		case 6:
+			e.PreviousBlockhash = string(v.bytes)
+		case 7:
+			slots, err := v.uint64s()
+			if err != nil {
+				return err
+			}
+			e.OrphanedSlots = append(e.OrphanedSlots, slots...)
+		case 8:
+			e.Leader = string(v.bytes)
+		case 9:
+			e.Commitment = string(v.bytes)
+		case 10:
+			e.Timestamp = int64(v.u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", SlotEvent, err)
+	}
+	return e, nil
+}
+
+func marshalTransactionInfo(tx *models.TransactionInfo) []byte {
+	var b []byte
+	b = appendString(b, 1, tx.Signature)
+	b = appendString(b, 2, tx.Status)
+	b = appendUint(b, 3, tx.Fee)
+	b = appendStrings(b, 4, tx.AccountKeys)
+	for _, inst := range tx.Instructions {
+		var ib []byte
+		ib = appendString(ib, 1, inst.ProgramId)
+		ib = appendString(ib, 2, inst.Data)
+		ib = appendStrings(ib, 3, inst.Accounts)
+		b = appendMessage(b, 5, ib)
+	}
+	for _, change := range tx.BalanceChanges {
+		var cb []byte
+		cb = appendString(cb, 1, change.Account)
+		cb = appendUint(cb, 2, change.PreBalance)
+		cb = appendUint(cb, 3, change.PostBalance)
+		cb = appendInt(cb, 4, change.Change)
+		b = appendMessage(b, 6, cb)
+	}
+	for _, balance := range tx.TokenBalances {
+		var tb []byte
+		tb = appendUint(tb, 1, balance.AccountIndex)
+		tb = appendString(tb, 2, balance.Mint)
+		tb = appendString(tb, 3, balance.Owner)
+		tb = appendString(tb, 4, balance.ProgramId)
+		tb = appendString(tb, 5, balance.UITokenAmount.Amount)
+		tb = appendUint(tb, 6, uint64(balance.UITokenAmount.Decimals))
+		tb = appendDouble(tb, 7, balance.UITokenAmount.UIAmount)
+		tb = appendString(tb, 8, balance.UITokenAmount.UIAmountString)
+		b = appendMessage(b, 7, tb)
+	}
+	b = appendUint(b, 8, tx.ComputeUnits)
+	b = appendStrings(b, 9, tx.LogMessages)
+	b = appendStrings(b, 10, tx.Programs)
+	return b
+}
+
+func unmarshalTransactionInfo(data []byte) (*models.TransactionInfo, error) {
+	tx := &models.TransactionInfo{}
+	err := fields(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			tx.Signature = string(v.bytes)
+		case 2:
+			tx.Status = string(v.bytes)
+		case 3:
+			tx.Fee = v.u
+		case 4:
+			tx.AccountKeys = append(tx.AccountKeys, string(v.bytes))
+		case 5:
+			var inst models.Instruction
+			err := fields(v.bytes, func(num protowire.Number, v value) error {
+				switch num {
+				case 1:
+					inst.ProgramId = string(v.bytes)
+				case 2:
+					inst.Data = string(v.bytes)
+				case 3:
+					inst.Accounts = append(inst.Accounts, string(v.bytes))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			tx.Instructions = append(tx.Instructions, inst)
+		case 6:
+			var change models.BalanceChange
+			err := fields(v.bytes, func(num protowire.Number, v value) error {
+				switch num {
+				case 1:
+					change.Account = string(v.bytes)
+				case 2:
+					change.PreBalance = v.u
+				case 3:
+					change.PostBalance = v.u
+				case 4:
+					change.Change = int64(v.u)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			tx.BalanceChanges = append(tx.BalanceChanges, change)
+		case 7:
+			var balance models.TokenBalance
+			err := fields(v.bytes, func(num protowire.Number, v value) error {
+				switch num {
+				case 1:
+					balance.AccountIndex = v.u
+				case 2:
+					balance.Mint = string(v.bytes)
+				case 3:
+					balance.Owner = string(v.bytes)
+				case 4:
+					balance.ProgramId = string(v.bytes)
+				case 5:
+					balance.UITokenAmount.Amount = string(v.bytes)
+				case 6:
+					balance.UITokenAmount.Decimals = uint8(v.u)
+				case 7:
+					balance.UITokenAmount.UIAmount = math.Float64frombits(v.u)
+				case 8:
+					balance.UITokenAmount.UIAmountString = string(v.bytes)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			tx.TokenBalances = append(tx.TokenBalances, balance)
+		case 8:
+			tx.ComputeUnits = v.u
+		case 9:
+			tx.LogMessages = append(tx.LogMessages, string(v.bytes))
+		case 10:
+			tx.Programs = append(tx.Programs, string(v.bytes))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
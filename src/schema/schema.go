@@ -0,0 +1,215 @@
+package schema
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Version 當前消息格式的版本，對應 proto 包名 solana.v1
+const Version = "v1"
+
+// Proto 消息定義，註冊到 schema registry
+//
+//go:embed solana/v1/messages.proto
+var Proto string
+
+// 頂層消息的全名，以及在 proto 文件中的位置，用於 Confluent 格式的消息索引
+const (
+	BlockMessage       = "solana.v1.BlockMessage"
+	TransactionMessage = "solana.v1.TransactionMessage"
+	SlotEvent          = "solana.v1.SlotEvent"
+)
+
+var messageIndexes = map[string]int{
+	BlockMessage:       0,
+	TransactionMessage: 1,
+	SlotEvent:          2,
+}
+
+// MessageIndex 返回消息在 proto 文件中的位置
+func MessageIndex(name string) (int, error) {
+	index, ok := messageIndexes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown message %s", name)
+	}
+	return index, nil
+}
+
+// magicByte Confluent 格式消息的第一個字節
+const magicByte = 0
+
+// Frame 按 Confluent 格式包裝 protobuf 消息：magic byte、4 字節 schema ID、消息索引、消息本身
+func Frame(schemaID int, index int, payload []byte) []byte {
+	framed := make([]byte, 5, 5+2+len(payload))
+	framed[0] = magicByte
+	binary.BigEndian.PutUint32(framed[1:], uint32(schemaID))
+	if index == 0 {
+		// 第一個消息簡寫為單個 0
+		framed = append(framed, 0)
+	} else {
+		framed = binary.AppendVarint(framed, 1)
+		framed = binary.AppendVarint(framed, int64(index))
+	}
+	return append(framed, payload...)
+}
+
+// Unframe 拆開 Confluent 格式的消息，返回 schema ID、消息索引和消息本身
+func Unframe(data []byte) (schemaID int, index int, payload []byte, err error) {
+	if len(data) < 6 || data[0] != magicByte {
+		return 0, 0, nil, fmt.Errorf("not a schema registry framed message")
+	}
+	schemaID = int(binary.BigEndian.Uint32(data[1:5]))
+	data = data[5:]
+
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 {
+		return 0, 0, nil, fmt.Errorf("invalid message index")
+	}
+	data = data[n:]
+
+	indexes := make([]int64, count)
+	for i := range indexes {
+		if indexes[i], n = binary.Varint(data); n <= 0 {
+			return 0, 0, nil, fmt.Errorf("invalid message index")
+		}
+		data = data[n:]
+	}
+	// 只支持頂層消息
+	if len(indexes) > 1 {
+		return 0, 0, nil, fmt.Errorf("nested message index %v not supported", indexes)
+	}
+	if len(indexes) == 1 {
+		index = int(indexes[0])
+	}
+	return schemaID, index, data, nil
+}
+
+// value 解碼時的字段值
+type value struct {
+	typ   protowire.Type
+	u     uint64
+	bytes []byte
+}
+
+// fields 依次解碼消息中的字段，未知字段交給 fn 忽略
+func fields(b []byte, fn func(num protowire.Number, v value) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		v := value{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			v.u, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v.u, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var u32 uint32
+			u32, n = protowire.ConsumeFixed32(b)
+			v.u = uint64(u32)
+		case protowire.BytesType:
+			v.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(num, v); err != nil {
+			return fmt.Errorf("field %d: %v", num, err)
+		}
+	}
+	return nil
+}
+
+// uint64s 解碼 packed 或非 packed 的重複 uint64 字段
+func (v value) uint64s() ([]uint64, error) {
+	if v.typ == protowire.VarintType {
+		return []uint64{v.u}, nil
+	}
+
+	var values []uint64
+	b := v.bytes
+	for len(b) > 0 {
+		u, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		values = append(values, u)
+		b = b[n:]
+	}
+	return values, nil
+}
+
+// 編碼時省略零值字段，與 proto3 的默認行為一致
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendStrings(b []byte, num protowire.Number, values []string) []byte {
+	for _, s := range values {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func appendUint(b []byte, num protowire.Number, u uint64) []byte {
+	if u == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, u)
+}
+
+// appendOptionalUint optional 字段只要不為 nil 就寫入，包括 0
+func appendOptionalUint(b []byte, num protowire.Number, u *uint64) []byte {
+	if u == nil {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, *u)
+}
+
+func appendInt(b []byte, num protowire.Number, i int64) []byte {
+	return appendUint(b, num, uint64(i))
+}
+
+func appendDouble(b []byte, num protowire.Number, f float64) []byte {
+	if f == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(f))
+}
+
+func appendPackedUints(b []byte, num protowire.Number, values []uint64) []byte {
+	if len(values) == 0 {
+		return b
+	}
+	var packed []byte
+	for _, u := range values {
+		packed = protowire.AppendVarint(packed, u)
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// SlotRange 閉區間的 slot 範圍
+type SlotRange struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// ArchiveFile 清單中的一個 Parquet 文件
+type ArchiveFile struct {
+	Path       string      `json:"path"` // 相對歸檔目錄的路徑，也是上傳時的 key
+	Dataset    string      `json:"dataset"`
+	Period     string      `json:"period"`
+	FromSlot   uint64      `json:"fromSlot"`
+	ToSlot     uint64      `json:"toSlot"`
+	ParentSlot uint64      `json:"parentSlot,omitempty"` // 第一個區塊的父 slot，只有 blocks 文件有
+	Gaps       []SlotRange `json:"gaps,omitempty"`       // 文件內缺失的區塊，只有 blocks 文件有
+	Rows       int64       `json:"rows"`
+	Bytes      int64       `json:"bytes"`
+	SHA256     string      `json:"sha256"`
+	CreatedAt  int64       `json:"createdAt"`
+	Sequence   uint64      `json:"sequence,omitempty"` // 生成時的暫存序號，文件中的行與之後的數據按此比較
+	Uploaded   bool        `json:"uploaded,omitempty"`
+}
+
+// ArchiveManifest 歸檔目錄中所有 Parquet 文件的清單，每次修改後整體重寫
+type ArchiveManifest struct {
+	path    string
+	files   []ArchiveFile
+	removed []string // 已被替換、等待從對象存儲刪除的路徑
+	mutex   sync.Mutex
+}
+
+// manifestContent manifest.json 的內容
+type manifestContent struct {
+	Files   []ArchiveFile `json:"files"`
+	Removed []string      `json:"removed,omitempty"`
+}
+
+// LoadArchiveManifest 讀取清單，文件不存在時返回空清單
+func LoadArchiveManifest(path string) (*ArchiveManifest, error) {
+	m := &ArchiveManifest{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive manifest: %v", err)
+	}
+
+	var content manifestContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse archive manifest: %v", err)
+	}
+	m.files = content.Files
+	m.removed = content.Removed
+	return m, nil
+}
+
+// Files 返回清單的副本
+func (m *ArchiveManifest) Files() []ArchiveFile {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]ArchiveFile(nil), m.files...)
+}
+
+// Add 加入文件並保存，同一路徑的舊記錄被替換
+func (m *ArchiveManifest) Add(files ...ArchiveFile) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, file := range files {
+		replaced := false
+		for i := range m.files {
+			if m.files[i].Path == file.Path {
+				m.files[i] = file
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			m.files = append(m.files, file)
+		}
+	}
+	return m.save()
+}
+
+// Period 返回時段的所有文件
+func (m *ArchiveManifest) Period(period string) []ArchiveFile {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var files []ArchiveFile
+	for _, file := range m.files {
+		if file.Period == period {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// ReplacePeriod 用 files 替換時段的所有文件並保存，返回路徑不再使用的舊文件。
+// 已上傳的舊文件記入待刪除列表，由 Removed 和 MarkRemoved 處理
+func (m *ArchiveManifest) ReplacePeriod(period string, files []ArchiveFile) ([]ArchiveFile, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	paths := make(map[string]bool, len(files))
+	for _, file := range files {
+		paths[file.Path] = true
+	}
+
+	var kept, removed []ArchiveFile
+	for _, file := range m.files {
+		switch {
+		case file.Period != period:
+			kept = append(kept, file)
+		case !paths[file.Path]:
+			removed = append(removed, file)
+			if file.Uploaded {
+				m.removed = append(m.removed, file.Path)
+			}
+		}
+	}
+	m.files = append(kept, files...)
+
+	// 新文件會覆蓋同一路徑的對象，不能再刪除
+	pending := m.removed[:0]
+	for _, path := range m.removed {
+		if !paths[path] {
+			pending = append(pending, path)
+		}
+	}
+	m.removed = pending
+	return removed, m.save()
+}
+
+// Removed 等待從對象存儲刪除的路徑
+func (m *ArchiveManifest) Removed() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.removed...)
+}
+
+// MarkRemoved 標記對象已刪除並保存
+func (m *ArchiveManifest) MarkRemoved(path string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	pending := m.removed[:0]
+	for _, removed := range m.removed {
+		if removed != path {
+			pending = append(pending, removed)
+		}
+	}
+	m.removed = pending
+	return m.save()
+}
+
+// MarkUploaded 標記文件已上傳並保存
+func (m *ArchiveManifest) MarkUploaded(path string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := range m.files {
+		if m.files[i].Path == path {
+			m.files[i].Uploaded = true
+		}
+	}
+	return m.save()
+}
+
+// Gaps 根據 blocks 文件的 slot 範圍和父 slot 找出缺失的區塊範圍。
+// 範圍內可能包含沒有產出區塊的 slot，需要用 getBlocks 確認後再回填
+func (m *ArchiveManifest) Gaps() []SlotRange {
+	var blocks []ArchiveFile
+	for _, file := range m.Files() {
+		if file.Dataset == ArchiveBlocks {
+			blocks = append(blocks, file)
+		}
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].FromSlot < blocks[j].FromSlot })
+
+	var gaps []SlotRange
+	covered := blocks[0].ToSlot
+	gaps = append(gaps, blocks[0].Gaps...)
+	for _, file := range blocks[1:] {
+		// 父 slot 在已覆蓋範圍之後，說明兩個文件之間有區塊缺失
+		if file.FromSlot > covered && file.ParentSlot > covered {
+			gaps = append(gaps, SlotRange{From: covered + 1, To: file.ParentSlot})
+		}
+		gaps = append(gaps, file.Gaps...)
+		if file.ToSlot > covered {
+			covered = file.ToSlot
+		}
+	}
+
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].From < gaps[j].From })
+	return gaps
+}
+
+// save 先寫入臨時文件再替換，調用前需持有 mutex
+func (m *ArchiveManifest) save() error {
+	data, err := json.MarshalIndent(manifestContent{Files: m.files, Removed: m.removed}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive manifest: %v", err)
+	}
+
+	tmpPath := m.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write archive manifest: %v", err)
+	}
+	if err := os.Rename(tmpPath, m.path); err != nil {
+		return fmt.Errorf("failed to replace archive manifest: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// archiveOrphans 重組或回滾丟棄的 slot 的墓碑，值為記錄時的序號。生成文件時序號更小的數據被跳過，
+// 之後重新發送的數據序號更大，不受影響。墓碑只在重組時產生，數量很少，一直保留在文件中
+type archiveOrphans struct {
+	file  *os.File
+	slots map[uint64]uint64
+}
+
+// openArchiveOrphans 讀取已有的墓碑並以追加方式打開文件
+func openArchiveOrphans(path string) (*archiveOrphans, error) {
+	o := &archiveOrphans{slots: make(map[uint64]uint64)}
+	err := readStaged(path, func(row stagedRow[uint64]) error {
+		if row.Seq > o.slots[row.Row] {
+			o.slots[row.Row] = row.Seq
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o.file, err = openStageFile(path); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// add 記錄墓碑，同步到磁盤後才生效
+func (o *archiveOrphans) add(slots []uint64, sequence uint64) error {
+	var lines []byte
+	for _, slot := range slots {
+		line, err := json.Marshal(stagedRow[uint64]{Seq: sequence, Row: slot})
+		if err != nil {
+			return fmt.Errorf("failed to marshal archive orphan: %v", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if _, err := o.file.Write(lines); err != nil {
+		return fmt.Errorf("failed to write archive orphans: %v", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive orphans: %v", err)
+	}
+
+	for _, slot := range slots {
+		if sequence > o.slots[slot] {
+			o.slots[slot] = sequence
+		}
+	}
+	return nil
+}
+
+// snapshot 返回墓碑的副本，用於在 mutex 外生成文件
+func (o *archiveOrphans) snapshot() map[uint64]uint64 {
+	slots := make(map[uint64]uint64, len(o.slots))
+	for slot, sequence := range o.slots {
+		slots[slot] = sequence
+	}
+	return slots
+}
+
+// since 序號大於 sequence 的墓碑對應的 slot
+func (o *archiveOrphans) since(sequence uint64) []uint64 {
+	var slots []uint64
+	for slot, orphaned := range o.slots {
+		if orphaned > sequence {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+func (o *archiveOrphans) close() error {
+	return o.file.Close()
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"solana/src/parquet"
+)
+
+// archiveRowGroupSize Parquet 行組大小，生成文件時需要在內存中保留一個行組
+const archiveRowGroupSize = 64 * 1024 * 1024
+
+// finalize 將暫存數據與時段已有的文件合併，每個數據集寫成一個 Parquet 文件，更新清單後刪除暫存目錄和舊文件。
+// 同一 slot 寫入過多次時只保留序號最大的數據，已有文件中的行使用文件的序號；
+// 序號小於墓碑的數據屬於被重組或回滾丟棄的 slot，不寫入
+func (s *ArchiveSink) finalize(stage *archiveStage) error {
+	period, partition := s.partition(stage.start)
+
+	s.mutex.Lock()
+	checked := s.nextSequence()
+	orphans := s.orphans.snapshot()
+	s.mutex.Unlock()
+
+	existing := s.manifest.Period(period)
+	if stage.empty() && !orphansIn(existing, orphans) {
+		// 為墓碑重新打開的時段中沒有受影響的 slot，不需要重寫
+		return s.removeStage(stage)
+	}
+	orphaned := func(slot int64, sequence uint64) bool {
+		orphan, ok := orphans[uint64(slot)]
+		return ok && sequence < orphan
+	}
+
+	// 先讀取區塊，得到每個 slot 最後一次寫入的序號
+	latest := make(map[uint64]uint64)
+	blocks := make(map[uint64]ArchiveBlockRow)
+	addBlock := func(row ArchiveBlockRow, sequence uint64) {
+		slot := uint64(row.Slot)
+		if sequence >= latest[slot] {
+			latest[slot] = sequence
+			blocks[slot] = row
+		}
+	}
+	err := s.readArchived(existing, ArchiveBlocks, func(values []interface{}, slot int64, sequence uint64) error {
+		addBlock(archiveBlockRowOf(values), sequence)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = readStaged(stage.path(ArchiveBlocks), func(row stagedRow[ArchiveBlockRow]) error {
+		addBlock(row.Row, row.Seq)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	current := func(slot int64, sequence uint64) bool {
+		if orphaned(slot, sequence) {
+			return false
+		}
+		last, ok := latest[uint64(slot)]
+		return !ok || last == sequence
+	}
+
+	slots := make([]uint64, 0, len(blocks))
+	for slot := range blocks {
+		if current(int64(slot), latest[slot]) {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	var files []ArchiveFile
+	blockFile, ok, err := s.writeParquet(ArchiveBlocks, period, partition, archiveBlockColumns,
+		func(emit func(values []interface{}, slot uint64) error) error {
+			for _, slot := range slots {
+				row := blocks[slot]
+				if err := emit(row.values(), slot); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	if ok {
+		blockFile.ParentSlot = uint64(blocks[slots[0]].ParentSlot)
+		blockFile.Gaps = blockGaps(slots, blocks)
+		files = append(files, blockFile)
+	}
+
+	transactionFile, ok, err := s.writeParquet(ArchiveTransactions, period, partition, archiveTransactionColumns,
+		func(emit func(values []interface{}, slot uint64) error) error {
+			err := s.readArchived(existing, ArchiveTransactions, func(values []interface{}, slot int64, sequence uint64) error {
+				if !current(slot, sequence) {
+					return nil
+				}
+				return emit(values, uint64(slot))
+			})
+			if err != nil {
+				return err
+			}
+			return readStaged(stage.path(ArchiveTransactions), func(row stagedRow[ArchiveTransactionRow]) error {
+				if !current(row.Row.Slot, row.Seq) {
+					return nil
+				}
+				return emit(row.Row.values(), uint64(row.Row.Slot))
+			})
+		})
+	if err != nil {
+		return err
+	}
+	if ok {
+		files = append(files, transactionFile)
+	}
+
+	balanceFile, ok, err := s.writeParquet(ArchiveBalanceChanges, period, partition, archiveBalanceChangeColumns,
+		func(emit func(values []interface{}, slot uint64) error) error {
+			err := s.readArchived(existing, ArchiveBalanceChanges, func(values []interface{}, slot int64, sequence uint64) error {
+				if !current(slot, sequence) {
+					return nil
+				}
+				return emit(values, uint64(slot))
+			})
+			if err != nil {
+				return err
+			}
+			return readStaged(stage.path(ArchiveBalanceChanges), func(row stagedRow[ArchiveBalanceChangeRow]) error {
+				if !current(row.Row.Slot, row.Seq) {
+					return nil
+				}
+				return emit(row.Row.values(), uint64(row.Row.Slot))
+			})
+		})
+	if err != nil {
+		return err
+	}
+	if ok {
+		files = append(files, balanceFile)
+	}
+
+	for i := range files {
+		files[i].Sequence = stage.sealedAt
+	}
+	removed, err := s.manifest.ReplacePeriod(period, files)
+	if err != nil {
+		return err
+	}
+	for _, file := range removed {
+		if err := os.Remove(filepath.Join(s.config.Dir, filepath.FromSlash(file.Path))); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing replaced archive file: %v", err)
+		}
+	}
+	if err := s.removeStage(stage); err != nil {
+		return err
+	}
+
+	// 生成期間收到的墓碑可能落在剛寫入的文件中
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, slot := range s.orphans.since(checked) {
+		s.reopen(slot)
+	}
+	return nil
+}
+
+func (s *ArchiveSink) removeStage(stage *archiveStage) error {
+	if err := os.RemoveAll(stage.dir); err != nil {
+		return fmt.Errorf("failed to remove archive stage: %v", err)
+	}
+	return nil
+}
+
+// readArchived 按行讀取時段已有的某個數據集的文件，sequence 為文件的序號
+func (s *ArchiveSink) readArchived(files []ArchiveFile, dataset string,
+	handle func(values []interface{}, slot int64, sequence uint64) error) error {
+	columns, _ := archiveColumns(dataset)
+	slotColumn := archiveSlotColumn(columns)
+
+	for _, file := range files {
+		if file.Dataset != dataset {
+			continue
+		}
+		if err := s.readArchivedFile(file, columns, func(values []interface{}) error {
+			slot, _ := values[slotColumn].(int64)
+			return handle(values, slot, file.Sequence)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ArchiveSink) readArchivedFile(file ArchiveFile, columns []parquet.Column, handle func(values []interface{}) error) error {
+	f, err := os.Open(filepath.Join(s.config.Dir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return fmt.Errorf("failed to open archived %s: %v", file.Path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := parquet.Read(f, info.Size(), columns, handle); err != nil {
+		return fmt.Errorf("failed to read archived %s: %v", file.Path, err)
+	}
+	return nil
+}
+
+// orphansIn 文件的 slot 範圍內是否有文件生成之後記錄的墓碑
+func orphansIn(files []ArchiveFile, orphans map[uint64]uint64) bool {
+	for slot, sequence := range orphans {
+		for _, file := range files {
+			if slot >= file.FromSlot && slot <= file.ToSlot && sequence > file.Sequence {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeParquet 寫入一個數據集的 Parquet 文件，文件名包含 slot 範圍。沒有數據時不生成文件
+func (s *ArchiveSink) writeParquet(dataset, period, partition string, columns []parquet.Column,
+	rows func(emit func(values []interface{}, slot uint64) error) error) (ArchiveFile, bool, error) {
+	dir := filepath.Join(s.config.Dir, dataset, partition)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("failed to create archive directory: %v", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "."+dataset+"-*.parquet.tmp")
+	if err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("failed to create archive file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	hash := sha256.New()
+	parquetWriter, err := parquet.NewWriter(io.MultiWriter(tmpFile, hash), columns, archiveRowGroupSize, "solana archive")
+	if err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("failed to create %s parquet file: %v", dataset, err)
+	}
+
+	file := ArchiveFile{Dataset: dataset, Period: period}
+	err = rows(func(values []interface{}, slot uint64) error {
+		if file.Rows == 0 || slot < file.FromSlot {
+			file.FromSlot = slot
+		}
+		if slot > file.ToSlot {
+			file.ToSlot = slot
+		}
+		file.Rows++
+		return parquetWriter.Write(values...)
+	})
+	if err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("failed to write %s parquet: %v", dataset, err)
+	}
+	if err := parquetWriter.Close(); err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("failed to finish %s parquet: %v", dataset, err)
+	}
+	if file.Rows == 0 {
+		return ArchiveFile{}, false, nil
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("failed to sync %s parquet: %v", dataset, err)
+	}
+	info, err := tmpFile.Stat()
+	if err != nil {
+		return ArchiveFile{}, false, err
+	}
+
+	// 重新讀取整個文件，格式有誤時不歸檔，保留暫存數據
+	verified, err := parquet.Verify(tmpFile, info.Size(), columns)
+	if err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("%s parquet failed verification: %v", dataset, err)
+	}
+	if verified != file.Rows {
+		return ArchiveFile{}, false, fmt.Errorf("%s parquet has %d rows, wrote %d", dataset, verified, file.Rows)
+	}
+
+	name := fmt.Sprintf("%s-%d-%d.parquet", dataset, file.FromSlot, file.ToSlot)
+	if err := os.Rename(tmpFile.Name(), filepath.Join(dir, name)); err != nil {
+		return ArchiveFile{}, false, fmt.Errorf("failed to rename %s parquet: %v", dataset, err)
+	}
+
+	file.Path = filepath.ToSlash(filepath.Join(dataset, partition, name))
+	file.Bytes = info.Size()
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	file.CreatedAt = time.Now().Unix()
+	return file, true, nil
+}
+
+// VerifyArchiveFile 檢查已歸檔文件的大小、校驗和與 Parquet 格式，dir 為歸檔目錄
+func VerifyArchiveFile(dir string, file ArchiveFile) error {
+	columns, ok := archiveColumns(file.Dataset)
+	if !ok {
+		return fmt.Errorf("unknown dataset %q", file.Dataset)
+	}
+
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", file.Path, err)
+	}
+	if size != file.Bytes {
+		return fmt.Errorf("%s has %d bytes, manifest says %d", file.Path, size, file.Bytes)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != file.SHA256 {
+		return fmt.Errorf("%s has checksum %s, manifest says %s", file.Path, sum, file.SHA256)
+	}
+
+	rows, err := parquet.Verify(f, size, columns)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file.Path, err)
+	}
+	if rows != file.Rows {
+		return fmt.Errorf("%s has %d rows, manifest says %d", file.Path, rows, file.Rows)
+	}
+	return nil
+}
+
+// blockGaps 按父 slot 找出文件內缺失的區塊，slots 需已排序
+func blockGaps(slots []uint64, blocks map[uint64]ArchiveBlockRow) []SlotRange {
+	var gaps []SlotRange
+	for i := 1; i < len(slots); i++ {
+		parent := uint64(blocks[slots[i]].ParentSlot)
+		if parent > slots[i-1] {
+			gaps = append(gaps, SlotRange{From: slots[i-1] + 1, To: parent})
+		}
+	}
+	return gaps
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"solana/src/models"
+	"solana/src/parquet"
+)
+
+// 歸檔的數據集，每個數據集一種 Parquet 文件
+const (
+	ArchiveBlocks         = "blocks"
+	ArchiveTransactions   = "transactions"
+	ArchiveBalanceChanges = "balance_changes"
+)
+
+// 各數據集的 Parquet 列，順序與 values 一致。列名和類型是對外的格式，只能在末尾新增列
+var (
+	archiveBlockColumns = []parquet.Column{
+		parquet.Uint64Column("slot"),
+		parquet.Uint64Column("parent_slot"),
+		parquet.Uint64Column("block_height"),
+		parquet.TimestampColumn("block_time"),
+		parquet.StringColumn("blockhash"),
+		parquet.StringColumn("previous_blockhash"),
+		parquet.StringColumn("commitment"),
+		parquet.Int32Column("transaction_count"),
+	}
+	archiveTransactionColumns = []parquet.Column{
+		parquet.StringColumn("signature"),
+		parquet.Uint64Column("slot"),
+		parquet.TimestampColumn("block_time"),
+		parquet.Int32Column("tx_index"),
+		parquet.StringColumn("status"),
+		parquet.Uint64Column("fee"),
+		parquet.Uint64Column("compute_units"),
+		parquet.StringListColumn("account_keys"),
+		parquet.StringListColumn("programs"),
+		parquet.StringColumn("instructions"),
+		parquet.StringColumn("token_balances"),
+		parquet.StringListColumn("log_messages"),
+	}
+	archiveBalanceChangeColumns = []parquet.Column{
+		parquet.StringColumn("signature"),
+		parquet.Uint64Column("slot"),
+		parquet.TimestampColumn("block_time"),
+		parquet.StringColumn("account"),
+		parquet.Uint64Column("pre_balance"),
+		parquet.Uint64Column("post_balance"),
+		parquet.Int64Column("change"),
+	}
+)
+
+// archiveColumns 返回數據集的列
+func archiveColumns(dataset string) ([]parquet.Column, bool) {
+	switch dataset {
+	case ArchiveBlocks:
+		return archiveBlockColumns, true
+	case ArchiveTransactions:
+		return archiveTransactionColumns, true
+	case ArchiveBalanceChanges:
+		return archiveBalanceChangeColumns, true
+	}
+	return nil, false
+}
+
+// archiveSlotColumn 數據集中 slot 列的位置
+func archiveSlotColumn(columns []parquet.Column) int {
+	for i, column := range columns {
+		if column.Name == "slot" {
+			return i
+		}
+	}
+	return -1
+}
+
+// ArchiveBlockRow blocks 文件的一行
+type ArchiveBlockRow struct {
+	Slot              int64
+	ParentSlot        int64
+	BlockHeight       int64
+	BlockTime         int64
+	Blockhash         string
+	PreviousBlockhash string
+	Commitment        string
+	TransactionCount  int32
+}
+
+// ArchiveTransactionRow transactions 文件的一行，指令和代幣餘額以 JSON 保存
+type ArchiveTransactionRow struct {
+	Signature     string
+	Slot          int64
+	BlockTime     int64
+	Index         int32
+	Status        string
+	Fee           int64
+	ComputeUnits  int64
+	AccountKeys   []string
+	Programs      []string
+	Instructions  string
+	TokenBalances string
+	LogMessages   []string
+}
+
+// ArchiveBalanceChangeRow balance_changes 文件的一行
+type ArchiveBalanceChangeRow struct {
+	Signature   string
+	Slot        int64
+	BlockTime   int64
+	Account     string
+	PreBalance  int64
+	PostBalance int64
+	Change      int64
+}
+
+func (r *ArchiveBlockRow) values() []interface{} {
+	return []interface{}{r.Slot, r.ParentSlot, r.BlockHeight, r.BlockTime, r.Blockhash,
+		r.PreviousBlockhash, r.Commitment, r.TransactionCount}
+}
+
+// archiveBlockRowOf 由 Parquet 讀出的值還原 blocks 的一行，與 values 對應
+func archiveBlockRowOf(values []interface{}) ArchiveBlockRow {
+	var row ArchiveBlockRow
+	row.Slot, _ = values[0].(int64)
+	row.ParentSlot, _ = values[1].(int64)
+	row.BlockHeight, _ = values[2].(int64)
+	row.BlockTime, _ = values[3].(int64)
+	row.Blockhash, _ = values[4].(string)
+	row.PreviousBlockhash, _ = values[5].(string)
+	row.Commitment, _ = values[6].(string)
+	row.TransactionCount, _ = values[7].(int32)
+	return row
+}
+
+func (r *ArchiveTransactionRow) values() []interface{} {
+	return []interface{}{r.Signature, r.Slot, r.BlockTime, r.Index, r.Status, r.Fee, r.ComputeUnits,
+		nonNil(r.AccountKeys), nonNil(r.Programs), r.Instructions, r.TokenBalances, nonNil(r.LogMessages)}
+}
+
+func (r *ArchiveBalanceChangeRow) values() []interface{} {
+	return []interface{}{r.Signature, r.Slot, r.BlockTime, r.Account, r.PreBalance, r.PostBalance, r.Change}
+}
+
+// stagedRow 暫存文件中的一行。Seq 區分同一 slot 的多次寫入，生成 Parquet 時只保留最後一次
+type stagedRow[T any] struct {
+	Seq uint64 `json:"seq"`
+	Row T      `json:"row"`
+}
+
+// archiveTime 用於分區的區塊時間。與 ClickHouse 相同，沒有區塊時間時使用 Unix 零點而不是處理時間，
+// 重新發送同一個 slot 時總是落在同一時段
+func archiveTime(blockTime *uint64) time.Time {
+	if blockTime != nil {
+		return time.Unix(int64(*blockTime), 0).UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+func newArchiveBlockRow(block *models.BlockMessage, blockTime time.Time) ArchiveBlockRow {
+	return ArchiveBlockRow{
+		Slot:              int64(block.Slot),
+		ParentSlot:        int64(block.ParentSlot),
+		BlockHeight:       int64(block.BlockHeight),
+		BlockTime:         blockTime.UnixMilli(),
+		Blockhash:         block.Blockhash,
+		PreviousBlockhash: block.PreviousBlockhash,
+		Commitment:        block.Commitment,
+		TransactionCount:  int32(len(block.Transactions)),
+	}
+}
+
+func newArchiveTransactionRow(transaction *models.TransactionMessage, blockTime time.Time) ArchiveTransactionRow {
+	// 指令和代幣餘額只包含字符串和數字，序列化不會失敗
+	instructions, _ := json.Marshal(nonNil(transaction.Instructions))
+	tokenBalances, _ := json.Marshal(nonNil(transaction.TokenBalances))
+
+	return ArchiveTransactionRow{
+		Signature:     transaction.Signature,
+		Slot:          int64(transaction.Slot),
+		BlockTime:     blockTime.UnixMilli(),
+		Index:         int32(transaction.Index),
+		Status:        transaction.Status,
+		Fee:           int64(transaction.Fee),
+		ComputeUnits:  int64(transaction.ComputeUnits),
+		AccountKeys:   nonNil(transaction.AccountKeys),
+		Programs:      nonNil(transaction.Programs),
+		Instructions:  string(instructions),
+		TokenBalances: string(tokenBalances),
+		LogMessages:   nonNil(transaction.LogMessages),
+	}
+}
+
+func newArchiveBalanceChangeRows(transaction *models.TransactionMessage, blockTime time.Time) []ArchiveBalanceChangeRow {
+	rows := make([]ArchiveBalanceChangeRow, 0, len(transaction.BalanceChanges))
+	for _, change := range transaction.BalanceChanges {
+		rows = append(rows, ArchiveBalanceChangeRow{
+			Signature:   transaction.Signature,
+			Slot:        int64(transaction.Slot),
+			BlockTime:   blockTime.UnixMilli(),
+			Account:     change.Account,
+			PreBalance:  int64(change.PreBalance),
+			PostBalance: int64(change.PostBalance),
+			Change:      change.Change,
+		})
+	}
+	return rows
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveStageLayout 暫存目錄名中時段開始時間的格式
+const archiveStageLayout = "20060102T15"
+
+// archiveStage 一個時段的暫存數據。Parquet 文件只能一次寫完，
+// 時段結束前數據先以 JSON Lines 追加到暫存文件，Flush 時同步到磁盤，進程重啟後繼續追加
+type archiveStage struct {
+	start    time.Time
+	dir      string
+	files    map[string]*os.File
+	writers  map[string]*bufio.Writer
+	sealedAt uint64 // 停止寫入時的序號，暫存中的數據都早於該序號
+}
+
+// openArchiveStage 打開或創建暫存目錄，目錄名為時段開始時間和創建序號
+func openArchiveStage(dir string) (*archiveStage, error) {
+	label, _, ok := strings.Cut(filepath.Base(dir), "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid archive stage %s", dir)
+	}
+	start, err := time.Parse(archiveStageLayout, label)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive stage %s: %v", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive stage: %v", err)
+	}
+
+	stage := &archiveStage{
+		start:   start,
+		dir:     dir,
+		files:   make(map[string]*os.File),
+		writers: make(map[string]*bufio.Writer),
+	}
+	for _, dataset := range []string{ArchiveBlocks, ArchiveTransactions, ArchiveBalanceChanges} {
+		file, err := openStageFile(stage.path(dataset))
+		if err != nil {
+			stage.close()
+			return nil, err
+		}
+		stage.files[dataset] = file
+		stage.writers[dataset] = bufio.NewWriterSize(file, 1<<20)
+	}
+	return stage, nil
+}
+
+func archiveStageName(start time.Time, sequence uint64) string {
+	return fmt.Sprintf("%s-%020d", start.Format(archiveStageLayout), sequence)
+}
+
+// openStageFile 以追加方式打開，上次中斷時留下的不完整行用換行隔開，讀取時跳過
+func openStageFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive stage file: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if last[0] != '\n' {
+			if _, err := file.Write([]byte{'\n'}); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+	}
+	return file, nil
+}
+
+func (st *archiveStage) path(dataset string) string {
+	return filepath.Join(st.dir, dataset+".jsonl")
+}
+
+func (st *archiveStage) write(dataset string, sequence uint64, row interface{}) error {
+	line, err := json.Marshal(stagedRow[interface{}]{Seq: sequence, Row: row})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s row: %v", dataset, err)
+	}
+	if _, err := st.writers[dataset].Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to stage %s row: %v", dataset, err)
+	}
+	return nil
+}
+
+func (st *archiveStage) flush() error {
+	for dataset, writer := range st.writers {
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("failed to flush %s stage: %v", dataset, err)
+		}
+		if err := st.files[dataset].Sync(); err != nil {
+			return fmt.Errorf("failed to sync %s stage: %v", dataset, err)
+		}
+	}
+	return nil
+}
+
+// empty 暫存中沒有任何數據，調用前需已 flush
+func (st *archiveStage) empty() bool {
+	for dataset := range st.files {
+		if info, err := os.Stat(st.path(dataset)); err != nil || info.Size() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (st *archiveStage) close() error {
+	err := st.flush()
+	for _, file := range st.files {
+		file.Close()
+	}
+	return err
+}
+
+// readStaged 依次讀取暫存文件的每一行，文件不存在時不做任何事
+func readStaged[T any](path string, handle func(row stagedRow[T]) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 1<<20)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 1 {
+			var row stagedRow[T]
+			if jsonErr := json.Unmarshal(line, &row); jsonErr != nil {
+				log.Printf("Skipping incomplete line %d in %s: %v", lineNumber, path, jsonErr)
+			} else if err := handle(row); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+	}
+}
+
+// parseStageSequence 暫存目錄名中的創建序號
+func parseStageSequence(name string) uint64 {
+	_, sequence, _ := strings.Cut(name, "-")
+	value, _ := strconv.ParseUint(sequence, 10, 64)
+	return value
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/IBM/sarama"
+)
+
+// 分塊消息的頭部，未帶這些頭部的消息不是分塊消息
+const (
+	chunkHeaderSlot     = "chunk-slot"
+	chunkHeaderIndex    = "chunk-index"
+	chunkHeaderTotal    = "chunk-total"
+	chunkHeaderSize     = "chunk-size"
+	chunkHeaderChecksum = "chunk-checksum"
+)
+
+// ChunkManifest 描述分塊消息中的一塊，同一消息的所有分塊使用相同的 key，保證進入同一分區並保持順序
+type ChunkManifest struct {
+	Slot     uint64
+	Index    int    // 從 0 開始
+	Total    int    // 分塊總數
+	Size     int    // 完整消息的字節數
+	Checksum string // 完整消息的 sha256
+}
+
+func (m ChunkManifest) headers() []sarama.RecordHeader {
+	return []sarama.RecordHeader{
+		{Key: []byte(chunkHeaderSlot), Value: []byte(strconv.FormatUint(m.Slot, 10))},
+		{Key: []byte(chunkHeaderIndex), Value: []byte(strconv.Itoa(m.Index))},
+		{Key: []byte(chunkHeaderTotal), Value: []byte(strconv.Itoa(m.Total))},
+		{Key: []byte(chunkHeaderSize), Value: []byte(strconv.Itoa(m.Size))},
+		{Key: []byte(chunkHeaderChecksum), Value: []byte(m.Checksum)},
+	}
+}
+
+// ParseChunkManifest 從消息頭部讀取分塊信息，不是分塊消息時返回 nil
+func ParseChunkManifest(headers []*sarama.RecordHeader) (*ChunkManifest, error) {
+	values := make(map[string]string, len(headers))
+	for _, header := range headers {
+		values[string(header.Key)] = string(header.Value)
+	}
+	if _, ok := values[chunkHeaderTotal]; !ok {
+		return nil, nil
+	}
+
+	manifest := &ChunkManifest{Checksum: values[chunkHeaderChecksum]}
+	var err error
+	if manifest.Slot, err = strconv.ParseUint(values[chunkHeaderSlot], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", chunkHeaderSlot, err)
+	}
+	if manifest.Index, err = strconv.Atoi(values[chunkHeaderIndex]); err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", chunkHeaderIndex, err)
+	}
+	if manifest.Total, err = strconv.Atoi(values[chunkHeaderTotal]); err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", chunkHeaderTotal, err)
+	}
+	if manifest.Size, err = strconv.Atoi(values[chunkHeaderSize]); err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", chunkHeaderSize, err)
+	}
+	if manifest.Index < 0 || manifest.Index >= manifest.Total {
+		return nil, fmt.Errorf("chunk index %d out of range (total %d)", manifest.Index, manifest.Total)
+	}
+	return manifest, nil
+}
+
+// splitMessage 將超過 limit 的消息拆分為多塊，其餘字段與原消息相同；未超過時原樣返回
+func splitMessage(msg *sarama.ProducerMessage, slot uint64, limit int) []*sarama.ProducerMessage {
+	value, ok := msg.Value.(sarama.ByteEncoder)
+	if !ok || limit <= 0 || len(value) <= limit {
+		return []*sarama.ProducerMessage{msg}
+	}
+
+	sum := sha256.Sum256(value)
+	manifest := ChunkManifest{
+		Slot:     slot,
+		Total:    (len(value) + limit - 1) / limit,
+		Size:     len(value),
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+
+	chunks := make([]*sarama.ProducerMessage, 0, manifest.Total)
+	for offset := 0; offset < len(value); offset += limit {
+		end := min(offset+limit, len(value))
+		chunk := *msg
+		chunk.Value = value[offset:end]
+		chunk.Headers = append(append([]sarama.RecordHeader{}, msg.Headers...), manifest.headers()...)
+		chunks = append(chunks, &chunk)
+		manifest.Index++
+	}
+	return chunks
+}
+
+// partialMessage 等待其餘分塊的消息
+type partialMessage struct {
+	manifest ChunkManifest
+	chunks   [][]byte
+	received int
+}
+
+// ChunkAssembler 供消費者把分塊消息還原為完整消息，最多同時保留 maxPending 個未收齊的消息，
+// 超出時丟棄最早的一個（例如從中間的 offset 開始消費時收不到前面的分塊）
+type ChunkAssembler struct {
+	pending    map[string]*partialMessage
+	order      []string
+	maxPending int
+}
+
+func NewChunkAssembler(maxPending int) *ChunkAssembler {
+	if maxPending <= 0 {
+		maxPending = 16
+	}
+	return &ChunkAssembler{pending: make(map[string]*partialMessage), maxPending: maxPending}
+}
+
+// Add 加入一條消息，消息完整時返回完整內容，分塊未收齊時返回 nil。不是分塊的消息原樣返回
+func (a *ChunkAssembler) Add(headers []*sarama.RecordHeader, value []byte) ([]byte, error) {
+	manifest, err := ParseChunkManifest(headers)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return value, nil
+	}
+
+	id := fmt.Sprintf("%d/%s", manifest.Slot, manifest.Checksum)
+	partial, ok := a.pending[id]
+	if !ok {
+		partial = &partialMessage{manifest: *manifest, chunks: make([][]byte, manifest.Total)}
+		a.pending[id] = partial
+		a.order = append(a.order, id)
+		a.evict()
+	}
+	if manifest.Total != partial.manifest.Total {
+		return nil, fmt.Errorf("chunk total mismatch for slot %d: %d != %d", manifest.Slot, manifest.Total, partial.manifest.Total)
+	}
+
+	// 重複投遞的分塊直接覆蓋
+	if partial.chunks[manifest.Index] == nil {
+		partial.received++
+	}
+	partial.chunks[manifest.Index] = append([]byte(nil), value...)
+	if partial.received < partial.manifest.Total {
+		return nil, nil
+	}
+
+	a.remove(id)
+	complete := make([]byte, 0, partial.manifest.Size)
+	for _, chunk := range partial.chunks {
+		complete = append(complete, chunk...)
+	}
+
+	sum := sha256.Sum256(complete)
+	if hex.EncodeToString(sum[:]) != partial.manifest.Checksum {
+		return nil, fmt.Errorf("checksum mismatch for slot %d", partial.manifest.Slot)
+	}
+	return complete, nil
+}
+
+// Pending 未收齊的消息數量
+func (a *ChunkAssembler) Pending() int {
+	return len(a.pending)
+}
+
+func (a *ChunkAssembler) evict() {
+	for len(a.order) > a.maxPending {
+		delete(a.pending, a.order[0])
+		a.order = a.order[1:]
+	}
+}
+
+func (a *ChunkAssembler) remove(id string) {
+	delete(a.pending, id)
+	for i, pendingID := range a.order {
+		if pendingID == id {
+			a.order = append(a.order[:i], a.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+// chunkRecord 模擬消費者收到的一條分塊消息
+type chunkRecord struct {
+	headers []*sarama.RecordHeader
+	value   []byte
+}
+
+func splitRecords(t *testing.T, value []byte, slot uint64, limit int) []chunkRecord {
+	t.Helper()
+	msg := &sarama.ProducerMessage{Topic: "blocks", Value: sarama.ByteEncoder(value)}
+	var records []chunkRecord
+	for _, chunk := range splitMessage(msg, slot, limit) {
+		headers := make([]*sarama.RecordHeader, len(chunk.Headers))
+		for i := range chunk.Headers {
+			headers[i] = &chunk.Headers[i]
+		}
+		records = append(records, chunkRecord{headers: headers, value: chunk.Value.(sarama.ByteEncoder)})
+	}
+	return records
+}
+
+func TestChunkAssembler(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 10)
+
+	tests := []struct {
+		name    string
+		limit   int
+		order   []int // 投遞順序，為空時按原順序
+		corrupt int   // 篡改該序號的分塊，-1 表示不篡改
+		chunks  int
+		wantErr string
+	}{
+		{name: "not chunked", limit: 1000, corrupt: -1, chunks: 1},
+		{name: "in order", limit: 30, corrupt: -1, chunks: 4},
+		{name: "reversed", limit: 30, order: []int{3, 2, 1, 0}, corrupt: -1, chunks: 4},
+		{name: "shuffled", limit: 25, order: []int{2, 0, 3, 1}, corrupt: -1, chunks: 4},
+		{name: "duplicate delivery", limit: 30, order: []int{1, 1, 0, 3, 2}, corrupt: -1, chunks: 4},
+		{name: "checksum mismatch", limit: 30, order: []int{1, 0, 3, 2}, corrupt: 2, chunks: 4, wantErr: "checksum mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := splitRecords(t, payload, 42, tt.limit)
+			if len(records) != tt.chunks {
+				t.Fatalf("split into %d chunks, want %d", len(records), tt.chunks)
+			}
+			if tt.corrupt >= 0 {
+				records[tt.corrupt].value = append([]byte("x"), records[tt.corrupt].value[1:]...)
+			}
+			order := tt.order
+			if order == nil {
+				for i := range records {
+					order = append(order, i)
+				}
+			}
+
+			assembler := NewChunkAssembler(4)
+			var got []byte
+			var err error
+			for i, index := range order {
+				got, err = assembler.Add(records[index].headers, records[index].value)
+				if i < len(order)-1 && (got != nil || err != nil) {
+					t.Fatalf("chunk %d: got %d bytes, err %v before all chunks arrived", index, len(got), err)
+				}
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Errorf("reassembled %q, want %q", got, payload)
+				}
+			}
+			if assembler.Pending() != 0 {
+				t.Errorf("pending = %d, want 0", assembler.Pending())
+			}
+		})
+	}
+}
+
+func TestChunkAssemblerEvictsOldest(t *testing.T) {
+	assembler := NewChunkAssembler(2)
+	for slot := uint64(1); slot <= 3; slot++ {
+		records := splitRecords(t, bytes.Repeat([]byte{byte(slot)}, 20), slot, 10)
+		if _, err := assembler.Add(records[0].headers, records[0].value); err != nil {
+			t.Fatalf("slot %d: %v", slot, err)
+		}
+	}
+	if assembler.Pending() != 2 {
+		t.Fatalf("pending = %d, want 2", assembler.Pending())
+	}
+
+	// slot 1 已被淘汰，收到剩餘分塊後仍不完整
+	records := splitRecords(t, bytes.Repeat([]byte{1}, 20), 1, 10)
+	got, err := assembler.Add(records[1].headers, records[1].value)
+	if got != nil || err != nil {
+		t.Errorf("evicted message reassembled: %d bytes, err %v", len(got), err)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// migrateClickHouse 依次執行尚未執行的遷移。ClickHouse 沒有事務，
+// 遷移語句需要能重複執行，中途失敗時下次啟動會從頭重新執行該文件
+func migrateClickHouse(ctx context.Context, conn driver.Conn) error {
+	migrations, err := loadMigrations("clickhouse")
+	if err != nil {
+		return err
+	}
+
+	err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    UInt32,
+		applied_at DateTime DEFAULT now()
+	) ENGINE = MergeTree ORDER BY version`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %v", err)
+	}
+
+	for _, migration := range migrations {
+		var count uint64
+		if err := conn.QueryRow(ctx, "SELECT count() FROM schema_migrations WHERE version = ?", uint32(migration.version)).Scan(&count); err != nil {
+			return fmt.Errorf("failed to check migration %s: %v", migration.name, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		// 原生協議每次只能執行一條語句
+		for _, statement := range splitStatements(migration.script) {
+			if err := conn.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("migration %s failed: %v", migration.name, err)
+			}
+		}
+		if err := conn.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", uint32(migration.version)); err != nil {
+			return fmt.Errorf("failed to record migration %s: %v", migration.name, err)
+		}
+		log.Printf("Applied ClickHouse migration %s", migration.name)
+	}
+	return nil
+}
+
+// splitStatements 按分號拆分語句，去掉註釋行和空語句
+func splitStatements(script string) []string {
+	var statements []string
+	for _, part := range strings.Split(script, ";") {
+		lines := make([]string, 0)
+		for _, line := range strings.Split(part, "\n") {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			statements = append(statements, strings.Join(lines, "\n"))
+		}
+	}
+	return statements
+}
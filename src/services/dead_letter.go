@@ -0,0 +1,172 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"solana/src/models"
+)
+
+// DeadLetterStore 持久化永久失敗的 slot
+type DeadLetterStore interface {
+	Append(letter *models.DeadLetter) error
+	// Load 讀取全部死信，沒有時返回空列表
+	Load() ([]*models.DeadLetter, error)
+	// Replace 以給定的死信覆蓋現有內容，用於重新投遞後移除成功的項目
+	Replace(letters []*models.DeadLetter) error
+}
+
+// FileDeadLetterStore 以 JSON Lines 格式保存死信，每條追加後立即刷盤
+type FileDeadLetterStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileDeadLetterStore(path string) *FileDeadLetterStore {
+	return &FileDeadLetterStore{path: path}
+}
+
+func (s *FileDeadLetterStore) Append(letter *models.DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create dead letter directory: %v", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open dead letter file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead letter: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dead letter file: %v", err)
+	}
+	return nil
+}
+
+func (s *FileDeadLetterStore) Load() ([]*models.DeadLetter, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead letter file: %v", err)
+	}
+	defer file.Close()
+
+	letters := make([]*models.DeadLetter, 0)
+	scanner := bufio.NewScanner(file)
+	// 原始響應可能很大
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter models.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("failed to parse dead letter at line %d: %v", line, err)
+		}
+		letters = append(letters, &letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead letter file: %v", err)
+	}
+	return letters, nil
+}
+
+func (s *FileDeadLetterStore) Replace(letters []*models.DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tmpPath := s.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open dead letter file: %v", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, letter := range letters {
+		data, err := json.Marshal(letter)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to marshal dead letter: %v", err)
+		}
+		writer.Write(data)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write dead letters: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync dead letter file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close dead letter file: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace dead letter file: %v", err)
+	}
+	return nil
+}
+
+// NewDeadLetter 根據錯誤構建死信，記錄完整的錯誤鏈與最後一次的原始響應
+func NewDeadLetter(slot uint64, reason string, err error, attempts []models.DeadLetterAttempt) *models.DeadLetter {
+	now := time.Now().Unix()
+	letter := &models.DeadLetter{
+		Slot:          slot,
+		Reason:        reason,
+		ErrorChain:    make([]string, 0),
+		Attempts:      attempts,
+		FirstFailedAt: now,
+		FailedAt:      now,
+	}
+	if len(attempts) > 0 {
+		letter.FirstFailedAt = attempts[0].Time
+	}
+
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		letter.ErrorChain = append(letter.ErrorChain, e.Error())
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && len(rpcErr.Raw) > 0 {
+		letter.RawResponse = string(rpcErr.Raw)
+	}
+	return letter
+}
+
+// NewAttempt 將一次失敗的請求轉換為嘗試記錄
+func NewAttempt(attempt int, err error) models.DeadLetterAttempt {
+	record := models.DeadLetterAttempt{
+		Attempt: attempt,
+		Error:   err.Error(),
+		Time:    time.Now().Unix(),
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		record.Endpoint = rpcErr.Endpoint
+	}
+	return record
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RPC 錯誤類別，可配合 errors.Is 判斷
+var (
+	ErrSlotSkipped       = errors.New("slot skipped")
+	ErrBlockNotAvailable = errors.New("block not available yet")
+	ErrLongTermStorage   = errors.New("block missing in long-term storage")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrNodeBehind        = errors.New("node is behind")
+	ErrTransport         = errors.New("transport error")
+)
+
+// Solana JSON-RPC 錯誤碼
+const (
+	codeBlockCleanedUp             = -32001
+	codeBlockNotAvailable          = -32004
+	codeNodeUnhealthy              = -32005
+	codeSlotSkipped                = -32007
+	codeLongTermStorageSlotSkipped = -32009
+	codeBlockStatusNotAvailableYet = -32014
+	codeMinContextSlotNotReached   = -32016
+	codeTooManyRequests            = 429
+)
+
+// RPCError 所有 SolanaClient 方法返回的錯誤
+type RPCError struct {
+	Kind       error // 錯誤類別，無法歸類時為 nil
+	Code       int
+	Message    string
+	Method     string
+	Endpoint   string
+	RetryAfter time.Duration
+	Raw        []byte // 原始響應內容
+	Err        error  // 底層錯誤
+	// AllEndpoints 所有端點都返回了同一類錯誤，只對長期存儲錯誤設置，
+	// 此時才能認為區塊確實不存在，而不是某個節點已清理了該區塊
+	AllEndpoints bool
+}
+
+func (e *RPCError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Method)
+	if e.Endpoint != "" {
+		sb.WriteString(fmt.Sprintf(" via %s", e.Endpoint))
+	}
+	if e.Kind != nil {
+		sb.WriteString(fmt.Sprintf(": %v", e.Kind))
+	}
+	if e.Code != 0 {
+		sb.WriteString(fmt.Sprintf(" (code %d)", e.Code))
+	}
+	if e.Message != "" {
+		sb.WriteString(fmt.Sprintf(": %s", e.Message))
+	}
+	if e.Err != nil {
+		sb.WriteString(fmt.Sprintf(": %v", e.Err))
+	}
+	return sb.String()
+}
+
+func (e *RPCError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable 表示換一個端點重試可能成功。區塊已被清理或不在長期存儲中時，
+// 其他保留更多歷史的節點可能仍有該區塊
+func (e *RPCError) Retryable() bool {
+	return e.Kind == ErrTransport || e.Kind == ErrRateLimited || e.Kind == ErrNodeBehind ||
+		e.Kind == ErrLongTermStorage
+}
+
+// RetryAfter 返回錯誤建議的等待時間，沒有則為 0
+func RetryAfter(err error) time.Duration {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.RetryAfter
+	}
+	return 0
+}
+
+// classifyCode 將 JSON-RPC 錯誤碼映射到錯誤類別
+func classifyCode(code int) error {
+	switch code {
+	case codeSlotSkipped:
+		return ErrSlotSkipped
+	case codeBlockNotAvailable, codeBlockStatusNotAvailableYet:
+		return ErrBlockNotAvailable
+	case codeLongTermStorageSlotSkipped, codeBlockCleanedUp:
+		return ErrLongTermStorage
+	case codeNodeUnhealthy, codeMinContextSlotNotReached:
+		return ErrNodeBehind
+	case codeTooManyRequests, -codeTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// parseRetryAfter 解析 Retry-After 標頭，支援秒數與 HTTP 日期兩種格式
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := time.Parse(time.RFC1123, value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -2,7 +2,11 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"solana/src/config"
@@ -11,50 +15,517 @@ import (
 	"github.com/IBM/sarama"
 )
 
+// DeliveryTracker 接收異步模式下的消息確認，用於只在 Kafka 確認後推進進度
+type DeliveryTracker interface {
+	// Sent 消息進入發送隊列時調用
+	Sent(slot uint64)
+	// Delivered 消息被確認或最終發送失敗時調用
+	Delivered(slot uint64, err error)
+}
+
+// deliveryMeta 隨消息傳遞，用於在確認時找到對應的 slot
+type deliveryMeta struct {
+	slot       uint64
+	tracked    bool
+	generation uint64 // 進入隊列時的 Flush 代數
+}
+
+// ErrMessageTooLarge 消息超過 Producer.MaxMessageBytes 或 broker 的限制，重試也不會成功
+var ErrMessageTooLarge = errors.New("message too large")
+
+// sendError 包裝發送錯誤，消息過大時可用 errors.Is 判斷
+func sendError(context string, err error) error {
+	if messageTooLarge(err) {
+		return fmt.Errorf("%s: %w: %v", context, ErrMessageTooLarge, err)
+	}
+	return fmt.Errorf("%s: %v", context, err)
+}
+
+func messageTooLarge(err error) bool {
+	var producerErrs sarama.ProducerErrors
+	if errors.As(err, &producerErrs) {
+		for _, producerErr := range producerErrs {
+			if messageTooLarge(producerErr.Err) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var configErr sarama.ConfigurationError
+	if errors.As(err, &configErr) {
+		return strings.Contains(string(configErr), "larger than configured")
+	}
+	return errors.Is(err, sarama.ErrMessageSizeTooLarge)
+}
+
 type KafkaProducer struct {
-	producer sarama.SyncProducer
-	Topic    string
+	producer         sarama.SyncProducer
+	async            sarama.AsyncProducer // 異步模式下使用，此時 producer 為 nil
+	Topic            string
+	TransactionTopic string // 單筆交易消息的 topic
+	EventTopic       string
+	DeadLetterTopic  string          // 為空時死信只寫入本地文件
+	CheckpointTopic  string          // 事務模式下保存進度的壓縮 topic
+	Tracker          DeliveryTracker // 異步模式下接收消息確認
+	brokers          []string
+	config           *config.KafkaConfig
+	router           *TopicRouter
+	serializer       Serializer
+	txnMutex         sync.Mutex // 同一時間只能有一個事務
+	wg               sync.WaitGroup
+	// 異步模式下按 Flush 代數統計尚未確認的消息，Flush 等待之前代數的消息全部確認
+	generation  uint64
+	inFlight    map[uint64]int
+	deliveryErr error // 上次 Flush 之後第一個發送失敗
+	flushMutex  sync.Mutex
+	flushCond   *sync.Cond
 }
 
 func NewKafkaProducer(config *config.KafkaConfig, brokers []string) (*KafkaProducer, error) {
+	if config.Async {
+		producer, err := sarama.NewAsyncProducer(brokers, config.ToSaramaConfig())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create async producer: %v", err)
+		}
+
+		kp := &KafkaProducer{async: producer, brokers: brokers, config: config, router: NewTopicRouter(config.Routes), serializer: NewSerializer(config)}
+		kp.inFlight = make(map[uint64]int)
+		kp.flushCond = sync.NewCond(&kp.flushMutex)
+		kp.handleDeliveries()
+		return kp, nil
+	}
+
 	producer, err := sarama.NewSyncProducer(brokers, config.ToSaramaConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create producer: %v", err)
 	}
 
 	return &KafkaProducer{
-		producer: producer,
+		producer:   producer,
+		brokers:    brokers,
+		config:     config,
+		router:     NewTopicRouter(config.Routes),
+		serializer: NewSerializer(config),
 		// 可以將 topic 設為 KafkaProducer 結構的屬性，並在這裡初始化
 	}, nil
 }
 
-func (kp *KafkaProducer) SendBlockMessage(block *models.BlockResponse) error {
-	message := convertToBlockMessage(block)
-	value, err := json.Marshal(message)
+// Transactional 是否為事務模式，此時區塊消息只在事務提交後對 read_committed 的消費者可見
+func (kp *KafkaProducer) Transactional() bool {
+	return kp.producer != nil && kp.producer.IsTransactional()
+}
+
+// Async 是否為異步模式，異步模式下發送方法只表示消息已進入隊列
+func (kp *KafkaProducer) Async() bool {
+	return kp.async != nil
+}
+
+// PublishBlock 按 PublishMode 發送區塊消息和/或每筆交易的消息
+func (kp *KafkaProducer) PublishBlock(message *models.BlockMessage) error {
+	if kp.Transactional() {
+		return kp.PublishBlockWithCheckpoint(message, nil)
+	}
+
+	msgs, err := kp.blockMessages(message)
 	if err != nil {
-		return fmt.Errorf("failed to marshal block message: %v", err)
+		return err
 	}
 
-	msg := &sarama.ProducerMessage{
-		Topic: kp.Topic,
-		Key:   sarama.StringEncoder(fmt.Sprintf("%d", message.BlockHeight)),
-		Value: sarama.ByteEncoder(value),
+	if kp.async != nil {
+		for _, msg := range msgs {
+			kp.enqueue(msg)
+		}
+		return nil
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := kp.producer.SendMessages(msgs); err != nil {
+		return sendError("failed to send message", err)
+	}
+
+	fmt.Printf("Slot %d: %d message(s) sent, first to partition %d at offset %d\n", message.Slot, len(msgs), msgs[0].Partition, msgs[0].Offset)
+	return nil
+}
+
+// blockMessages 構造一個區塊需要發送的所有消息
+func (kp *KafkaProducer) blockMessages(message *models.BlockMessage) ([]*sarama.ProducerMessage, error) {
+	msgs := make([]*sarama.ProducerMessage, 0, 1)
+
+	if kp.config.PublishesBlocks() {
+		value, err := kp.serializer.Block(kp.Topic, message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal block message: %v", err)
+		}
+
+		msgs = append(msgs, kp.chunk(&sarama.ProducerMessage{
+			Topic:    kp.Topic,
+			Key:      sarama.StringEncoder(fmt.Sprintf("%d", message.BlockHeight)),
+			Value:    sarama.ByteEncoder(value),
+			Metadata: deliveryMeta{slot: message.Slot, tracked: true},
+		}, message.Slot)...)
+	}
+
+	if kp.config.PublishesTransactions() {
+		for i := range message.Transactions {
+			txMsgs, err := kp.transactionMessages(NewTransactionMessage(message, i))
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, txMsgs...)
+		}
 	}
 
-	partition, offset, err := kp.producer.SendMessage(msg)
+	return msgs, nil
+}
+
+// transactionMessages 每筆交易都發送到默認 topic，再按調用的程序分發到路由的 topic
+func (kp *KafkaProducer) transactionMessages(transaction *models.TransactionMessage) ([]*sarama.ProducerMessage, error) {
+	var msgs []*sarama.ProducerMessage
+	topics := append([]string{kp.TransactionTopic}, kp.router.Topics(&transaction.TransactionInfo)...)
+	for _, topic := range topics {
+		// 帶 schema ID 時各 topic 的消息頭不同，需要分別編碼
+		value, err := kp.serializer.Transaction(topic, transaction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal transaction message: %v", err)
+		}
+
+		msgs = append(msgs, kp.chunk(&sarama.ProducerMessage{
+			Topic:    topic,
+			Key:      sarama.StringEncoder(transaction.Signature),
+			Value:    sarama.ByteEncoder(value),
+			Metadata: deliveryMeta{slot: transaction.Slot, tracked: true},
+		}, transaction.Slot)...)
+	}
+	return msgs, nil
+}
+
+// PublishTransaction 單獨發送一筆交易，不受 PublishMode 影響
+func (kp *KafkaProducer) PublishTransaction(transaction *models.TransactionMessage) error {
+	msgs, err := kp.transactionMessages(transaction)
+	if err != nil {
+		return err
+	}
+
+	if kp.async != nil {
+		for _, msg := range msgs {
+			kp.enqueue(msg)
+		}
+		return nil
+	}
+
+	if kp.Transactional() {
+		return kp.transaction(func() ([]*sarama.ProducerMessage, error) { return msgs, nil })
+	}
+	if err := kp.producer.SendMessages(msgs); err != nil {
+		return sendError("failed to send transaction message", err)
+	}
+	return nil
+}
+
+// chunk 超過 ChunkLimit 的消息拆分為多塊發送，消費者用 ChunkAssembler 還原
+func (kp *KafkaProducer) chunk(msg *sarama.ProducerMessage, slot uint64) []*sarama.ProducerMessage {
+	chunks := splitMessage(msg, slot, kp.config.ChunkLimit())
+	if len(chunks) > 1 {
+		log.Printf("Slot %d: %d byte message to %s split into %d chunks", slot, msg.Value.Length(), msg.Topic, len(chunks))
+	}
+	return chunks
+}
+
+// PublishBlockWithCheckpoint 在同一事務中發送區塊與進度，snapshot 在取得事務後調用，
+// 保證寫入的進度包含之前提交的所有事務，為 nil 時只發送區塊
+func (kp *KafkaProducer) PublishBlockWithCheckpoint(message *models.BlockMessage, snapshot func() *models.Checkpoint) error {
+	msgs, err := kp.blockMessages(message)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		return err
 	}
 
-	fmt.Printf("Message sent to partition %d at offset %d\n", partition, offset)
+	return kp.transactionWithCheckpoint(msgs, snapshot)
+}
+
+// PublishEventWithCheckpoint 在同一事務中發送 slot 事件與進度，用於跳過的 slot
+func (kp *KafkaProducer) PublishEventWithCheckpoint(event *models.SlotEvent, snapshot func() *models.Checkpoint) error {
+	msg, err := kp.eventMessage(event)
+	if err != nil {
+		return err
+	}
+	if err := kp.transactionWithCheckpoint([]*sarama.ProducerMessage{msg}, snapshot); err != nil {
+		return fmt.Errorf("failed to send event message: %v", err)
+	}
 	return nil
 }
 
+// transactionWithCheckpoint 在事務中發送 msgs，snapshot 不為 nil 時附上進度
+func (kp *KafkaProducer) transactionWithCheckpoint(msgs []*sarama.ProducerMessage, snapshot func() *models.Checkpoint) error {
+	return kp.transaction(func() ([]*sarama.ProducerMessage, error) {
+		if snapshot != nil {
+			checkpoint, err := kp.checkpointMessage(snapshot())
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, checkpoint)
+		}
+		return msgs, nil
+	})
+}
+
+// SendCheckpoint 將進度寫入壓縮 topic，以事務 ID 作為 key
+func (kp *KafkaProducer) SendCheckpoint(checkpoint *models.Checkpoint) error {
+	msg, err := kp.checkpointMessage(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if kp.async != nil {
+		kp.enqueue(msg)
+		return nil
+	}
+	if err := kp.sendSync(msg); err != nil {
+		return fmt.Errorf("failed to send checkpoint: %v", err)
+	}
+	return nil
+}
+
+func (kp *KafkaProducer) checkpointMessage(checkpoint *models.Checkpoint) (*sarama.ProducerMessage, error) {
+	value, err := json.Marshal(checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+
+	return &sarama.ProducerMessage{
+		Topic:    kp.CheckpointTopic,
+		Key:      sarama.StringEncoder(kp.checkpointKey()),
+		Value:    sarama.ByteEncoder(value),
+		Metadata: deliveryMeta{slot: checkpoint.LastContiguousSlot},
+	}, nil
+}
+
+// checkpointKey 每個監控實例以事務 ID 區分自己的進度
+func (kp *KafkaProducer) checkpointKey() string {
+	if kp.config.TransactionalID != "" {
+		return kp.config.TransactionalID
+	}
+	return kp.config.ClientID
+}
+
+// sendSync 同步發送單條消息，事務模式下包裝為單獨的事務
+func (kp *KafkaProducer) sendSync(msg *sarama.ProducerMessage) error {
+	if kp.Transactional() {
+		return kp.transaction(func() ([]*sarama.ProducerMessage, error) {
+			return []*sarama.ProducerMessage{msg}, nil
+		})
+	}
+
+	_, _, err := kp.producer.SendMessage(msg)
+	return err
+}
+
+// transaction 開始事務並發送 build 返回的消息，任一步驟失敗時中止事務
+func (kp *KafkaProducer) transaction(build func() ([]*sarama.ProducerMessage, error)) error {
+	kp.txnMutex.Lock()
+	defer kp.txnMutex.Unlock()
+
+	if err := kp.producer.BeginTxn(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	msgs, err := build()
+	if err == nil {
+		if err = kp.producer.SendMessages(msgs); err != nil {
+			err = sendError("failed to send transactional messages", err)
+		}
+	}
+	if err == nil {
+		if err = kp.producer.CommitTxn(); err != nil {
+			err = fmt.Errorf("failed to commit transaction: %v", err)
+		}
+	}
+
+	if err != nil {
+		if kp.producer.TxnStatus()&sarama.ProducerTxnFlagInTransaction != 0 {
+			if abortErr := kp.producer.AbortTxn(); abortErr != nil {
+				return fmt.Errorf("%v (abort failed: %v)", err, abortErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// PublishEvent 發送 slot 事件，以 slot 作為 key 保證同一 slot 的事件有序
+func (kp *KafkaProducer) PublishEvent(event *models.SlotEvent) error {
+	msg, err := kp.eventMessage(event)
+	if err != nil {
+		return err
+	}
+
+	if kp.async != nil {
+		kp.enqueue(msg)
+		return nil
+	}
+
+	if err := kp.sendSync(msg); err != nil {
+		return fmt.Errorf("failed to send event message: %v", err)
+	}
+
+	return nil
+}
+
+func (kp *KafkaProducer) eventMessage(event *models.SlotEvent) (*sarama.ProducerMessage, error) {
+	value, err := kp.serializer.Event(kp.EventTopic, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event message: %v", err)
+	}
+
+	return &sarama.ProducerMessage{
+		Topic:    kp.EventTopic,
+		Key:      sarama.StringEncoder(fmt.Sprintf("%d", event.Slot)),
+		Value:    sarama.ByteEncoder(value),
+		Metadata: deliveryMeta{slot: event.Slot, tracked: true},
+	}, nil
+}
+
+// PublishDeadLetter 發送永久失敗的 slot 到死信 topic，死信已寫入本地文件，異步模式下不跟蹤確認
+func (kp *KafkaProducer) PublishDeadLetter(letter *models.DeadLetter) error {
+	if kp.DeadLetterTopic == "" {
+		return nil
+	}
+
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %v", err)
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:    kp.DeadLetterTopic,
+		Key:      sarama.StringEncoder(fmt.Sprintf("%d", letter.Slot)),
+		Value:    sarama.ByteEncoder(value),
+		Metadata: deliveryMeta{slot: letter.Slot},
+	}
+
+	if kp.async != nil {
+		kp.enqueue(msg)
+		return nil
+	}
+
+	if err := kp.sendSync(msg); err != nil {
+		return fmt.Errorf("failed to send dead letter: %v", err)
+	}
+
+	return nil
+}
+
+// SetTracker 實現 AsyncSink
+func (kp *KafkaProducer) SetTracker(tracker DeliveryTracker) {
+	kp.Tracker = tracker
+}
+
+// CheckpointStore 實現 TransactionalSink，進度保存在 CheckpointTopic
+func (kp *KafkaProducer) CheckpointStore() CheckpointStore {
+	return NewKafkaCheckpointStore(kp)
+}
+
+// Flush 同步發送時消息在返回前已確認；異步發送時等待調用前進入隊列的消息全部確認，
+// 期間有消息最終發送失敗時返回錯誤
+func (kp *KafkaProducer) Flush() error {
+	if kp.async == nil {
+		return nil
+	}
+
+	kp.flushMutex.Lock()
+	defer kp.flushMutex.Unlock()
+
+	generation := kp.generation
+	kp.generation++
+	for kp.pendingUntil(generation) {
+		kp.flushCond.Wait()
+	}
+
+	err := kp.deliveryErr
+	kp.deliveryErr = nil
+	return err
+}
+
+// pendingUntil 調用前需持有 flushMutex，返回是否還有不晚於 generation 的消息未確認
+func (kp *KafkaProducer) pendingUntil(generation uint64) bool {
+	for g := range kp.inFlight {
+		if g <= generation {
+			return true
+		}
+	}
+	return false
+}
+
 func (kp *KafkaProducer) Close() error {
+	if kp.async != nil {
+		// 等待隊列中的消息發送完並處理完所有確認
+		err := kp.async.Close()
+		kp.wg.Wait()
+		return err
+	}
 	return kp.producer.Close()
 }
 
-func convertToBlockMessage(block *models.BlockResponse) models.BlockMessage {
+// enqueue 將消息放入異步發送隊列，隊列滿時阻塞
+func (kp *KafkaProducer) enqueue(msg *sarama.ProducerMessage) {
+	meta, _ := msg.Metadata.(deliveryMeta)
+	if meta.tracked && kp.Tracker != nil {
+		kp.Tracker.Sent(meta.slot)
+	}
+
+	kp.flushMutex.Lock()
+	meta.generation = kp.generation
+	kp.inFlight[meta.generation]++
+	kp.flushMutex.Unlock()
+
+	msg.Metadata = meta
+	kp.async.Input() <- msg
+}
+
+// handleDeliveries 將異步發送的結果轉交給 Tracker
+func (kp *KafkaProducer) handleDeliveries() {
+	kp.wg.Add(2)
+	go func() {
+		defer kp.wg.Done()
+		for msg := range kp.async.Successes() {
+			kp.delivered(msg, nil)
+		}
+	}()
+	go func() {
+		defer kp.wg.Done()
+		for perr := range kp.async.Errors() {
+			log.Printf("Failed to deliver message to %s: %v", perr.Msg.Topic, perr.Err)
+			kp.delivered(perr.Msg, sendError("failed to deliver message to "+perr.Msg.Topic, perr.Err))
+		}
+	}()
+}
+
+func (kp *KafkaProducer) delivered(msg *sarama.ProducerMessage, err error) {
+	meta, ok := msg.Metadata.(deliveryMeta)
+	if !ok {
+		return
+	}
+
+	// 先通知 Tracker 再計為已確認，Flush 返回時 Tracker 已收到之前所有消息的結果
+	if meta.tracked && kp.Tracker != nil {
+		kp.Tracker.Delivered(meta.slot, err)
+	}
+
+	kp.flushMutex.Lock()
+	if kp.inFlight[meta.generation]--; kp.inFlight[meta.generation] <= 0 {
+		delete(kp.inFlight, meta.generation)
+	}
+	if err != nil && kp.deliveryErr == nil {
+		kp.deliveryErr = err
+	}
+	kp.flushCond.Broadcast()
+	kp.flushMutex.Unlock()
+}
+
+// ConvertToBlockMessage 將 RPC 區塊轉換為發送到 Kafka 的消息
+func ConvertToBlockMessage(block *models.BlockResponse) models.BlockMessage {
 	transactions := make([]models.TransactionInfo, len(block.Result.Transactions))
 
 	for i, tx := range block.Result.Transactions {
@@ -62,6 +533,8 @@ func convertToBlockMessage(block *models.BlockResponse) models.BlockMessage {
 	}
 
 	return models.BlockMessage{
+		Slot:              block.Slot,
+		Commitment:        block.Commitment,
 		BlockHeight:       block.Result.BlockHeight,
 		BlockTime:         block.Result.BlockTime,
 		Blockhash:         block.Result.Blockhash,
@@ -72,11 +545,26 @@ func convertToBlockMessage(block *models.BlockResponse) models.BlockMessage {
 	}
 }
 
+// NewTransactionMessage 取出區塊中第 index 筆交易並附上區塊信息
+func NewTransactionMessage(block *models.BlockMessage, index int) *models.TransactionMessage {
+	return &models.TransactionMessage{
+		Slot:            block.Slot,
+		BlockHeight:     block.BlockHeight,
+		BlockTime:       block.BlockTime,
+		Blockhash:       block.Blockhash,
+		Index:           index,
+		Commitment:      block.Commitment,
+		TransactionInfo: block.Transactions[index],
+		Timestamp:       block.Timestamp,
+	}
+}
+
 func convertTransaction(tx models.Transaction) models.TransactionInfo {
 	// 處理餘額變化
 	balanceChanges := getBalanceChanges(tx)
 	// 處理指令
 	instructions := getInstructions(tx)
+	programs := getPrograms(tx)
 
 	status := "Success"
 	if tx.Meta.Err != nil {
@@ -89,6 +577,7 @@ func convertTransaction(tx models.Transaction) models.TransactionInfo {
 		Fee:            tx.Meta.Fee,
 		AccountKeys:    tx.Transaction.Message.AccountKeys,
 		Instructions:   instructions,
+		Programs:       programs,
 		BalanceChanges: balanceChanges,
 		TokenBalances:  tx.Meta.PostTokenBalances,
 		ComputeUnits:   tx.Meta.ComputeUnitsConsumed,
@@ -98,7 +587,8 @@ func convertTransaction(tx models.Transaction) models.TransactionInfo {
 
 func getBalanceChanges(tx models.Transaction) []models.BalanceChange {
 	changes := make([]models.BalanceChange, 0)
-	for j, key := range tx.Transaction.Message.AccountKeys {
+	// 餘額數組覆蓋通過地址查找表加載的賬戶
+	for j, key := range accountKeys(tx) {
 		if j < len(tx.Meta.PreBalances) && j < len(tx.Meta.PostBalances) {
 			preBalance := tx.Meta.PreBalances[j]
 			postBalance := tx.Meta.PostBalances[j]
@@ -115,19 +605,34 @@ func getBalanceChanges(tx models.Transaction) []models.BalanceChange {
 	return changes
 }
 
+// accountKeys 返回交易的完整賬戶列表，v0 交易通過地址查找表加載的地址按可寫、只讀的順序排在靜態賬戶之後
+func accountKeys(tx models.Transaction) []string {
+	keys := tx.Transaction.Message.AccountKeys
+	loaded := tx.Meta.LoadedAddresses
+	if len(loaded.Writable) == 0 && len(loaded.Readonly) == 0 {
+		return keys
+	}
+
+	all := make([]string, 0, len(keys)+len(loaded.Writable)+len(loaded.Readonly))
+	all = append(all, keys...)
+	all = append(all, loaded.Writable...)
+	return append(all, loaded.Readonly...)
+}
+
 func getInstructions(tx models.Transaction) []models.Instruction {
+	keys := accountKeys(tx)
 	instructions := make([]models.Instruction, len(tx.Transaction.Message.Instructions))
 	for j, inst := range tx.Transaction.Message.Instructions {
 		accounts := make([]string, 0)
 		for _, idx := range inst.Accounts {
-			if int(idx) < len(tx.Transaction.Message.AccountKeys) {
-				accounts = append(accounts, tx.Transaction.Message.AccountKeys[idx])
+			if int(idx) < len(keys) {
+				accounts = append(accounts, keys[idx])
 			}
 		}
 
 		var programId string
-		if int(inst.ProgramIdIndex) < len(tx.Transaction.Message.AccountKeys) {
-			programId = tx.Transaction.Message.AccountKeys[inst.ProgramIdIndex]
+		if int(inst.ProgramIdIndex) < len(keys) {
+			programId = keys[inst.ProgramIdIndex]
 		}
 
 		instructions[j] = models.Instruction{
@@ -138,3 +643,32 @@ func getInstructions(tx models.Transaction) []models.Instruction {
 	}
 	return instructions
 }
+
+// getPrograms 返回交易中頂層與內部指令調用的所有程序，按首次出現的順序去重
+func getPrograms(tx models.Transaction) []string {
+	keys := accountKeys(tx)
+	programs := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(program string) {
+		if program != "" && !seen[program] {
+			seen[program] = true
+			programs = append(programs, program)
+		}
+	}
+
+	for _, inst := range tx.Transaction.Message.Instructions {
+		if int(inst.ProgramIdIndex) < len(keys) {
+			add(keys[inst.ProgramIdIndex])
+		}
+	}
+	for _, inner := range tx.Meta.InnerInstructions {
+		for _, inst := range inner.Instructions {
+			if inst.ProgramId != "" {
+				add(inst.ProgramId)
+			} else if int(inst.ProgramIdIndex) < len(keys) {
+				add(keys[inst.ProgramIdIndex])
+			}
+		}
+	}
+	return programs
+}
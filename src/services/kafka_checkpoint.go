@@ -0,0 +1,247 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"solana/src/models"
+
+	"github.com/IBM/sarama"
+)
+
+const (
+	checkpointFetchBytes = 16 * 1024 * 1024 // 讀取進度時每次 Fetch 的最大字節數
+	checkpointFetchWait  = 500              // Fetch 等待新數據的毫秒數
+	checkpointReplicas   = 3                // 創建進度 topic 時的最大副本數
+	cleanupPolicy        = "cleanup.policy"
+)
+
+// KafkaCheckpointStore 將進度保存在壓縮 topic 中，事務模式下與區塊消息一起提交
+type KafkaCheckpointStore struct {
+	producer *KafkaProducer
+}
+
+func NewKafkaCheckpointStore(producer *KafkaProducer) *KafkaCheckpointStore {
+	return &KafkaCheckpointStore{producer: producer}
+}
+
+// Load 以 read_committed 讀取整個 topic，返回本實例最後一次提交的進度
+func (s *KafkaCheckpointStore) Load() (*models.Checkpoint, error) {
+	topic := s.producer.CheckpointTopic
+	key := s.producer.checkpointKey()
+
+	client, err := sarama.NewClient(s.producer.brokers, s.producer.config.ToSaramaConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %v", err)
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(topic)
+	if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions of %s: %v", topic, err)
+	}
+
+	var latest []byte
+	for _, partition := range partitions {
+		value, err := s.readPartition(client, topic, partition, key)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			latest = value
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	var checkpoint models.Checkpoint
+	if err := json.Unmarshal(latest, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %v", err)
+	}
+	return &checkpoint, nil
+}
+
+// readPartition 以 read_committed 讀取分區到最後穩定位置（LSO），返回最後一條匹配 key 的消息內容。
+// 事務的控制記錄佔用 offset 但不是消息，Consumer 不會返回它們，因此直接發送 Fetch 請求，按批次的最後 offset 推進
+func (s *KafkaCheckpointStore) readPartition(client sarama.Client, topic string, partition int32, key string) ([]byte, error) {
+	offset, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oldest offset of %s/%d: %v", topic, partition, err)
+	}
+
+	var latest []byte
+	for {
+		broker, err := client.Leader(topic, partition)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get leader of %s/%d: %v", topic, partition, err)
+		}
+
+		// 版本 4 起支持 read_committed 並返回 LSO 與中止的事務
+		request := &sarama.FetchRequest{
+			Version:     4,
+			MaxWaitTime: checkpointFetchWait,
+			MinBytes:    1,
+			MaxBytes:    checkpointFetchBytes,
+			Isolation:   sarama.ReadCommitted,
+		}
+		request.AddBlock(topic, partition, offset, checkpointFetchBytes, -1)
+		response, err := broker.Fetch(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s/%d: %v", topic, partition, err)
+		}
+		block := response.GetBlock(topic, partition)
+		if block == nil {
+			return nil, fmt.Errorf("failed to fetch %s/%d: %v", topic, partition, sarama.ErrIncompleteResponse)
+		}
+		if !errors.Is(block.Err, sarama.ErrNoError) {
+			return nil, fmt.Errorf("failed to fetch %s/%d: %v", topic, partition, block.Err)
+		}
+		if offset >= block.LastStableOffset {
+			return latest, nil
+		}
+
+		next, value, err := readCommitted(block, offset, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s/%d: %v", topic, partition, err)
+		}
+		if value != nil {
+			latest = value
+		}
+		if next <= offset {
+			return nil, fmt.Errorf("failed to read %s/%d: no progress at offset %d (LSO %d)", topic, partition, offset, block.LastStableOffset)
+		}
+		offset = next
+	}
+}
+
+// readCommitted 跳過控制記錄與中止事務中的消息，返回下一個要讀取的 offset 和 from 之後最後一條匹配 key 的消息內容
+func readCommitted(block *sarama.FetchResponseBlock, from int64, key string) (int64, []byte, error) {
+	aborted := append([]*sarama.AbortedTransaction(nil), block.AbortedTransactions...)
+	sort.Slice(aborted, func(i, j int) bool { return aborted[i].FirstOffset < aborted[j].FirstOffset })
+	abortedProducers := make(map[int64]bool)
+
+	next := from
+	var value []byte
+	for _, records := range block.RecordsSet {
+		batch := records.RecordBatch
+		if batch == nil {
+			return 0, nil, fmt.Errorf("legacy message format is not supported")
+		}
+		// 截斷的批次下次從它的起點重新讀取
+		if batch.PartialTrailingRecord {
+			break
+		}
+
+		for len(aborted) > 0 && aborted[0].FirstOffset <= batch.LastOffset() {
+			abortedProducers[aborted[0].ProducerID] = true
+			aborted = aborted[1:]
+		}
+		next = batch.LastOffset() + 1
+
+		if batch.Control {
+			if isAbortMarker(batch) {
+				delete(abortedProducers, batch.ProducerID)
+			}
+			continue
+		}
+		if batch.IsTransactional && abortedProducers[batch.ProducerID] {
+			continue
+		}
+		for _, record := range batch.Records {
+			if batch.FirstOffset+record.OffsetDelta >= from && string(record.Key) == key {
+				value = record.Value
+			}
+		}
+	}
+	return next, value, nil
+}
+
+// isAbortMarker 控制記錄的 key 為 2 字節版本加 2 字節類型
+func isAbortMarker(batch *sarama.RecordBatch) bool {
+	if len(batch.Records) == 0 || len(batch.Records[0].Key) < 4 {
+		return false
+	}
+	return sarama.ControlRecordType(binary.BigEndian.Uint16(batch.Records[0].Key[2:4])) == sarama.ControlRecordAbort
+}
+
+// EnsureTopic 檢查進度 topic 是否只開啟了壓縮，不存在時創建。
+// 按保留時間刪除的 topic 會在一段時間沒有新進度後丟失最後的進度，拒絕使用
+func (s *KafkaCheckpointStore) EnsureTopic() error {
+	topic := s.producer.CheckpointTopic
+	admin, err := sarama.NewClusterAdmin(s.producer.brokers, s.producer.config.ToSaramaConfig())
+	if err != nil {
+		return fmt.Errorf("failed to create kafka admin: %v", err)
+	}
+	defer admin.Close()
+
+	metadata, err := admin.DescribeTopics([]string{topic})
+	if err != nil {
+		return fmt.Errorf("failed to describe %s: %v", topic, err)
+	}
+	switch {
+	case len(metadata) != 1:
+		return fmt.Errorf("failed to describe %s: got %d topics", topic, len(metadata))
+	case errors.Is(metadata[0].Err, sarama.ErrUnknownTopicOrPartition):
+		created, err := createCheckpointTopic(admin, topic)
+		if err != nil || created {
+			return err
+		}
+	case !errors.Is(metadata[0].Err, sarama.ErrNoError):
+		return fmt.Errorf("failed to describe %s: %v", topic, metadata[0].Err)
+	}
+
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        topic,
+		ConfigNames: []string{cleanupPolicy},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe config of %s: %v", topic, err)
+	}
+	for _, entry := range entries {
+		if entry.Name == cleanupPolicy {
+			if entry.Value != "compact" {
+				return fmt.Errorf("checkpoint topic %s has %s=%s, expected compact", topic, cleanupPolicy, entry.Value)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("checkpoint topic %s has no %s config", topic, cleanupPolicy)
+}
+
+// createCheckpointTopic 創建單分區的壓縮 topic，副本數不超過 broker 數量。
+// 其他實例已經創建時返回 false，由調用方檢查配置
+func createCheckpointTopic(admin sarama.ClusterAdmin, topic string) (bool, error) {
+	brokers, _, err := admin.DescribeCluster()
+	if err != nil {
+		return false, fmt.Errorf("failed to describe cluster: %v", err)
+	}
+
+	policy := "compact"
+	detail := &sarama.TopicDetail{
+		NumPartitions:     1,
+		ReplicationFactor: int16(min(len(brokers), checkpointReplicas)),
+		ConfigEntries:     map[string]*string{cleanupPolicy: &policy},
+	}
+	err = admin.CreateTopic(topic, detail, false)
+	if errors.Is(err, sarama.ErrTopicAlreadyExists) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create checkpoint topic %s: %v", topic, err)
+	}
+	log.Printf("Created checkpoint topic %s (replicas %d)", topic, detail.ReplicationFactor)
+	return true, nil
+}
+
+func (s *KafkaCheckpointStore) Save(checkpoint *models.Checkpoint) error {
+	return s.producer.SendCheckpoint(checkpoint)
+}
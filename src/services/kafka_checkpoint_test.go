@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+// dataBatch 一個事務中的數據批次，每條記錄為 key=value
+func dataBatch(producerID, first int64, keys ...string) *sarama.Records {
+	batch := &sarama.RecordBatch{FirstOffset: first, ProducerID: producerID, IsTransactional: true, LastOffsetDelta: int32(len(keys) - 1)}
+	for i, key := range keys {
+		batch.Records = append(batch.Records, &sarama.Record{OffsetDelta: int64(i), Key: []byte(key), Value: []byte(fmt.Sprintf("%s@%d", key, first+int64(i)))})
+	}
+	return &sarama.Records{RecordBatch: batch}
+}
+
+// markerBatch 事務的提交或中止標記，佔用一個 offset
+func markerBatch(producerID, offset int64, abort bool) *sarama.Records {
+	key := []byte{0, 0, 0, 1}
+	if abort {
+		key[3] = 0
+	}
+	return &sarama.Records{RecordBatch: &sarama.RecordBatch{
+		FirstOffset:     offset,
+		ProducerID:      producerID,
+		IsTransactional: true,
+		Control:         true,
+		Records:         []*sarama.Record{{Key: key}},
+	}}
+}
+
+func TestReadCommitted(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      int64
+		records   []*sarama.Records
+		aborted   []*sarama.AbortedTransaction
+		wantNext  int64
+		wantValue string
+	}{
+		{
+			name:     "trailing commit marker advances past the end",
+			records:  []*sarama.Records{dataBatch(1, 0, "me"), markerBatch(1, 1, false)},
+			wantNext: 2, wantValue: "me@0",
+		},
+		{
+			name: "last matching key wins",
+			records: []*sarama.Records{
+				dataBatch(1, 0, "me", "other"), markerBatch(1, 2, false),
+				dataBatch(1, 3, "me"), markerBatch(1, 4, false),
+			},
+			wantNext: 5, wantValue: "me@3",
+		},
+		{
+			name: "aborted transaction is skipped",
+			records: []*sarama.Records{
+				dataBatch(1, 0, "me"), markerBatch(1, 1, false),
+				dataBatch(1, 2, "me"), markerBatch(1, 3, true),
+			},
+			aborted:  []*sarama.AbortedTransaction{{ProducerID: 1, FirstOffset: 2}},
+			wantNext: 4, wantValue: "me@0",
+		},
+		{
+			name: "producer reused after abort",
+			records: []*sarama.Records{
+				dataBatch(1, 0, "me"), markerBatch(1, 1, true),
+				dataBatch(1, 2, "me"), markerBatch(1, 3, false),
+			},
+			aborted:  []*sarama.AbortedTransaction{{ProducerID: 1, FirstOffset: 0}},
+			wantNext: 4, wantValue: "me@2",
+		},
+		{
+			name:     "records before the fetch offset are ignored",
+			from:     1,
+			records:  []*sarama.Records{dataBatch(1, 0, "me", "other"), markerBatch(1, 2, false)},
+			wantNext: 3,
+		},
+		{
+			name: "partial trailing batch is read again",
+			records: []*sarama.Records{
+				dataBatch(1, 0, "me"), markerBatch(1, 1, false),
+				{RecordBatch: &sarama.RecordBatch{FirstOffset: 2, PartialTrailingRecord: true}},
+			},
+			wantNext: 2, wantValue: "me@0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := &sarama.FetchResponseBlock{RecordsSet: tt.records, AbortedTransactions: tt.aborted}
+			next, value, err := readCommitted(block, tt.from, "me")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != tt.wantNext || string(value) != tt.wantValue {
+				t.Errorf("next = %d value = %q, want next = %d value = %q", next, value, tt.wantNext, tt.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"solana/src/models"
+)
+
+func TestGetBalanceChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   string
+		want []string
+	}{
+		{
+			name: "legacy transaction",
+			tx: `{"meta":{"preBalances":[100,5,7],"postBalances":[90,15,7]},
+				"transaction":{"message":{"accountKeys":["payer","to","program"]}}}`,
+			want: []string{"payer", "to"},
+		},
+		{
+			name: "v0 transaction with loaded addresses",
+			tx: `{"meta":{"preBalances":[100,7,1,2],"postBalances":[95,7,6,2],
+					"loadedAddresses":{"writable":["lookupW"],"readonly":["lookupR"]}},
+				"transaction":{"message":{"accountKeys":["payer","program"]}}}`,
+			want: []string{"payer", "lookupW"},
+		},
+		{
+			name: "balances shorter than accounts",
+			tx: `{"meta":{"preBalances":[100],"postBalances":[90],
+					"loadedAddresses":{"writable":["lookupW"]}},
+				"transaction":{"message":{"accountKeys":["payer"]}}}`,
+			want: []string{"payer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tx models.Transaction
+			if err := json.Unmarshal([]byte(tt.tx), &tx); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, change := range getBalanceChanges(tx) {
+				if change.Change != int64(change.PostBalance)-int64(change.PreBalance) {
+					t.Errorf("%s: change = %d", change.Account, change.Change)
+				}
+				got = append(got, change.Account)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("accounts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migration 一個遷移文件，文件名以版本號開頭，例如 0001_init.sql
+type migration struct {
+	version int
+	name    string
+	script  string
+}
+
+// loadMigrations 讀取目錄中的遷移，按文件名順序返回
+func loadMigrations(dir string) ([]migration, error) {
+	dir = path.Join("migrations", dir)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name %s", name)
+		}
+		script, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", name, err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, script: string(script)})
+	}
+	return migrations, nil
+}
@@ -0,0 +1,47 @@
+-- 交易、指令與餘額變化表，按天分區，ReplacingMergeTree 在合併時按簽名去重，
+-- 查詢時使用 FINAL 可得到去重後的結果
+
+CREATE TABLE IF NOT EXISTS transactions (
+    signature     String,
+    slot          UInt64,
+    block_height  UInt64,
+    block_time    DateTime,
+    blockhash     String,
+    tx_index      UInt32,
+    commitment    LowCardinality(String),
+    status        LowCardinality(String),
+    fee           UInt64,
+    compute_units UInt64,
+    account_keys  Array(String),
+    programs      Array(LowCardinality(String)),
+    log_messages  Array(String),
+    version       UInt64
+) ENGINE = ReplacingMergeTree(version)
+PARTITION BY toDate(block_time)
+ORDER BY signature;
+
+CREATE TABLE IF NOT EXISTS instructions (
+    signature         String,
+    instruction_index UInt32,
+    slot              UInt64,
+    block_time        DateTime,
+    program_id        LowCardinality(String),
+    data              String,
+    accounts          Array(String),
+    version           UInt64
+) ENGINE = ReplacingMergeTree(version)
+PARTITION BY toDate(block_time)
+ORDER BY (signature, instruction_index);
+
+CREATE TABLE IF NOT EXISTS balance_changes (
+    signature    String,
+    account      String,
+    slot         UInt64,
+    block_time   DateTime,
+    pre_balance  UInt64,
+    post_balance UInt64,
+    change       Int64,
+    version      UInt64
+) ENGINE = ReplacingMergeTree(version)
+PARTITION BY toDate(block_time)
+ORDER BY (signature, account);
@@ -0,0 +1,77 @@
+-- 區塊與交易的規範化表，按 slot 和簽名冪等寫入
+
+CREATE TABLE IF NOT EXISTS blocks (
+    slot               BIGINT PRIMARY KEY,
+    block_height       BIGINT      NOT NULL,
+    block_time         TIMESTAMPTZ,
+    blockhash          TEXT        NOT NULL,
+    parent_slot        BIGINT      NOT NULL,
+    previous_blockhash TEXT        NOT NULL,
+    commitment         TEXT        NOT NULL,
+    transaction_count  INTEGER     NOT NULL,
+    published_at       TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS blocks_block_time_idx ON blocks (block_time);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    signature     TEXT PRIMARY KEY,
+    slot          BIGINT  NOT NULL,
+    tx_index      INTEGER NOT NULL,
+    block_time    TIMESTAMPTZ,
+    status        TEXT    NOT NULL,
+    fee           BIGINT  NOT NULL,
+    compute_units BIGINT  NOT NULL,
+    account_keys  TEXT[]  NOT NULL,
+    programs      TEXT[]  NOT NULL,
+    instructions  JSONB   NOT NULL,
+    log_messages  TEXT[]  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS transactions_slot_idx ON transactions (slot, tx_index);
+CREATE INDEX IF NOT EXISTS transactions_programs_idx ON transactions USING GIN (programs);
+
+CREATE TABLE IF NOT EXISTS balance_changes (
+    signature    TEXT   NOT NULL,
+    account      TEXT   NOT NULL,
+    slot         BIGINT NOT NULL,
+    pre_balance  BIGINT NOT NULL,
+    post_balance BIGINT NOT NULL,
+    change       BIGINT NOT NULL,
+    PRIMARY KEY (signature, account)
+);
+
+CREATE INDEX IF NOT EXISTS balance_changes_account_idx ON balance_changes (account, slot);
+CREATE INDEX IF NOT EXISTS balance_changes_slot_idx ON balance_changes (slot);
+
+CREATE TABLE IF NOT EXISTS token_balances (
+    signature        TEXT     NOT NULL,
+    account_index    INTEGER  NOT NULL,
+    slot             BIGINT   NOT NULL,
+    mint             TEXT     NOT NULL,
+    owner            TEXT     NOT NULL,
+    program_id       TEXT     NOT NULL,
+    amount           NUMERIC(39, 0) NOT NULL,
+    decimals         SMALLINT NOT NULL,
+    ui_amount_string TEXT     NOT NULL,
+    PRIMARY KEY (signature, account_index)
+);
+
+CREATE INDEX IF NOT EXISTS token_balances_owner_idx ON token_balances (owner, mint);
+CREATE INDEX IF NOT EXISTS token_balances_slot_idx ON token_balances (slot);
+
+CREATE TABLE IF NOT EXISTS slot_events (
+    slot       BIGINT      NOT NULL,
+    type       TEXT        NOT NULL,
+    blockhash  TEXT        NOT NULL,
+    data       JSONB       NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (slot, type, blockhash)
+);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+    slot      BIGINT PRIMARY KEY,
+    reason    TEXT        NOT NULL,
+    data      JSONB       NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// postgresMigrationLock 事務級咨詢鎖的 key，防止多個實例同時遷移
+const postgresMigrationLock = 0x736f6c616e61
+
+// migratePostgres 按文件名前綴的版本號依次執行尚未執行的遷移，每個遷移在獨立事務中執行
+func migratePostgres(db *sql.DB) error {
+	migrations, err := loadMigrations("postgres")
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		applied, err := applyPostgresMigration(db, migration.version, migration.script)
+		if err != nil {
+			return fmt.Errorf("migration %s failed: %v", migration.name, err)
+		}
+		if applied {
+			log.Printf("Applied PostgreSQL migration %s", migration.name)
+		}
+	}
+	return nil
+}
+
+func applyPostgresMigration(db *sql.DB, version int, script string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", postgresMigrationLock); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, tx.Commit()
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
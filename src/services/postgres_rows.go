@@ -0,0 +1,162 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"solana/src/models"
+)
+
+// 各表的列，順序與遷移中的建表語句一致
+var (
+	postgresBlockColumns = []string{
+		"slot", "block_height", "block_time", "blockhash", "parent_slot",
+		"previous_blockhash", "commitment", "transaction_count", "published_at",
+	}
+	postgresTransactionColumns = []string{
+		"signature", "slot", "tx_index", "block_time", "status", "fee",
+		"compute_units", "account_keys", "programs", "instructions", "log_messages",
+	}
+	postgresBalanceChangeColumns = []string{
+		"signature", "account", "slot", "pre_balance", "post_balance", "change",
+	}
+	postgresTokenBalanceColumns = []string{
+		"signature", "account_index", "slot", "mint", "owner", "program_id",
+		"amount", "decimals", "ui_amount_string",
+	}
+)
+
+// postgresRows 一批待 COPY 的行，同一簽名只保留第一次加入的交易
+type postgresRows struct {
+	blocks         [][]interface{}
+	transactions   [][]interface{}
+	balanceChanges [][]interface{}
+	tokenBalances  [][]interface{}
+	signatures     map[string]bool
+}
+
+func newPostgresRows() *postgresRows {
+	return &postgresRows{signatures: make(map[string]bool)}
+}
+
+func (r *postgresRows) addBlock(block *models.BlockMessage) {
+	r.blocks = append(r.blocks, []interface{}{
+		int64(block.Slot),
+		int64(block.BlockHeight),
+		blockTime(block.BlockTime),
+		pgText(block.Blockhash),
+		int64(block.ParentSlot),
+		pgText(block.PreviousBlockhash),
+		pgText(block.Commitment),
+		len(block.Transactions),
+		time.Unix(block.Timestamp, 0),
+	})
+}
+
+func (r *postgresRows) addTransaction(slot uint64, index int, seconds *uint64, info *models.TransactionInfo) {
+	// 獨立發佈的交易可能與區塊中的交易重複，重複的行會讓 upsert 失敗
+	if r.signatures[info.Signature] {
+		return
+	}
+	r.signatures[info.Signature] = true
+
+	// 指令只包含字符串，序列化不會失敗
+	instructions, _ := json.Marshal(nonNil(info.Instructions))
+
+	signature := pgText(info.Signature)
+	r.transactions = append(r.transactions, []interface{}{
+		signature,
+		int64(slot),
+		index,
+		blockTime(seconds),
+		pgText(info.Status),
+		int64(info.Fee),
+		int64(info.ComputeUnits),
+		pgTextArray(info.AccountKeys),
+		pgTextArray(info.Programs),
+		pgJSON(instructions),
+		pgTextArray(info.LogMessages),
+	})
+
+	for _, change := range info.BalanceChanges {
+		r.balanceChanges = append(r.balanceChanges, []interface{}{
+			signature,
+			pgText(change.Account),
+			int64(slot),
+			int64(change.PreBalance),
+			int64(change.PostBalance),
+			change.Change,
+		})
+	}
+
+	for _, balance := range info.TokenBalances {
+		amount := balance.UITokenAmount.Amount
+		if amount == "" {
+			amount = "0"
+		}
+		r.tokenBalances = append(r.tokenBalances, []interface{}{
+			signature,
+			int64(balance.AccountIndex),
+			int64(slot),
+			pgText(balance.Mint),
+			pgText(balance.Owner),
+			pgText(balance.ProgramId),
+			amount,
+			int(balance.UITokenAmount.Decimals),
+			pgText(balance.UITokenAmount.UIAmountString),
+		})
+	}
+}
+
+// pgText 去除 PostgreSQL text 不接受的 NUL 字符和無效的 UTF-8，例如日誌和備忘錄中的任意字節
+func pgText(value string) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(value, "\x00", ""), "\uFFFD")
+}
+
+func pgTextArray(values []string) pq.StringArray {
+	array := make(pq.StringArray, len(values))
+	for i, value := range values {
+		array[i] = pgText(value)
+	}
+	return array
+}
+
+// pgJSON 去除 JSON 中的 \u0000 轉義，jsonb 不接受該字符。json.Marshal 已把無效的 UTF-8 替換掉
+func pgJSON(data []byte) string {
+	var builder strings.Builder
+	builder.Grow(len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\\' || i+1 >= len(data) {
+			builder.WriteByte(data[i])
+			continue
+		}
+		// 反斜杠總是開始一個轉義序列，按序列整體處理，避免把 \\u0000 誤認為 NUL
+		if string(data[i+1:min(i+6, len(data))]) == "u0000" {
+			i += 5
+			continue
+		}
+		builder.WriteByte(data[i])
+		builder.WriteByte(data[i+1])
+		i++
+	}
+	return builder.String()
+}
+
+// blockTime 沒有區塊時間時寫入 NULL
+func blockTime(seconds *uint64) interface{} {
+	if seconds == nil {
+		return nil
+	}
+	return time.Unix(int64(*seconds), 0)
+}
+
+// nonNil 空切片寫入空數組而不是 NULL
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"solana/src/config"
+)
+
+// RateLimitStats 令牌桶狀態快照
+type RateLimitStats struct {
+	Endpoint       string  `json:"endpoint"`
+	Method         string  `json:"method"`
+	ConfiguredRate float64 `json:"configured_rate"`
+	CurrentRate    float64 `json:"current_rate"`
+	Tokens         float64 `json:"tokens"`
+	PausedFor      string  `json:"paused_for"`
+	Throttled      uint64  `json:"throttled"`
+}
+
+type tokenBucket struct {
+	limit       float64 // 配置的速率
+	rate        float64 // 自適應調整後的速率
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	throttled   uint64
+	config      *config.RateLimitConfig
+	mutex       sync.Mutex
+}
+
+func newTokenBucket(limit float64, cfg *config.RateLimitConfig) *tokenBucket {
+	burst := math.Max(float64(cfg.Burst), 1)
+	return &tokenBucket{
+		limit:  limit,
+		rate:   limit,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		config: cfg,
+	}
+}
+
+// refill 按經過的時間補充令牌並逐步恢復速率，調用前需持有 mutex
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.last = now
+	b.rate = math.Min(b.limit, b.rate+b.limit*b.config.RecoveryPerSecond*elapsed)
+	b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+}
+
+// reserve 嘗試取得一個令牌，返回需要等待的時間，為 0 表示已取得
+func (b *tokenBucket) reserve() time.Duration {
+	if b.limit <= 0 {
+		return 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.refill(now)
+
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) wait() {
+	for {
+		delay := b.reserve()
+		if delay == 0 {
+			return
+		}
+		time.Sleep(delay)
+	}
+}
+
+// throttle 被服務端限流時降低速率並暫停到 Retry-After 之後
+func (b *tokenBucket) throttle(retryAfter time.Duration) {
+	if b.limit <= 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if retryAfter <= 0 {
+		retryAfter = b.config.DefaultRetryAfter
+	}
+
+	now := time.Now()
+	b.refill(now)
+	b.throttled++
+	b.rate = math.Max(b.minRate(), b.rate*b.config.BackoffFactor)
+	b.tokens = 0
+	if until := now.Add(retryAfter); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+// minRateRatio 配置未經校驗時的降速下限，保證速率始終大於 0
+const minRateRatio = 0.01
+
+// minRate 自適應降速的下限
+func (b *tokenBucket) minRate() float64 {
+	return b.limit * math.Max(b.config.MinRateRatio, minRateRatio)
+}
+
+func (b *tokenBucket) paused() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return time.Now().Before(b.pausedUntil)
+}
+
+// rateLimiter 單個端點的限流器，獨立配置的方法各有一個令牌桶，其餘方法共用默認令牌桶
+type rateLimiter struct {
+	config  *config.RateLimitConfig
+	buckets map[string]*tokenBucket
+	mutex   sync.Mutex
+}
+
+const defaultBucket = "default"
+
+func newRateLimiter(cfg *config.RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:  cfg,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *rateLimiter) bucket(method string) *tokenBucket {
+	rate, dedicated := l.config.MethodRate(method)
+	if !dedicated {
+		method = defaultBucket
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, ok := l.buckets[method]
+	if !ok {
+		b = newTokenBucket(rate, l.config)
+		l.buckets[method] = b
+	}
+	return b
+}
+
+func (l *rateLimiter) stats(endpoint string) []RateLimitStats {
+	l.mutex.Lock()
+	methods := make([]string, 0, len(l.buckets))
+	for method := range l.buckets {
+		methods = append(methods, method)
+	}
+	l.mutex.Unlock()
+	sort.Strings(methods)
+
+	stats := make([]RateLimitStats, 0, len(methods))
+	for _, method := range methods {
+		l.mutex.Lock()
+		b := l.buckets[method]
+		l.mutex.Unlock()
+
+		b.mutex.Lock()
+		b.refill(time.Now())
+		pausedFor := time.Duration(0)
+		if d := time.Until(b.pausedUntil); d > 0 {
+			pausedFor = d
+		}
+		stats = append(stats, RateLimitStats{
+			Endpoint:       endpoint,
+			Method:         method,
+			ConfiguredRate: b.limit,
+			CurrentRate:    b.rate,
+			Tokens:         b.tokens,
+			PausedFor:      pausedFor.Truncate(time.Millisecond).String(),
+			Throttled:      b.throttled,
+		})
+		b.mutex.Unlock()
+	}
+	return stats
+}
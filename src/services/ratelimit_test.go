@@ -0,0 +1,130 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"solana/src/config"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      float64
+		burst      int
+		tokens     float64
+		rate       float64
+		elapsed    time.Duration
+		wantTokens float64
+		wantRate   float64
+	}{
+		{name: "refills at the current rate", limit: 10, burst: 10, tokens: 0, rate: 10, elapsed: 300 * time.Millisecond, wantTokens: 3, wantRate: 10},
+		{name: "capped at burst", limit: 10, burst: 5, tokens: 4, rate: 10, elapsed: time.Second, wantTokens: 5, wantRate: 10},
+		// 速率先恢復 10*0.05*2=1，再按恢復後的速率補充
+		{name: "recovers the throttled rate", limit: 10, burst: 100, tokens: 0, rate: 2, elapsed: 2 * time.Second, wantTokens: 6, wantRate: 3},
+		{name: "recovery capped at the configured rate", limit: 10, burst: 100, tokens: 0, rate: 9.9, elapsed: 2 * time.Second, wantTokens: 20, wantRate: 10},
+		{name: "no time elapsed", limit: 10, burst: 10, tokens: 1, rate: 10, elapsed: 0, wantTokens: 1, wantRate: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewRateLimitConfig()
+			cfg.Burst = tt.burst
+			b := newTokenBucket(tt.limit, cfg)
+			b.tokens = tt.tokens
+			b.rate = tt.rate
+
+			b.refill(b.last.Add(tt.elapsed))
+			if !approx(b.tokens, tt.wantTokens) || !approx(b.rate, tt.wantRate) {
+				t.Errorf("tokens = %v rate = %v, want tokens = %v rate = %v", b.tokens, b.rate, tt.wantTokens, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	cfg := config.NewRateLimitConfig()
+	cfg.Burst = 2
+	b := newTokenBucket(10, cfg)
+
+	for i := 0; i < 2; i++ {
+		if delay := b.reserve(); delay != 0 {
+			t.Fatalf("reserve %d: delay = %v, want 0 within burst", i, delay)
+		}
+	}
+	// 令牌用完後大約需要等待一個令牌的補充時間
+	if delay := b.reserve(); delay <= 0 || delay > 100*time.Millisecond {
+		t.Errorf("delay = %v, want (0, 100ms]", delay)
+	}
+}
+
+func TestTokenBucketBackoffFloor(t *testing.T) {
+	tests := []struct {
+		name         string
+		minRateRatio float64
+		throttles    int
+		wantRate     float64
+	}{
+		{name: "halves on each throttle", minRateRatio: 0.1, throttles: 2, wantRate: 2.5},
+		{name: "stops at the configured floor", minRateRatio: 0.1, throttles: 10, wantRate: 1},
+		{name: "zero ratio still keeps a positive floor", minRateRatio: 0, throttles: 20, wantRate: 10 * minRateRatio},
+		{name: "negative ratio still keeps a positive floor", minRateRatio: -1, throttles: 20, wantRate: 10 * minRateRatio},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewRateLimitConfig()
+			cfg.MinRateRatio = tt.minRateRatio
+			cfg.RecoveryPerSecond = 0
+			b := newTokenBucket(10, cfg)
+
+			for i := 0; i < tt.throttles; i++ {
+				b.throttle(time.Nanosecond)
+			}
+			if !approx(b.rate, tt.wantRate) {
+				t.Errorf("rate = %v, want %v", b.rate, tt.wantRate)
+			}
+			if b.throttled != uint64(tt.throttles) {
+				t.Errorf("throttled = %d, want %d", b.throttled, tt.throttles)
+			}
+
+			// 暫停結束後等待時間必須是有限的正數
+			b.pausedUntil = time.Time{}
+			if delay := b.reserve(); delay <= 0 || delay > time.Duration(float64(time.Second)/tt.wantRate)+time.Millisecond {
+				t.Errorf("delay = %v after backoff", delay)
+			}
+		})
+	}
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		backoffFactor float64
+		minRateRatio  float64
+		wantErr       bool
+	}{
+		{name: "defaults", backoffFactor: 0.5, minRateRatio: 0.1},
+		{name: "no backoff", backoffFactor: 1, minRateRatio: 1},
+		{name: "zero min rate", backoffFactor: 0.5, minRateRatio: 0, wantErr: true},
+		{name: "negative min rate", backoffFactor: 0.5, minRateRatio: -0.1, wantErr: true},
+		{name: "min rate above limit", backoffFactor: 0.5, minRateRatio: 1.5, wantErr: true},
+		{name: "zero backoff factor", backoffFactor: 0, minRateRatio: 0.1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewRateLimitConfig()
+			cfg.BackoffFactor = tt.backoffFactor
+			cfg.MinRateRatio = tt.minRateRatio
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func approx(got, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
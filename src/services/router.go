@@ -0,0 +1,37 @@
+package services
+
+import (
+	"solana/src/models"
+)
+
+// TopicRouter 按交易調用的程序將交易消息分發到對應的 topic
+type TopicRouter struct {
+	routes map[string][]string
+}
+
+// NewTopicRouter 沒有配置路由時返回 nil，nil 的 TopicRouter 不匹配任何 topic
+func NewTopicRouter(routes map[string][]string) *TopicRouter {
+	if len(routes) == 0 {
+		return nil
+	}
+	return &TopicRouter{routes: routes}
+}
+
+// Topics 返回交易匹配的所有 topic，同一 topic 只出現一次
+func (r *TopicRouter) Topics(tx *models.TransactionInfo) []string {
+	if r == nil {
+		return nil
+	}
+
+	var topics []string
+	seen := make(map[string]bool)
+	for _, program := range tx.Programs {
+		for _, topic := range r.routes[program] {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return topics
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"solana/src/config"
+)
+
+type rpcEndpoint struct {
+	url                 string
+	latency             time.Duration // 延遲的指數移動平均
+	errorRate           float64       // 錯誤率的指數移動平均
+	totalRequests       uint64
+	totalFailures       uint64
+	consecutiveFailures int
+	lastSlot            uint64
+	lastSlotTime        time.Time
+	healthy             bool
+	unhealthySince      time.Time
+	limiter             *rateLimiter
+	mutex               sync.RWMutex
+}
+
+// EndpointStats 端點健康狀態快照
+type EndpointStats struct {
+	URL          string  `json:"url"`
+	Healthy      bool    `json:"healthy"`
+	LatencyMs    float64 `json:"latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+	LastSlot     uint64  `json:"last_slot"`
+	SlotLag      uint64  `json:"slot_lag"`
+	Requests     uint64  `json:"requests"`
+	Failures     uint64  `json:"failures"`
+	Score        float64 `json:"score"`
+	LastProbeAgo string  `json:"last_probe_ago"`
+}
+
+type RPCPool struct {
+	endpoints []*rpcEndpoint
+	config    *config.RPCConfig
+	probe     func(ep *rpcEndpoint) (uint64, error)
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewRPCPool(cfg *config.RPCConfig) *RPCPool {
+	endpoints := make([]*rpcEndpoint, 0, len(cfg.Endpoints))
+	for _, url := range cfg.Endpoints {
+		endpoints = append(endpoints, &rpcEndpoint{
+			url:     url,
+			healthy: true,
+			limiter: newRateLimiter(cfg.RateLimit),
+		})
+	}
+
+	return &RPCPool{
+		endpoints: endpoints,
+		config:    cfg,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start 啟動背景健康探測，probe 用於查詢端點目前的 slot
+func (p *RPCPool) Start(probe func(ep *rpcEndpoint) (uint64, error)) {
+	p.probe = probe
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.probeAll()
+
+		ticker := time.NewTicker(p.config.ProbeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.probeAll()
+			case <-p.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (p *RPCPool) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+	p.wg.Wait()
+}
+
+func (p *RPCPool) probeAll() {
+	var wg sync.WaitGroup
+	for _, ep := range p.endpoints {
+		wg.Add(1)
+		go func(ep *rpcEndpoint) {
+			defer wg.Done()
+			// 延遲與錯誤已在請求時記錄，這裡只需更新 slot
+			if slot, err := p.probe(ep); err == nil {
+				p.recordSlot(ep, slot)
+			}
+		}(ep)
+	}
+	wg.Wait()
+}
+
+// candidates 按健康程度排序返回端點：健康且新鮮的在前，
+// 冷卻期已過的不健康端點作為恢復探測放在其後，其餘排在最後作為最終手段
+func (p *RPCPool) candidates() []*rpcEndpoint {
+	maxSlot := p.maxSlot()
+	now := time.Now()
+
+	type scored struct {
+		ep    *rpcEndpoint
+		tier  int
+		score float64
+	}
+	list := make([]scored, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		ep.mutex.RLock()
+		tier := 0
+		switch {
+		case ep.healthy && slotLag(maxSlot, ep.lastSlot) <= p.config.MaxSlotLag:
+			tier = 0
+		case now.Sub(ep.unhealthySince) >= p.config.RecoveryCooldown:
+			tier = 1
+		default:
+			tier = 2
+		}
+		score := p.score(ep, maxSlot)
+		ep.mutex.RUnlock()
+		list = append(list, scored{ep: ep, tier: tier, score: score})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].tier != list[j].tier {
+			return list[i].tier < list[j].tier
+		}
+		return list[i].score < list[j].score
+	})
+
+	result := make([]*rpcEndpoint, len(list))
+	for i, s := range list {
+		result[i] = s.ep
+	}
+	return result
+}
+
+// score 分數越低越好，調用前需持有 ep.mutex
+func (p *RPCPool) score(ep *rpcEndpoint, maxSlot uint64) float64 {
+	latencyMs := float64(ep.latency.Microseconds()) / 1000
+	lag := float64(slotLag(maxSlot, ep.lastSlot))
+	return latencyMs*(1+ep.errorRate*4) + lag*p.config.SlotLagPenalty
+}
+
+func (p *RPCPool) maxSlot() uint64 {
+	var max uint64
+	for _, ep := range p.endpoints {
+		ep.mutex.RLock()
+		if ep.lastSlot > max {
+			max = ep.lastSlot
+		}
+		ep.mutex.RUnlock()
+	}
+	return max
+}
+
+func (p *RPCPool) recordSuccess(ep *rpcEndpoint, latency time.Duration) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	ep.totalRequests++
+	ep.consecutiveFailures = 0
+	ep.errorRate = (1 - p.config.LatencyAlpha) * ep.errorRate
+	p.updateLatency(ep, latency)
+	ep.healthy = true
+}
+
+func (p *RPCPool) recordFailure(ep *rpcEndpoint, latency time.Duration) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	ep.totalRequests++
+	ep.totalFailures++
+	ep.consecutiveFailures++
+	ep.errorRate = (1-p.config.LatencyAlpha)*ep.errorRate + p.config.LatencyAlpha
+	p.updateLatency(ep, latency)
+
+	if ep.consecutiveFailures >= p.config.MaxFailures {
+		// 冷卻期間內再次失敗則重新計時
+		ep.healthy = false
+		ep.unhealthySince = time.Now()
+	}
+}
+
+func (p *RPCPool) recordSlot(ep *rpcEndpoint, slot uint64) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	ep.lastSlot = slot
+	ep.lastSlotTime = time.Now()
+}
+
+func (p *RPCPool) updateLatency(ep *rpcEndpoint, latency time.Duration) {
+	if ep.latency == 0 {
+		ep.latency = latency
+		return
+	}
+	alpha := p.config.LatencyAlpha
+	ep.latency = time.Duration((1-alpha)*float64(ep.latency) + alpha*float64(latency))
+}
+
+// Stats 獲取所有端點的健康狀態
+func (p *RPCPool) Stats() []EndpointStats {
+	maxSlot := p.maxSlot()
+	stats := make([]EndpointStats, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		ep.mutex.RLock()
+		lastProbeAgo := "never"
+		if !ep.lastSlotTime.IsZero() {
+			lastProbeAgo = time.Since(ep.lastSlotTime).Truncate(time.Millisecond).String()
+		}
+		stats = append(stats, EndpointStats{
+			URL:          ep.url,
+			Healthy:      ep.healthy,
+			LatencyMs:    float64(ep.latency.Microseconds()) / 1000,
+			ErrorRate:    ep.errorRate,
+			LastSlot:     ep.lastSlot,
+			SlotLag:      slotLag(maxSlot, ep.lastSlot),
+			Requests:     ep.totalRequests,
+			Failures:     ep.totalFailures,
+			Score:        p.score(ep, maxSlot),
+			LastProbeAgo: lastProbeAgo,
+		})
+		ep.mutex.RUnlock()
+	}
+	return stats
+}
+
+// RateLimitStats 獲取所有端點的限流狀態
+func (p *RPCPool) RateLimitStats() []RateLimitStats {
+	stats := make([]RateLimitStats, 0)
+	for _, ep := range p.endpoints {
+		stats = append(stats, ep.limiter.stats(ep.url)...)
+	}
+	return stats
+}
+
+func slotLag(maxSlot, slot uint64) uint64 {
+	if slot >= maxSlot {
+		return 0
+	}
+	return maxSlot - slot
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"solana/src/config"
+)
+
+// s3UploadTimeout 單個文件上傳的超時
+const s3UploadTimeout = 30 * time.Minute
+
+// S3Client 上傳文件到 S3 兼容的對象存儲，使用路徑風格的地址，兼容 MinIO 等自建服務
+type S3Client struct {
+	config *config.S3Config
+	client *minio.Client
+}
+
+func NewS3Client(cfg *config.S3Config) (*S3Client, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       endpoint.Scheme == "https",
+		Region:       cfg.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %v", err)
+	}
+	return &S3Client{config: cfg, client: client}, nil
+}
+
+// PutFile 上傳本地文件，key 會加上配置的前綴
+func (c *S3Client) PutFile(key, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3UploadTimeout)
+	defer cancel()
+
+	key = strings.TrimPrefix(c.config.Prefix+key, "/")
+	if _, err := c.client.FPutObject(ctx, c.config.Bucket, key, path, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to upload %s: %v", key, err)
+	}
+	return nil
+}
+
+// RemoveFile 刪除對象，key 會加上配置的前綴。對象不存在時不報錯
+func (c *S3Client) RemoveFile(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3UploadTimeout)
+	defer cancel()
+
+	key = strings.TrimPrefix(c.config.Prefix+key, "/")
+	if err := c.client.RemoveObject(ctx, c.config.Bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove %s: %v", key, err)
+	}
+	return nil
+}
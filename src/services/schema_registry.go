@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// schemaRegistryContentType Confluent schema registry API 使用的內容類型
+const schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+// SchemaRegistryClient Confluent 兼容的 schema registry 客戶端，只實現註冊與查詢
+type SchemaRegistryClient struct {
+	url    string
+	client *http.Client
+}
+
+func NewSchemaRegistryClient(registryURL string) *SchemaRegistryClient {
+	return &SchemaRegistryClient{
+		url:    strings.TrimRight(registryURL, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// RegisteredSchema registry 中某個版本的 schema
+type RegisteredSchema struct {
+	Subject    string `json:"subject,omitempty"`
+	Version    int    `json:"version,omitempty"`
+	ID         int    `json:"id"`
+	SchemaType string `json:"schemaType,omitempty"`
+	Schema     string `json:"schema"`
+}
+
+// registryError registry 返回的錯誤
+type registryError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// Register 在 subject 下註冊 schema，已存在相同 schema 時返回原有 ID
+func (c *SchemaRegistryClient) Register(subject, schemaType, schema string) (int, error) {
+	body, err := json.Marshal(RegisteredSchema{SchemaType: schemaType, Schema: schema})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal schema: %v", err)
+	}
+
+	var result RegisteredSchema
+	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject))
+	if err := c.do(http.MethodPost, path, body, &result); err != nil {
+		return 0, fmt.Errorf("failed to register schema for %s: %v", subject, err)
+	}
+	return result.ID, nil
+}
+
+// SchemaByID 按 ID 查詢 schema
+func (c *SchemaRegistryClient) SchemaByID(id int) (*RegisteredSchema, error) {
+	var result RegisteredSchema
+	if err := c.do(http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get schema %d: %v", id, err)
+	}
+	result.ID = id
+	return &result, nil
+}
+
+func (c *SchemaRegistryClient) do(method, path string, body []byte, result interface{}) error {
+	req, err := http.NewRequest(method, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", schemaRegistryContentType)
+	req.Header.Set("Accept", schemaRegistryContentType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var regErr registryError
+		if json.Unmarshal(data, &regErr) == nil && regErr.Message != "" {
+			return fmt.Errorf("registry error %d: %s", regErr.ErrorCode, regErr.Message)
+		}
+		return fmt.Errorf("registry returned status %d", resp.StatusCode)
+	}
+	return json.Unmarshal(data, result)
+}
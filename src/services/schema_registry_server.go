@@ -0,0 +1,207 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// SchemaRegistryServer 本地使用的 schema registry，實現 Confluent API 中註冊與查詢的部分，
+// 不做兼容性檢查。path 不為空時每次註冊後保存到文件
+type SchemaRegistryServer struct {
+	path     string
+	schemas  []RegisteredSchema // 按 ID 順序，ID 從 1 開始
+	subjects map[string][]int   // subject 各版本對應的 schema ID
+	mutex    sync.RWMutex
+}
+
+// registryState 保存到文件的內容
+type registryState struct {
+	Schemas  []RegisteredSchema `json:"schemas"`
+	Subjects map[string][]int   `json:"subjects"`
+}
+
+func NewSchemaRegistryServer(path string) (*SchemaRegistryServer, error) {
+	s := &SchemaRegistryServer{path: path, subjects: make(map[string][]int)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry file: %v", err)
+	}
+
+	var state registryState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse registry file: %v", err)
+	}
+	s.schemas = state.Schemas
+	if state.Subjects != nil {
+		s.subjects = state.Subjects
+	}
+	return s, nil
+}
+
+// Handler 返回 registry 的 HTTP 路由
+func (s *SchemaRegistryServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subjects/{subject}/versions", s.handleRegister)
+	mux.HandleFunc("GET /subjects", s.handleSubjects)
+	mux.HandleFunc("GET /subjects/{subject}/versions", s.handleVersions)
+	mux.HandleFunc("GET /subjects/{subject}/versions/{version}", s.handleVersion)
+	mux.HandleFunc("GET /schemas/ids/{id}", s.handleSchema)
+	return mux
+}
+
+func (s *SchemaRegistryServer) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req RegisteredSchema
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Schema == "" {
+		writeRegistryError(w, http.StatusUnprocessableEntity, 42201, "Invalid schema")
+		return
+	}
+	if req.SchemaType == "" {
+		req.SchemaType = "AVRO"
+	}
+
+	id, err := s.register(r.PathValue("subject"), req.SchemaType, req.Schema)
+	if err != nil {
+		writeRegistryError(w, http.StatusInternalServerError, 50001, err.Error())
+		return
+	}
+	writeRegistryJSON(w, map[string]int{"id": id})
+}
+
+// register 相同 schema 共用同一個 ID，subject 最新版本相同時不新增版本
+func (s *SchemaRegistryServer) register(subject, schemaType, schema string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id := 0
+	for _, existing := range s.schemas {
+		if existing.SchemaType == schemaType && existing.Schema == schema {
+			id = existing.ID
+			break
+		}
+	}
+	if id == 0 {
+		id = len(s.schemas) + 1
+		s.schemas = append(s.schemas, RegisteredSchema{ID: id, SchemaType: schemaType, Schema: schema})
+	}
+
+	for _, versionID := range s.subjects[subject] {
+		if versionID == id {
+			return id, nil
+		}
+	}
+	s.subjects[subject] = append(s.subjects[subject], id)
+	return id, s.save()
+}
+
+// save 調用前需持有 mutex
+func (s *SchemaRegistryServer) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(registryState{Schemas: s.schemas, Subjects: s.subjects}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write registry file: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *SchemaRegistryServer) handleSubjects(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	subjects := make([]string, 0, len(s.subjects))
+	for subject := range s.subjects {
+		subjects = append(subjects, subject)
+	}
+	s.mutex.RUnlock()
+
+	sort.Strings(subjects)
+	writeRegistryJSON(w, subjects)
+}
+
+func (s *SchemaRegistryServer) handleVersions(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	ids, ok := s.subjects[r.PathValue("subject")]
+	s.mutex.RUnlock()
+	if !ok {
+		writeRegistryError(w, http.StatusNotFound, 40401, "Subject not found")
+		return
+	}
+
+	versions := make([]int, len(ids))
+	for i := range ids {
+		versions[i] = i + 1
+	}
+	writeRegistryJSON(w, versions)
+}
+
+func (s *SchemaRegistryServer) handleVersion(w http.ResponseWriter, r *http.Request) {
+	subject := r.PathValue("subject")
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ids, ok := s.subjects[subject]
+	if !ok {
+		writeRegistryError(w, http.StatusNotFound, 40401, "Subject not found")
+		return
+	}
+
+	version := len(ids)
+	if value := r.PathValue("version"); value != "latest" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > len(ids) {
+			writeRegistryError(w, http.StatusNotFound, 40402, "Version not found")
+			return
+		}
+		version = parsed
+	}
+
+	schema := s.schemas[ids[version-1]-1]
+	schema.Subject = subject
+	schema.Version = version
+	writeRegistryJSON(w, schema)
+}
+
+func (s *SchemaRegistryServer) handleSchema(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if err != nil || id < 1 || id > len(s.schemas) {
+		writeRegistryError(w, http.StatusNotFound, 40403, "Schema not found")
+		return
+	}
+	schema := s.schemas[id-1]
+	writeRegistryJSON(w, RegisteredSchema{SchemaType: schema.SchemaType, Schema: schema.Schema})
+}
+
+func writeRegistryJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", schemaRegistryContentType)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error writing registry response: %v", err)
+	}
+}
+
+func writeRegistryError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", schemaRegistryContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(registryError{ErrorCode: code, Message: message})
+}
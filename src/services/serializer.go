@@ -0,0 +1,103 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"solana/src/config"
+	"solana/src/models"
+	"solana/src/schema"
+)
+
+// Serializer 將消息編碼為發送到 Kafka 的格式，topic 用於確定 schema registry 中的 subject
+type Serializer interface {
+	Block(topic string, message *models.BlockMessage) ([]byte, error)
+	Transaction(topic string, message *models.TransactionMessage) ([]byte, error)
+	Event(topic string, event *models.SlotEvent) ([]byte, error)
+}
+
+// NewSerializer 按配置創建序列化器
+func NewSerializer(cfg *config.KafkaConfig) Serializer {
+	if cfg.Serialization != config.SerializationProtobuf {
+		return JSONSerializer{}
+	}
+
+	var registry *SchemaRegistryClient
+	if cfg.SchemaRegistryURL != "" {
+		registry = NewSchemaRegistryClient(cfg.SchemaRegistryURL)
+	}
+	return NewProtobufSerializer(registry)
+}
+
+// JSONSerializer 以 JSON 編碼消息
+type JSONSerializer struct{}
+
+func (JSONSerializer) Block(topic string, message *models.BlockMessage) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (JSONSerializer) Transaction(topic string, message *models.TransactionMessage) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (JSONSerializer) Event(topic string, event *models.SlotEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// ProtobufSerializer 以 schema.Proto 定義的格式編碼消息。配置了 registry 時，
+// 第一次發送到某個 topic 前以 <topic>-value 為 subject 註冊 schema，消息按 Confluent 格式帶上 schema ID
+type ProtobufSerializer struct {
+	registry *SchemaRegistryClient
+	ids      map[string]int // subject 到 schema ID
+	mutex    sync.Mutex
+}
+
+func NewProtobufSerializer(registry *SchemaRegistryClient) *ProtobufSerializer {
+	return &ProtobufSerializer{registry: registry, ids: make(map[string]int)}
+}
+
+func (s *ProtobufSerializer) Block(topic string, message *models.BlockMessage) ([]byte, error) {
+	return s.frame(topic, schema.BlockMessage, schema.MarshalBlock(message))
+}
+
+func (s *ProtobufSerializer) Transaction(topic string, message *models.TransactionMessage) ([]byte, error) {
+	return s.frame(topic, schema.TransactionMessage, schema.MarshalTransaction(message))
+}
+
+func (s *ProtobufSerializer) Event(topic string, event *models.SlotEvent) ([]byte, error) {
+	return s.frame(topic, schema.SlotEvent, schema.MarshalEvent(event))
+}
+
+func (s *ProtobufSerializer) frame(topic, message string, payload []byte) ([]byte, error) {
+	if s.registry == nil {
+		return payload, nil
+	}
+
+	id, err := s.schemaID(topic + "-value")
+	if err != nil {
+		return nil, err
+	}
+	index, err := schema.MessageIndex(message)
+	if err != nil {
+		return nil, err
+	}
+	return schema.Frame(id, index, payload), nil
+}
+
+// schemaID 註冊結果按 subject 緩存，註冊失敗時下次發送重試
+func (s *ProtobufSerializer) schemaID(subject string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if id, ok := s.ids[subject]; ok {
+		return id, nil
+	}
+
+	id, err := s.registry.Register(subject, "PROTOBUF", schema.Proto)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register %s schema: %v", schema.Version, err)
+	}
+	s.ids[subject] = id
+	return id, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+
+	"solana/src/models"
+)
+
+// Sink 區塊數據的輸出目標。Publish 方法返回 nil 表示數據已交給 Sink，
+// 異步 Sink 另外通過 DeliveryTracker 報告最終結果；Flush 返回後之前發佈的數據已寫出
+type Sink interface {
+	PublishBlock(block *models.BlockMessage) error
+	PublishTransaction(transaction *models.TransactionMessage) error
+	PublishEvent(event *models.SlotEvent) error
+	PublishDeadLetter(letter *models.DeadLetter) error
+	Flush() error
+	Close() error
+}
+
+// AsyncSink 異步確認的 Sink，Async 為 true 時需要設置 Tracker 才能知道數據何時寫出
+type AsyncSink interface {
+	Sink
+	Async() bool
+	SetTracker(tracker DeliveryTracker)
+}
+
+// TransactionalSink 能在同一事務中寫入區塊與進度的 Sink
+type TransactionalSink interface {
+	Sink
+	Transactional() bool
+	PublishBlockWithCheckpoint(block *models.BlockMessage, snapshot func() *models.Checkpoint) error
+	PublishEventWithCheckpoint(event *models.SlotEvent, snapshot func() *models.Checkpoint) error
+	CheckpointStore() CheckpointStore
+}
+
+// 寫入 stdout 和文件時每行的類型
+const (
+	RecordBlock       = "block"
+	RecordTransaction = "transaction"
+	RecordEvent       = "event"
+	RecordDeadLetter  = "dead_letter"
+)
+
+// SinkRecord stdout 和文件 Sink 每行寫入的內容
+type SinkRecord struct {
+	Type string      `json:"type"`
+	Slot uint64      `json:"slot"`
+	Data interface{} `json:"data"`
+}
+
+// orphanedSlots 事件中數據已不在鏈上的 slot：重組時被孤立的 slot，以及未能進入最終鏈的 slot。
+// 之後重新發送的區塊會重新寫入
+func orphanedSlots(event *models.SlotEvent) []uint64 {
+	switch event.Type {
+	case models.EventReorg:
+		return event.OrphanedSlots
+	case models.EventRolledBack:
+		return []uint64{event.Slot}
+	}
+	return nil
+}
+
+// MultiSink 將數據同時發佈到多個 Sink。任一 Sink 失敗時返回錯誤，
+// 重試時已成功的 Sink 會再收到一次同樣的數據
+type MultiSink struct {
+	sinks []Sink
+}
+
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+func (m *MultiSink) PublishBlock(block *models.BlockMessage) error {
+	return m.each(func(sink Sink) error { return sink.PublishBlock(block) })
+}
+
+func (m *MultiSink) PublishTransaction(transaction *models.TransactionMessage) error {
+	return m.each(func(sink Sink) error { return sink.PublishTransaction(transaction) })
+}
+
+func (m *MultiSink) PublishEvent(event *models.SlotEvent) error {
+	return m.each(func(sink Sink) error { return sink.PublishEvent(event) })
+}
+
+func (m *MultiSink) PublishDeadLetter(letter *models.DeadLetter) error {
+	return m.each(func(sink Sink) error { return sink.PublishDeadLetter(letter) })
+}
+
+func (m *MultiSink) Flush() error {
+	return m.each(func(sink Sink) error { return sink.Flush() })
+}
+
+func (m *MultiSink) Close() error {
+	return m.each(func(sink Sink) error { return sink.Close() })
+}
+
+// Async 任一 Sink 為異步時返回 true
+func (m *MultiSink) Async() bool {
+	for _, sink := range m.sinks {
+		if async, ok := sink.(AsyncSink); ok && async.Async() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTracker 設置到所有異步的 Sink，每條消息的確認分別計數
+func (m *MultiSink) SetTracker(tracker DeliveryTracker) {
+	for _, sink := range m.sinks {
+		if async, ok := sink.(AsyncSink); ok && async.Async() {
+			async.SetTracker(tracker)
+		}
+	}
+}
+
+// each 對每個 Sink 調用 fn，一個失敗不影響其他 Sink
+func (m *MultiSink) each(fn func(sink Sink) error) error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := fn(sink); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
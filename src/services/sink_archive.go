@@ -0,0 +1,378 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"solana/src/config"
+	"solana/src/models"
+)
+
+// archiveCheckInterval 檢查時段是否結束、重試上傳的間隔
+const archiveCheckInterval = 10 * time.Second
+
+// 時段名稱的格式
+const (
+	archiveDayLayout  = "2006-01-02"
+	archiveHourLayout = "2006-01-02T15"
+)
+
+// ArchiveSink 將區塊、交易和餘額變化按時段歸檔為 Parquet 文件，用於長期保存。
+// 時段以區塊時間劃分，最新區塊的時間超過時段結束加上等待時間後生成文件，
+// 每個文件的 slot 範圍記錄在 manifest.json 中，可用於檢查缺失的區塊。
+// 時段生成後收到遲到的數據或重組事件時，重新生成整個時段的文件，每個時段每個數據集只有一個文件。
+// 配置了 S3 時文件生成後上傳，被替換的舊文件在上傳清單後刪除，失敗會定期重試
+type ArchiveSink struct {
+	config   *config.ArchiveConfig
+	manifest *ArchiveManifest
+	uploader *S3Client               // 為空時只保存在本地
+	stages   map[int64]*archiveStage // 按時段開始時間的 Unix 秒索引
+	sealed   []*archiveStage         // 不再寫入、等待生成 Parquet 的暫存
+	orphans  *archiveOrphans         // 重組和回滾丟棄的 slot
+	latest   time.Time               // 已收到的最新區塊時間
+	pending  bool                    // 清單有更新尚未上傳，只在 loop 中使用
+	sequence uint64
+	mutex    sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewArchiveSink(cfg *config.ArchiveConfig) (*ArchiveSink, error) {
+	if err := os.MkdirAll(filepath.Join(cfg.Dir, "staging"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %v", err)
+	}
+	manifest, err := LoadArchiveManifest(filepath.Join(cfg.Dir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	orphans, err := openArchiveOrphans(filepath.Join(cfg.Dir, "staging", "orphans.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ArchiveSink{
+		config:   cfg,
+		manifest: manifest,
+		stages:   make(map[int64]*archiveStage),
+		orphans:  orphans,
+		stopChan: make(chan struct{}),
+	}
+	if cfg.S3.Endpoint != "" {
+		if s.uploader, err = NewS3Client(cfg.S3); err != nil {
+			orphans.close()
+			return nil, err
+		}
+	}
+	if err := s.restore(); err != nil {
+		orphans.close()
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+func (s *ArchiveSink) PublishBlock(block *models.BlockMessage) error {
+	blockTime := archiveTime(block.BlockTime)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stage, err := s.stage(blockTime)
+	if err != nil {
+		return err
+	}
+	sequence := s.nextSequence()
+	if err := stage.write(ArchiveBlocks, sequence, newArchiveBlockRow(block, blockTime)); err != nil {
+		return err
+	}
+	for i := range block.Transactions {
+		if err := s.writeTransaction(stage, sequence, NewTransactionMessage(block, i), blockTime); err != nil {
+			return err
+		}
+	}
+
+	if blockTime.After(s.latest) {
+		s.latest = blockTime
+	}
+	return nil
+}
+
+func (s *ArchiveSink) PublishTransaction(transaction *models.TransactionMessage) error {
+	blockTime := archiveTime(transaction.BlockTime)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stage, err := s.stage(blockTime)
+	if err != nil {
+		return err
+	}
+	return s.writeTransaction(stage, s.nextSequence(), transaction, blockTime)
+}
+
+// PublishEvent 事件不寫入，重組和回滾丟棄的 slot 記錄為墓碑：暫存中更早寫入的數據在生成時跳過，
+// 已生成文件的時段重新打開暫存，下次檢查時重寫
+func (s *ArchiveSink) PublishEvent(event *models.SlotEvent) error {
+	slots := orphanedSlots(event)
+	if len(slots) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.orphans.add(slots, s.nextSequence()); err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		s.reopen(slot)
+	}
+	return nil
+}
+
+// PublishDeadLetter 死信不寫入
+func (s *ArchiveSink) PublishDeadLetter(letter *models.DeadLetter) error {
+	return nil
+}
+
+// Flush 將暫存數據同步到磁盤
+func (s *ArchiveSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, stage := range s.stages {
+		if err := stage.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 保留未結束時段的暫存數據，重啟後繼續寫入
+func (s *ArchiveSink) Close() error {
+	close(s.stopChan)
+	s.wg.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var firstErr error
+	for _, stage := range s.stages {
+		if err := stage.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := s.orphans.close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// Manifest 已生成的 Parquet 文件清單
+func (s *ArchiveSink) Manifest() *ArchiveManifest {
+	return s.manifest
+}
+
+// writeTransaction 調用前需持有 mutex
+func (s *ArchiveSink) writeTransaction(stage *archiveStage, sequence uint64, transaction *models.TransactionMessage, blockTime time.Time) error {
+	if err := stage.write(ArchiveTransactions, sequence, newArchiveTransactionRow(transaction, blockTime)); err != nil {
+		return err
+	}
+	for _, row := range newArchiveBalanceChangeRows(transaction, blockTime) {
+		if err := stage.write(ArchiveBalanceChanges, sequence, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stage 返回區塊時間所在時段的暫存，已生成文件的時段收到遲到的數據時會創建新的暫存，
+// 生成時與已有的文件合併。調用前需持有 mutex
+func (s *ArchiveSink) stage(blockTime time.Time) (*archiveStage, error) {
+	start := blockTime.Truncate(s.config.PeriodLength())
+	if stage, ok := s.stages[start.Unix()]; ok {
+		return stage, nil
+	}
+
+	dir := filepath.Join(s.config.Dir, "staging", archiveStageName(start, s.nextSequence()))
+	stage, err := openArchiveStage(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.stages[start.Unix()] = stage
+	return stage, nil
+}
+
+// reopen 為已生成且 slot 範圍包含 slot 的時段打開暫存，使其按墓碑重寫。調用前需持有 mutex
+func (s *ArchiveSink) reopen(slot uint64) {
+	for _, file := range s.manifest.Files() {
+		if slot < file.FromSlot || slot > file.ToSlot {
+			continue
+		}
+		start, err := s.periodStart(file.Period)
+		if err != nil {
+			log.Printf("Error reopening archive period for orphaned slot %d: %v", slot, err)
+			continue
+		}
+		if _, err := s.stage(start); err != nil {
+			log.Printf("Error reopening archive period %s: %v", file.Period, err)
+		}
+	}
+}
+
+// nextSequence 單調遞增的序號，使用納秒時間使重啟後的序號仍大於之前的。調用前需持有 mutex
+func (s *ArchiveSink) nextSequence() uint64 {
+	sequence := uint64(time.Now().UnixNano())
+	if sequence <= s.sequence {
+		sequence = s.sequence + 1
+	}
+	s.sequence = sequence
+	return sequence
+}
+
+// restore 重新打開上次運行留下的暫存，同一時段有多個暫存時只有最新的繼續寫入
+func (s *ArchiveSink) restore() error {
+	entries, err := os.ReadDir(filepath.Join(s.config.Dir, "staging"))
+	if err != nil {
+		return fmt.Errorf("failed to read archive staging: %v", err)
+	}
+
+	// 目錄名按時段和序號排序，後打開的暫存更新
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		stage, err := openArchiveStage(filepath.Join(s.config.Dir, "staging", entry.Name()))
+		if err != nil {
+			return err
+		}
+		if sequence := parseStageSequence(entry.Name()); sequence > s.sequence {
+			s.sequence = sequence
+		}
+		// 新暫存創建前舊暫存已停止寫入，以新暫存的序號作為舊暫存的結束序號
+		if previous, ok := s.stages[stage.start.Unix()]; ok {
+			previous.close()
+			previous.sealedAt = parseStageSequence(entry.Name())
+			s.sealed = append(s.sealed, previous)
+		}
+		s.stages[stage.start.Unix()] = stage
+		log.Printf("Resuming archive stage %s", entry.Name())
+	}
+	return nil
+}
+
+func (s *ArchiveSink) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(archiveCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.finalizeEnded()
+			if s.uploader != nil {
+				s.upload()
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// finalizeEnded 為已結束的時段生成 Parquet 文件。轉換在 mutex 外進行，不阻塞寫入
+func (s *ArchiveSink) finalizeEnded() {
+	s.mutex.Lock()
+	for key, stage := range s.stages {
+		if s.latest.Before(stage.start.Add(s.config.PeriodLength() + s.config.Grace)) {
+			continue
+		}
+		if err := stage.close(); err != nil {
+			log.Printf("Error closing archive stage %s: %v", stage.dir, err)
+			continue
+		}
+		delete(s.stages, key)
+		stage.sealedAt = s.nextSequence()
+		s.sealed = append(s.sealed, stage)
+	}
+	sealed := s.sealed
+	s.sealed = nil
+	s.mutex.Unlock()
+
+	for i, stage := range sealed {
+		if err := s.finalize(stage); err != nil {
+			log.Printf("Error archiving %s: %v", stage.dir, err)
+			s.mutex.Lock()
+			s.sealed = append(s.sealed, sealed[i:]...)
+			s.mutex.Unlock()
+			return
+		}
+		period, _ := s.partition(stage.start)
+		log.Printf("Archived period %s", period)
+	}
+}
+
+// upload 上傳尚未上傳的文件，之後上傳最新的清單，最後刪除已被替換的文件。失敗時等待下次重試
+func (s *ArchiveSink) upload() {
+	for _, file := range s.manifest.Files() {
+		if file.Uploaded {
+			continue
+		}
+		if err := s.uploader.PutFile(file.Path, filepath.Join(s.config.Dir, filepath.FromSlash(file.Path))); err != nil {
+			log.Printf("Error uploading archive file: %v", err)
+			return
+		}
+		if err := s.manifest.MarkUploaded(file.Path); err != nil {
+			log.Printf("Error saving archive manifest: %v", err)
+			return
+		}
+		s.pending = true
+	}
+
+	if s.pending {
+		if err := s.uploader.PutFile("manifest.json", filepath.Join(s.config.Dir, "manifest.json")); err != nil {
+			log.Printf("Error uploading archive manifest: %v", err)
+			return
+		}
+		s.pending = false
+	}
+
+	// 清單已不再引用這些文件，刪除後清單的變化在下次上傳時帶上
+	for _, path := range s.manifest.Removed() {
+		if err := s.uploader.RemoveFile(path); err != nil {
+			log.Printf("Error removing replaced archive file: %v", err)
+			return
+		}
+		if err := s.manifest.MarkRemoved(path); err != nil {
+			log.Printf("Error saving archive manifest: %v", err)
+			return
+		}
+	}
+}
+
+// partition 時段的名稱和 Hive 風格的分區目錄
+func (s *ArchiveSink) partition(start time.Time) (string, string) {
+	if s.config.Period == config.ArchivePeriodHour {
+		return start.Format(archiveHourLayout), start.Format("dt=2006-01-02/hour=15")
+	}
+	return start.Format(archiveDayLayout), start.Format("dt=2006-01-02")
+}
+
+// periodStart 由時段名稱得到時段的開始時間
+func (s *ArchiveSink) periodStart(period string) (time.Time, error) {
+	layout := archiveDayLayout
+	if s.config.Period == config.ArchivePeriodHour {
+		layout = archiveHourLayout
+	}
+	start, err := time.Parse(layout, period)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid archive period %q: %v", period, err)
+	}
+	return start, nil
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"solana/src/config"
+	"solana/src/models"
+)
+
+// archiveBase 測試使用的第一個時段，按小時劃分
+var archiveBase = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestArchiveConfig(t *testing.T) *config.ArchiveConfig {
+	cfg := config.NewArchiveConfig()
+	cfg.Dir = t.TempDir()
+	cfg.Period = config.ArchivePeriodHour
+	cfg.Grace = 0
+	return cfg
+}
+
+func newTestArchiveSink(t *testing.T) *ArchiveSink {
+	t.Helper()
+	s, err := NewArchiveSink(newTestArchiveConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// publishArchiveBlock 發佈一個帶一筆交易的區塊，offset 為相對第一個時段開始的時間
+func publishArchiveBlock(t *testing.T, s *ArchiveSink, slot uint64, offset time.Duration, blockhash string) {
+	t.Helper()
+	blockTime := uint64(archiveBase.Add(offset).Unix())
+	block := &models.BlockMessage{
+		Slot:       slot,
+		ParentSlot: slot - 1,
+		BlockTime:  &blockTime,
+		Blockhash:  blockhash,
+		Transactions: []models.TransactionInfo{{
+			Signature:      fmt.Sprintf("sig-%d", slot),
+			BalanceChanges: []models.BalanceChange{{Account: "payer", PreBalance: 10, PostBalance: 5, Change: -5}},
+		}},
+	}
+	if err := s.PublishBlock(block); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// archivedRows 讀取時段所有文件中的 slot 和 blocks 的 blockhash
+func archivedRows(t *testing.T, s *ArchiveSink, dataset, period string) ([]int64, []string) {
+	t.Helper()
+	columns, _ := archiveColumns(dataset)
+	var slots []int64
+	var hashes []string
+	for _, file := range s.Manifest().Period(period) {
+		if file.Dataset != dataset {
+			continue
+		}
+		if err := VerifyArchiveFile(s.config.Dir, file); err != nil {
+			t.Fatal(err)
+		}
+		err := s.readArchivedFile(file, columns, func(values []interface{}) error {
+			slots = append(slots, values[archiveSlotColumn(columns)].(int64))
+			if dataset == ArchiveBlocks {
+				hashes = append(hashes, archiveBlockRowOf(values).Blockhash)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots, hashes
+}
+
+// finalizeArchive 發佈下一個時段的區塊使之前的時段結束，然後生成文件
+func finalizeArchive(t *testing.T, s *ArchiveSink, slot uint64) {
+	t.Helper()
+	publishArchiveBlock(t, s, slot, 90*time.Minute, "next")
+	s.finalizeEnded()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.sealed) > 0 {
+		t.Fatalf("%d stages failed to finalize", len(s.sealed))
+	}
+}
+
+func TestArchiveSinkMergesLateData(t *testing.T) {
+	s := newTestArchiveSink(t)
+	publishArchiveBlock(t, s, 1, 10*time.Minute, "a1")
+	publishArchiveBlock(t, s, 2, 10*time.Minute, "a2")
+	finalizeArchive(t, s, 100)
+
+	first := s.Manifest().Period("2026-01-01T00")
+	if len(first) != 3 {
+		t.Fatalf("%d files after the first finalize, want 3", len(first))
+	}
+
+	// 遲到的新區塊和重新發送的區塊與已有文件合併
+	publishArchiveBlock(t, s, 3, 20*time.Minute, "a3")
+	publishArchiveBlock(t, s, 2, 10*time.Minute, "b2")
+	finalizeArchive(t, s, 101)
+
+	files := s.Manifest().Period("2026-01-01T00")
+	if len(files) != 3 {
+		t.Fatalf("%d files after merging late data, want one per dataset", len(files))
+	}
+	slots, hashes := archivedRows(t, s, ArchiveBlocks, "2026-01-01T00")
+	if !reflect.DeepEqual(slots, []int64{1, 2, 3}) || !reflect.DeepEqual(hashes, []string{"a1", "b2", "a3"}) {
+		t.Errorf("blocks = %v %v, want [1 2 3] [a1 b2 a3]", slots, hashes)
+	}
+	for _, dataset := range []string{ArchiveTransactions, ArchiveBalanceChanges} {
+		if slots, _ := archivedRows(t, s, dataset, "2026-01-01T00"); !reflect.DeepEqual(slots, []int64{1, 2, 3}) {
+			t.Errorf("%s slots = %v, want [1 2 3]", dataset, slots)
+		}
+	}
+
+	// 範圍改變後舊文件被刪除
+	for _, file := range first {
+		if _, err := os.Stat(filepath.Join(s.config.Dir, filepath.FromSlash(file.Path))); !os.IsNotExist(err) {
+			t.Errorf("replaced file %s still exists", file.Path)
+		}
+	}
+}
+
+func TestArchiveSinkDropsOrphanedSlots(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *models.SlotEvent
+	}{
+		{name: "reorg", event: &models.SlotEvent{Type: models.EventReorg, Slot: 4, OrphanedSlots: []uint64{2}}},
+		{name: "rolled back", event: &models.SlotEvent{Type: models.EventRolledBack, Slot: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" before finalize", func(t *testing.T) {
+			s := newTestArchiveSink(t)
+			publishArchiveBlock(t, s, 1, 10*time.Minute, "a1")
+			publishArchiveBlock(t, s, 2, 10*time.Minute, "a2")
+			publishArchiveBlock(t, s, 3, 10*time.Minute, "a3")
+			if err := s.PublishEvent(tt.event); err != nil {
+				t.Fatal(err)
+			}
+			finalizeArchive(t, s, 100)
+
+			for _, dataset := range []string{ArchiveBlocks, ArchiveTransactions, ArchiveBalanceChanges} {
+				if slots, _ := archivedRows(t, s, dataset, "2026-01-01T00"); !reflect.DeepEqual(slots, []int64{1, 3}) {
+					t.Errorf("%s slots = %v, want [1 3]", dataset, slots)
+				}
+			}
+
+			// 墓碑之後重新發送的數據不受影響
+			publishArchiveBlock(t, s, 2, 10*time.Minute, "b2")
+			finalizeArchive(t, s, 101)
+			slots, hashes := archivedRows(t, s, ArchiveBlocks, "2026-01-01T00")
+			if !reflect.DeepEqual(slots, []int64{1, 2, 3}) || !reflect.DeepEqual(hashes, []string{"a1", "b2", "a3"}) {
+				t.Errorf("blocks = %v %v, want [1 2 3] [a1 b2 a3]", slots, hashes)
+			}
+		})
+
+		t.Run(tt.name+" after finalize", func(t *testing.T) {
+			s := newTestArchiveSink(t)
+			publishArchiveBlock(t, s, 1, 10*time.Minute, "a1")
+			publishArchiveBlock(t, s, 2, 10*time.Minute, "a2")
+			publishArchiveBlock(t, s, 3, 10*time.Minute, "a3")
+			finalizeArchive(t, s, 100)
+
+			if err := s.PublishEvent(tt.event); err != nil {
+				t.Fatal(err)
+			}
+			s.finalizeEnded()
+
+			for _, dataset := range []string{ArchiveBlocks, ArchiveTransactions, ArchiveBalanceChanges} {
+				if slots, _ := archivedRows(t, s, dataset, "2026-01-01T00"); !reflect.DeepEqual(slots, []int64{1, 3}) {
+					t.Errorf("%s slots = %v, want [1 3]", dataset, slots)
+				}
+			}
+			if files := s.Manifest().Period("2026-01-01T00"); len(files) != 3 {
+				t.Errorf("%d files after rewriting, want 3", len(files))
+			}
+		})
+	}
+}
+
+func TestArchiveSinkRestoresOrphans(t *testing.T) {
+	s, err := NewArchiveSink(newTestArchiveConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishArchiveBlock(t, s, 1, 10*time.Minute, "a1")
+	publishArchiveBlock(t, s, 2, 10*time.Minute, "a2")
+	if err := s.PublishEvent(&models.SlotEvent{Type: models.EventRolledBack, Slot: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewArchiveSink(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Close()
+	finalizeArchive(t, restarted, 100)
+
+	if slots, _ := archivedRows(t, restarted, ArchiveBlocks, "2026-01-01T00"); !reflect.DeepEqual(slots, []int64{1}) {
+		t.Errorf("blocks = %v after restart, want [1]", slots)
+	}
+}
+
+func TestArchiveManifestReplacePeriod(t *testing.T) {
+	m, err := LoadArchiveManifest(filepath.Join(t.TempDir(), "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Add(
+		ArchiveFile{Path: "blocks/a", Dataset: ArchiveBlocks, Period: "p1", Uploaded: true},
+		ArchiveFile{Path: "blocks/b", Dataset: ArchiveBlocks, Period: "p1", Uploaded: true},
+		ArchiveFile{Path: "transactions/c", Dataset: ArchiveTransactions, Period: "p1"},
+		ArchiveFile{Path: "blocks/d", Dataset: ArchiveBlocks, Period: "p2", Uploaded: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := m.ReplacePeriod("p1", []ArchiveFile{{Path: "blocks/b", Dataset: ArchiveBlocks, Period: "p1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range removed {
+		paths = append(paths, file.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"blocks/a", "transactions/c"}) {
+		t.Errorf("removed = %v, want [blocks/a transactions/c]", paths)
+	}
+	// 只有已上傳的文件需要從對象存儲刪除，同一路徑的新文件會覆蓋舊對象
+	if got := m.Removed(); !reflect.DeepEqual(got, []string{"blocks/a"}) {
+		t.Errorf("Removed() = %v, want [blocks/a]", got)
+	}
+	if files := m.Files(); len(files) != 2 {
+		t.Errorf("%d files left, want 2", len(files))
+	}
+
+	if err := m.MarkRemoved("blocks/a"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadArchiveManifest(m.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Removed(); len(got) != 0 {
+		t.Errorf("Removed() = %v after MarkRemoved, want none", got)
+	}
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"solana/src/models"
+)
+
+// clickHouseTimeout 連接、遷移和每次寫入的超時
+const clickHouseTimeout = 30 * time.Second
+
+// ClickHouseSink 將交易、指令和餘額變化寫入按天分區的 ClickHouse 表，用於分析查詢。
+// 行的內容與發送到 Kafka 的交易消息一致；數據累積成批後用原生協議批量寫入，
+// 同一簽名重複寫入時由 ReplacingMergeTree 保留最新的版本。
+// 重組和回滾事件中不在鏈上的 slot 在寫入下一批之前刪除，與 PostgreSQL 保持一致
+type ClickHouseSink struct {
+	conn         driver.Conn
+	batchSize    int
+	transactions map[string]*models.TransactionMessage
+	orphaned     map[uint64]bool // 需要刪除數據的 slot
+	mutex        sync.Mutex
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+func NewClickHouseSink(dsn string, batchSize int, flushInterval time.Duration) (*ClickHouseSink, error) {
+	options, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ClickHouse DSN: %v", err)
+	}
+	conn, err := clickhouse.Open(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ClickHouse connection: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clickHouseTimeout)
+	defer cancel()
+	if err := conn.Ping(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to ClickHouse: %v", err)
+	}
+	if err := migrateClickHouse(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s := &ClickHouseSink{
+		conn:         conn,
+		batchSize:    batchSize,
+		transactions: make(map[string]*models.TransactionMessage),
+		orphaned:     make(map[uint64]bool),
+		stopChan:     make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		s.wg.Add(1)
+		go s.flushLoop(flushInterval)
+	}
+	return s, nil
+}
+
+// PublishBlock 將區塊拆成與 Kafka 交易消息相同的行
+func (s *ClickHouseSink) PublishBlock(block *models.BlockMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := range block.Transactions {
+		transaction := NewTransactionMessage(block, i)
+		s.transactions[transaction.Signature] = transaction
+	}
+	return s.flushIfFull()
+}
+
+func (s *ClickHouseSink) PublishTransaction(transaction *models.TransactionMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.transactions[transaction.Signature] = transaction
+	return s.flushIfFull()
+}
+
+// PublishEvent 分析表只包含交易數據，事件本身不寫入。重組和回滾事件記錄需要刪除的 slot，
+// 並丟棄緩衝中這些 slot 的舊交易，之後重新發送的區塊在刪除後寫入
+func (s *ClickHouseSink) PublishEvent(event *models.SlotEvent) error {
+	slots := orphanedSlots(event)
+	if len(slots) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, slot := range slots {
+		s.orphaned[slot] = true
+		for signature, transaction := range s.transactions {
+			if transaction.Slot == slot {
+				delete(s.transactions, signature)
+			}
+		}
+	}
+	return nil
+}
+
+// PublishDeadLetter 死信不寫入
+func (s *ClickHouseSink) PublishDeadLetter(letter *models.DeadLetter) error {
+	return nil
+}
+
+// Flush 寫入累積的交易，失敗時保留數據等待下次寫入
+func (s *ClickHouseSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.flush()
+}
+
+func (s *ClickHouseSink) Close() error {
+	close(s.stopChan)
+	s.wg.Wait()
+
+	err := s.Flush()
+	if closeErr := s.conn.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close ClickHouse connection: %v", closeErr)
+	}
+	return err
+}
+
+func (s *ClickHouseSink) flushLoop(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				log.Printf("Error flushing ClickHouse sink: %v", err)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// flushIfFull 調用前需持有 mutex
+func (s *ClickHouseSink) flushIfFull() error {
+	if len(s.transactions) < s.batchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+// flush 先刪除不在鏈上的 slot，再依次寫入三張表，調用前需持有 mutex。
+// 部分表寫入成功後失敗時整批重寫，重複的行在合併時去重
+func (s *ClickHouseSink) flush() error {
+	if len(s.transactions) == 0 && len(s.orphaned) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clickHouseTimeout)
+	defer cancel()
+
+	if err := s.deleteOrphaned(ctx); err != nil {
+		return err
+	}
+	if len(s.transactions) == 0 {
+		return nil
+	}
+
+	// 以寫入時間作為版本，重寫的數據覆蓋舊數據
+	version := uint64(time.Now().UnixNano())
+	writers := []struct {
+		table  string
+		append func(batch driver.Batch, transaction *models.TransactionMessage, blockTime time.Time) error
+	}{
+		{"transactions", func(batch driver.Batch, transaction *models.TransactionMessage, blockTime time.Time) error {
+			return batch.Append(
+				transaction.Signature,
+				transaction.Slot,
+				transaction.BlockHeight,
+				blockTime,
+				transaction.Blockhash,
+				uint32(transaction.Index),
+				transaction.Commitment,
+				transaction.Status,
+				transaction.Fee,
+				transaction.ComputeUnits,
+				nonNil(transaction.AccountKeys),
+				nonNil(transaction.Programs),
+				nonNil(transaction.LogMessages),
+				version,
+			)
+		}},
+		{"instructions", func(batch driver.Batch, transaction *models.TransactionMessage, blockTime time.Time) error {
+			for i, instruction := range transaction.Instructions {
+				err := batch.Append(
+					transaction.Signature,
+					uint32(i),
+					transaction.Slot,
+					blockTime,
+					instruction.ProgramId,
+					instruction.Data,
+					nonNil(instruction.Accounts),
+					version,
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"balance_changes", func(batch driver.Batch, transaction *models.TransactionMessage, blockTime time.Time) error {
+			for _, change := range transaction.BalanceChanges {
+				err := batch.Append(
+					transaction.Signature,
+					change.Account,
+					transaction.Slot,
+					blockTime,
+					change.PreBalance,
+					change.PostBalance,
+					change.Change,
+					version,
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	}
+
+	for _, writer := range writers {
+		batch, err := s.conn.PrepareBatch(ctx, "INSERT INTO "+writer.table)
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s batch: %v", writer.table, err)
+		}
+		for _, transaction := range s.transactions {
+			if err := writer.append(batch, transaction, transactionTime(transaction)); err != nil {
+				batch.Abort()
+				return fmt.Errorf("failed to append to %s batch: %v", writer.table, err)
+			}
+		}
+		if err := batch.Send(); err != nil {
+			return fmt.Errorf("failed to insert into %s: %v", writer.table, err)
+		}
+	}
+
+	s.transactions = make(map[string]*models.TransactionMessage)
+	return nil
+}
+
+// deleteOrphaned 用輕量刪除移除不在鏈上的 slot 的行，調用前需持有 mutex
+func (s *ClickHouseSink) deleteOrphaned(ctx context.Context) error {
+	if len(s.orphaned) == 0 {
+		return nil
+	}
+
+	slots := make([]uint64, 0, len(s.orphaned))
+	for slot := range s.orphaned {
+		slots = append(slots, slot)
+	}
+	for _, table := range []string{"transactions", "instructions", "balance_changes"} {
+		if err := s.conn.Exec(ctx, "DELETE FROM "+table+" WHERE slot IN (?)", slots); err != nil {
+			return fmt.Errorf("failed to delete orphaned slots from %s: %v", table, err)
+		}
+	}
+	s.orphaned = make(map[uint64]bool)
+	return nil
+}
+
+// transactionTime 用於分區的時間。ReplacingMergeTree 只在同一分區內去重，
+// 區塊沒有時間時使用 Unix 零點而不是處理時間，重寫同一簽名時總是落在同一分區
+func transactionTime(transaction *models.TransactionMessage) time.Time {
+	if transaction.BlockTime != nil {
+		return time.Unix(int64(*transaction.BlockTime), 0)
+	}
+	return time.Unix(0, 0)
+}
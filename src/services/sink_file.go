@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"solana/src/models"
+)
+
+// FileSink 將數據以 JSON Lines 格式寫入目錄中的文件，文件超過 maxBytes 後切換到新文件。
+// 寫入經過緩衝，Flush 時才寫到磁盤
+type FileSink struct {
+	dir      string
+	prefix   string
+	maxBytes int64
+	file     *os.File
+	writer   *bufio.Writer
+	written  int64 // 當前文件已寫入的字節數
+	sequence int   // 同一秒內切換文件時區分文件名
+	mutex    sync.Mutex
+}
+
+func NewFileSink(dir, prefix string, maxBytes int64) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sink directory: %v", err)
+	}
+
+	s := &FileSink{dir: dir, prefix: prefix, maxBytes: maxBytes}
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) PublishBlock(block *models.BlockMessage) error {
+	return s.write(SinkRecord{Type: RecordBlock, Slot: block.Slot, Data: block})
+}
+
+func (s *FileSink) PublishTransaction(transaction *models.TransactionMessage) error {
+	return s.write(SinkRecord{Type: RecordTransaction, Slot: transaction.Slot, Data: transaction})
+}
+
+func (s *FileSink) PublishEvent(event *models.SlotEvent) error {
+	return s.write(SinkRecord{Type: RecordEvent, Slot: event.Slot, Data: event})
+}
+
+func (s *FileSink) PublishDeadLetter(letter *models.DeadLetter) error {
+	return s.write(SinkRecord{Type: RecordDeadLetter, Slot: letter.Slot, Data: letter})
+}
+
+// Flush 將緩衝寫入文件並同步到磁盤
+func (s *FileSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.flush()
+}
+
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+func (s *FileSink) write(record SinkRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s record: %v", record.Type, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.maxBytes > 0 && s.written > 0 && s.written+int64(len(line))+1 > s.maxBytes {
+		if err := s.flush(); err != nil {
+			return err
+		}
+		if err := s.file.Close(); err != nil {
+			return fmt.Errorf("failed to close sink file: %v", err)
+		}
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.writer.Write(append(line, '\n'))
+	s.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write %s record: %v", record.Type, err)
+	}
+	return nil
+}
+
+// rotate 打開新文件，調用前需持有 mutex
+func (s *FileSink) rotate() error {
+	name := fmt.Sprintf("%s-%s-%d.jsonl", s.prefix, time.Now().UTC().Format("20060102-150405"), s.sequence)
+	s.sequence++
+
+	file, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open sink file: %v", err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriterSize(file, 1<<20)
+	s.written = 0
+	return nil
+}
+
+// flush 調用前需持有 mutex
+func (s *FileSink) flush() error {
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush sink file: %v", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync sink file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"sync"
+
+	"solana/src/models"
+)
+
+// MemorySink 將數據保存在內存中，用於測試和不需要輸出的本地運行，
+// limit 大於 0 時每種數據只保留最近的 limit 條
+type MemorySink struct {
+	limit        int
+	blocks       []*models.BlockMessage
+	transactions []*models.TransactionMessage
+	events       []*models.SlotEvent
+	deadLetters  []*models.DeadLetter
+	mutex        sync.Mutex
+}
+
+func NewMemorySink(limit int) *MemorySink {
+	return &MemorySink{limit: limit}
+}
+
+func (s *MemorySink) PublishBlock(block *models.BlockMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.blocks = keepLast(append(s.blocks, block), s.limit)
+	return nil
+}
+
+func (s *MemorySink) PublishTransaction(transaction *models.TransactionMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.transactions = keepLast(append(s.transactions, transaction), s.limit)
+	return nil
+}
+
+func (s *MemorySink) PublishEvent(event *models.SlotEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = keepLast(append(s.events, event), s.limit)
+	return nil
+}
+
+func (s *MemorySink) PublishDeadLetter(letter *models.DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deadLetters = keepLast(append(s.deadLetters, letter), s.limit)
+	return nil
+}
+
+func (s *MemorySink) Flush() error {
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// Blocks 返回已發佈區塊的副本
+func (s *MemorySink) Blocks() []*models.BlockMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*models.BlockMessage(nil), s.blocks...)
+}
+
+func (s *MemorySink) Transactions() []*models.TransactionMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*models.TransactionMessage(nil), s.transactions...)
+}
+
+func (s *MemorySink) Events() []*models.SlotEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*models.SlotEvent(nil), s.events...)
+}
+
+func (s *MemorySink) DeadLetters() []*models.DeadLetter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*models.DeadLetter(nil), s.deadLetters...)
+}
+
+// keepLast 只保留最後 limit 個元素，limit 為 0 時不限制
+func keepLast[T any](items []T, limit int) []T {
+	if limit <= 0 || len(items) <= limit {
+		return items
+	}
+	return append(items[:0], items[len(items)-limit:]...)
+}
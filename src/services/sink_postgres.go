@@ -0,0 +1,460 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"solana/src/models"
+)
+
+// postgresMaxFlushFailures 連續寫入失敗達到此次數後逐條寫入，找出被拒絕的數據
+const postgresMaxFlushFailures = 3
+
+// PostgresSink 將區塊、交易、餘額變化和代幣餘額寫入規範化的 PostgreSQL 表。
+// 數據先在內存中累積，滿一批或定時用 COPY 寫入臨時表，再按 slot 和簽名 upsert，
+// 重複寫入同一區塊不會產生重複數據
+type PostgresSink struct {
+	db        *sql.DB
+	batchSize int
+	batch     *postgresBatch
+	failures  int // 連續寫入失敗的次數
+	mutex     sync.Mutex
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// postgresBatch 一批待寫入的數據
+type postgresBatch struct {
+	blocks       map[uint64]*models.BlockMessage
+	transactions map[string]*models.TransactionMessage
+	events       []*models.SlotEvent
+	deadLetters  map[uint64]*models.DeadLetter
+	orphaned     map[uint64]bool // 需要刪除數據的 slot，在寫入本批區塊之前刪除
+}
+
+func newPostgresBatch() *postgresBatch {
+	return &postgresBatch{
+		blocks:       make(map[uint64]*models.BlockMessage),
+		transactions: make(map[string]*models.TransactionMessage),
+		deadLetters:  make(map[uint64]*models.DeadLetter),
+		orphaned:     make(map[uint64]bool),
+	}
+}
+
+func (b *postgresBatch) size() int {
+	return len(b.blocks) + len(b.transactions) + len(b.events) + len(b.deadLetters) + len(b.orphaned)
+}
+
+// orphan 記錄需要刪除的 slot，並丟棄緩衝中這些 slot 的舊數據
+func (b *postgresBatch) orphan(slots []uint64) {
+	for _, slot := range slots {
+		b.orphaned[slot] = true
+		delete(b.blocks, slot)
+		for signature, transaction := range b.transactions {
+			if transaction.Slot == slot {
+				delete(b.transactions, signature)
+			}
+		}
+	}
+}
+
+func NewPostgresSink(dsn string, batchSize int, flushInterval time.Duration) (*PostgresSink, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	if err := migratePostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &PostgresSink{
+		db:        db,
+		batchSize: batchSize,
+		batch:     newPostgresBatch(),
+		stopChan:  make(chan struct{}),
+	}
+
+	if flushInterval > 0 {
+		s.wg.Add(1)
+		go s.flushLoop(flushInterval)
+	}
+	return s, nil
+}
+
+func (s *PostgresSink) PublishBlock(block *models.BlockMessage) error {
+	return s.add(func() { s.batch.blocks[block.Slot] = block })
+}
+
+func (s *PostgresSink) PublishTransaction(transaction *models.TransactionMessage) error {
+	return s.add(func() { s.batch.transactions[transaction.Signature] = transaction })
+}
+
+// PublishEvent 寫入事件，重組和回滾事件同時刪除不在鏈上的 slot 的數據
+func (s *PostgresSink) PublishEvent(event *models.SlotEvent) error {
+	return s.add(func() {
+		s.batch.events = append(s.batch.events, event)
+		s.batch.orphan(orphanedSlots(event))
+	})
+}
+
+func (s *PostgresSink) PublishDeadLetter(letter *models.DeadLetter) error {
+	return s.add(func() { s.batch.deadLetters[letter.Slot] = letter })
+}
+
+// Flush 將累積的數據寫入數據庫，失敗時保留數據等待下次寫入，
+// 連續多次失敗時逐條寫入，仍被拒絕的數據寫入死信表後丟棄
+func (s *PostgresSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.flush()
+}
+
+func (s *PostgresSink) Close() error {
+	close(s.stopChan)
+	s.wg.Wait()
+
+	err := s.Flush()
+	if closeErr := s.db.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close database: %v", closeErr)
+	}
+	return err
+}
+
+// add 加入緩衝，滿一批時立即寫入
+func (s *PostgresSink) add(buffer func()) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	buffer()
+	if s.batch.size() < s.batchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+func (s *PostgresSink) flushLoop(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				log.Printf("Error flushing PostgreSQL sink: %v", err)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// flush 在一個事務中寫入所有緩衝的數據，調用前需持有 mutex
+func (s *PostgresSink) flush() error {
+	if s.batch.size() == 0 {
+		return nil
+	}
+
+	err := s.write(s.batch)
+	if err == nil {
+		s.batch = newPostgresBatch()
+		s.failures = 0
+		return nil
+	}
+
+	// 被拒絕的數據會讓整批一直失敗，多次失敗後逐條寫入
+	if s.failures++; s.failures < postgresMaxFlushFailures {
+		return err
+	}
+	log.Printf("PostgreSQL flush failed %d times, writing items one by one: %v", s.failures, err)
+	return s.isolate()
+}
+
+// write 在一個事務中寫入一批數據
+func (s *PostgresSink) write(batch *postgresBatch) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteOrphaned(tx, batch.orphaned); err != nil {
+		return err
+	}
+	if err := writeBlocks(tx, batch); err != nil {
+		return err
+	}
+	if err := writeEvents(tx, batch.events); err != nil {
+		return err
+	}
+	if err := writeDeadLetters(tx, batch.deadLetters); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// isolate 逐條寫入緩衝的數據，單獨寫入仍失敗的寫入死信表後丟棄。
+// 死信也無法寫入時說明數據庫不可用，保留剩餘數據並返回錯誤，調用前需持有 mutex
+func (s *PostgresSink) isolate() error {
+	batch := s.batch
+	if len(batch.orphaned) > 0 {
+		single := newPostgresBatch()
+		single.orphaned = batch.orphaned
+		if err := s.write(single); err != nil {
+			return err
+		}
+		batch.orphaned = make(map[uint64]bool)
+	}
+	for slot, block := range batch.blocks {
+		single := newPostgresBatch()
+		single.blocks[slot] = block
+		if err := s.writeOrReject(slot, single); err != nil {
+			return err
+		}
+		delete(batch.blocks, slot)
+	}
+	for signature, transaction := range batch.transactions {
+		single := newPostgresBatch()
+		single.transactions[signature] = transaction
+		if err := s.writeOrReject(transaction.Slot, single); err != nil {
+			return err
+		}
+		delete(batch.transactions, signature)
+	}
+	for len(batch.events) > 0 {
+		event := batch.events[0]
+		single := newPostgresBatch()
+		single.events = append(single.events, event)
+		if err := s.writeOrReject(event.Slot, single); err != nil {
+			return err
+		}
+		batch.events = batch.events[1:]
+	}
+	for slot, letter := range batch.deadLetters {
+		single := newPostgresBatch()
+		single.deadLetters[slot] = letter
+		if err := s.writeOrReject(slot, single); err != nil {
+			return err
+		}
+		delete(batch.deadLetters, slot)
+	}
+
+	s.failures = 0
+	return nil
+}
+
+// writeOrReject 寫入單條數據，失敗時為該 slot 寫入死信
+func (s *PostgresSink) writeOrReject(slot uint64, single *postgresBatch) error {
+	err := s.write(single)
+	if err == nil {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	letter := &models.DeadLetter{
+		Slot:          slot,
+		Reason:        models.DeadLetterRejected,
+		ErrorChain:    []string{err.Error()},
+		FirstFailedAt: now,
+		FailedAt:      now,
+	}
+	rejected := newPostgresBatch()
+	rejected.deadLetters[slot] = letter
+	if letterErr := s.write(rejected); letterErr != nil {
+		return fmt.Errorf("failed to write slot %d: %v (dead letter: %v)", slot, err, letterErr)
+	}
+	log.Printf("PostgreSQL rejected data for slot %d, written to dead letters: %v", slot, err)
+	return nil
+}
+
+// deleteOrphaned 刪除已不在鏈上的 slot 的區塊和交易
+func deleteOrphaned(tx *sql.Tx, orphaned map[uint64]bool) error {
+	if len(orphaned) == 0 {
+		return nil
+	}
+
+	slots := make([]int64, 0, len(orphaned))
+	for slot := range orphaned {
+		slots = append(slots, int64(slot))
+	}
+	for _, table := range []string{"blocks", "transactions", "balance_changes", "token_balances"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE slot = ANY($1)", pq.Array(slots)); err != nil {
+			return fmt.Errorf("failed to delete orphaned slots from %s: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// writeBlocks 寫入區塊與交易。同一 slot 重新寫入時（例如重組後）先刪除該 slot 原有的交易，
+// 同一簽名的餘額數據整體替換
+func writeBlocks(tx *sql.Tx, batch *postgresBatch) error {
+	if len(batch.blocks) == 0 && len(batch.transactions) == 0 {
+		return nil
+	}
+
+	rows := newPostgresRows()
+	slots := make([]int64, 0, len(batch.blocks))
+	for _, block := range batch.blocks {
+		slots = append(slots, int64(block.Slot))
+		rows.addBlock(block)
+		for i := range block.Transactions {
+			rows.addTransaction(block.Slot, i, block.BlockTime, &block.Transactions[i])
+		}
+	}
+	for _, transaction := range batch.transactions {
+		rows.addTransaction(transaction.Slot, transaction.Index, transaction.BlockTime, &transaction.TransactionInfo)
+	}
+
+	for _, table := range []string{"blocks", "transactions", "balance_changes", "token_balances"} {
+		statement := fmt.Sprintf("CREATE TEMP TABLE staging_%s (LIKE %s) ON COMMIT DROP", table, table)
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create staging table for %s: %v", table, err)
+		}
+	}
+
+	if err := copyRows(tx, "staging_blocks", postgresBlockColumns, rows.blocks); err != nil {
+		return err
+	}
+	if err := copyRows(tx, "staging_transactions", postgresTransactionColumns, rows.transactions); err != nil {
+		return err
+	}
+	if err := copyRows(tx, "staging_balance_changes", postgresBalanceChangeColumns, rows.balanceChanges); err != nil {
+		return err
+	}
+	if err := copyRows(tx, "staging_token_balances", postgresTokenBalanceColumns, rows.tokenBalances); err != nil {
+		return err
+	}
+
+	statements := []string{
+		`DELETE FROM transactions WHERE slot = ANY($1)`,
+		`DELETE FROM balance_changes WHERE slot = ANY($1)`,
+		`DELETE FROM token_balances WHERE slot = ANY($1)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, pq.Array(slots)); err != nil {
+			return fmt.Errorf("failed to clear replaced slots: %v", err)
+		}
+	}
+
+	statements = []string{
+		`INSERT INTO blocks SELECT * FROM staging_blocks
+		ON CONFLICT (slot) DO UPDATE SET
+			block_height = EXCLUDED.block_height,
+			block_time = EXCLUDED.block_time,
+			blockhash = EXCLUDED.blockhash,
+			parent_slot = EXCLUDED.parent_slot,
+			previous_blockhash = EXCLUDED.previous_blockhash,
+			commitment = EXCLUDED.commitment,
+			transaction_count = EXCLUDED.transaction_count,
+			published_at = EXCLUDED.published_at`,
+		`INSERT INTO transactions SELECT * FROM staging_transactions
+		ON CONFLICT (signature) DO UPDATE SET
+			slot = EXCLUDED.slot,
+			tx_index = EXCLUDED.tx_index,
+			block_time = EXCLUDED.block_time,
+			status = EXCLUDED.status,
+			fee = EXCLUDED.fee,
+			compute_units = EXCLUDED.compute_units,
+			account_keys = EXCLUDED.account_keys,
+			programs = EXCLUDED.programs,
+			instructions = EXCLUDED.instructions,
+			log_messages = EXCLUDED.log_messages`,
+		`DELETE FROM balance_changes WHERE signature IN (SELECT signature FROM staging_transactions)`,
+		`DELETE FROM token_balances WHERE signature IN (SELECT signature FROM staging_transactions)`,
+		`INSERT INTO balance_changes SELECT * FROM staging_balance_changes`,
+		`INSERT INTO token_balances SELECT * FROM staging_token_balances`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to upsert blocks: %v", err)
+		}
+	}
+	return nil
+}
+
+func writeEvents(tx *sql.Tx, events []*models.SlotEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO slot_events (slot, type, blockhash, data, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (slot, type, blockhash) DO UPDATE SET data = EXCLUDED.data, created_at = EXCLUDED.created_at`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare event insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %v", err)
+		}
+		if _, err := stmt.Exec(int64(event.Slot), event.Type, pgText(event.Blockhash), pgJSON(data), time.Unix(event.Timestamp, 0)); err != nil {
+			return fmt.Errorf("failed to insert event for slot %d: %v", event.Slot, err)
+		}
+	}
+	return nil
+}
+
+func writeDeadLetters(tx *sql.Tx, letters map[uint64]*models.DeadLetter) error {
+	if len(letters) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO dead_letters (slot, reason, data, failed_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (slot) DO UPDATE SET reason = EXCLUDED.reason, data = EXCLUDED.data, failed_at = EXCLUDED.failed_at`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare dead letter insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, letter := range letters {
+		data, err := json.Marshal(letter)
+		if err != nil {
+			return fmt.Errorf("failed to marshal dead letter: %v", err)
+		}
+		if _, err := stmt.Exec(int64(letter.Slot), letter.Reason, pgJSON(data), time.Unix(letter.FailedAt, 0)); err != nil {
+			return fmt.Errorf("failed to insert dead letter for slot %d: %v", letter.Slot, err)
+		}
+	}
+	return nil
+}
+
+// copyRows 用 COPY 批量寫入
+func copyRows(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("failed to start copy into %s: %v", table, err)
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy into %s: %v", table, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to finish copy into %s: %v", table, err)
+	}
+	return stmt.Close()
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"solana/src/models"
+)
+
+// StdoutSink 以 JSON Lines 格式寫出數據，用於本地開發時查看輸出
+type StdoutSink struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+func NewStdoutSink(writer io.Writer) *StdoutSink {
+	return &StdoutSink{writer: writer}
+}
+
+func (s *StdoutSink) PublishBlock(block *models.BlockMessage) error {
+	return s.write(SinkRecord{Type: RecordBlock, Slot: block.Slot, Data: block})
+}
+
+func (s *StdoutSink) PublishTransaction(transaction *models.TransactionMessage) error {
+	return s.write(SinkRecord{Type: RecordTransaction, Slot: transaction.Slot, Data: transaction})
+}
+
+func (s *StdoutSink) PublishEvent(event *models.SlotEvent) error {
+	return s.write(SinkRecord{Type: RecordEvent, Slot: event.Slot, Data: event})
+}
+
+func (s *StdoutSink) PublishDeadLetter(letter *models.DeadLetter) error {
+	return s.write(SinkRecord{Type: RecordDeadLetter, Slot: letter.Slot, Data: letter})
+}
+
+func (s *StdoutSink) Flush() error {
+	return nil
+}
+
+func (s *StdoutSink) Close() error {
+	return nil
+}
+
+func (s *StdoutSink) write(record SinkRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s record: %v", record.Type, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s record: %v", record.Type, err)
+	}
+	return nil
+}
@@ -2,157 +2,454 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
-	"sync"
 	"time"
 
+	"solana/src/config"
 	"solana/src/models"
+	"solana/src/utils"
 
 	"github.com/valyala/fasthttp"
 )
 
 type SolanaClient struct {
-	rpcURL    string
-	cache     *sync.Map
-	rateLimit *time.Ticker
+	pool   *RPCPool
+	config *config.RPCConfig
+	cache  *utils.SlotWindow // 已確認的空槽
 }
 
-func NewSolanaClient(rpcURL string) *SolanaClient {
-	return &SolanaClient{
-		rpcURL:    rpcURL,
-		cache:     &sync.Map{},
-		rateLimit: time.NewTicker(time.Millisecond * 100),
+// getBlocks 單次請求允許的最大 slot 範圍
+const maxGetBlocksRange = 500000
+
+type rpcRequest struct {
+	Jsonrpc string        `json:"jsonrpc"`
+	Id      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+func NewSolanaClient(cfg *config.RPCConfig) *SolanaClient {
+	c := &SolanaClient{
+		pool:   NewRPCPool(cfg),
+		config: cfg,
+		cache:  utils.NewSlotWindow("skipped_cache", cfg.SkippedCacheSize),
 	}
+
+	// 以 processed 的 slot 衡量各端點的新鮮度
+	c.pool.Start(func(ep *rpcEndpoint) (uint64, error) {
+		result, err := c.send(ep, "getSlot", []interface{}{
+			map[string]interface{}{"commitment": "processed"},
+		})
+		if err != nil {
+			return 0, err
+		}
+		return parseSlot("getSlot", result)
+	})
+
+	return c
 }
 
-func (c *SolanaClient) GetLatestSlot() (uint64, error) {
-	<-c.rateLimit.C
+func (c *SolanaClient) Close() {
+	c.pool.Stop()
+}
+
+// EndpointStats 獲取 RPC 端點的健康狀態
+func (c *SolanaClient) EndpointStats() []EndpointStats {
+	return c.pool.Stats()
+}
+
+// SkippedCacheStats 獲取空槽緩存的狀態
+func (c *SolanaClient) SkippedCacheStats() utils.SlotWindowStats {
+	return c.cache.Stats()
+}
+
+// RateLimitStats 獲取各端點各方法的限流狀態
+func (c *SolanaClient) RateLimitStats() []RateLimitStats {
+	return c.pool.RateLimitStats()
+}
+
+// call 將請求路由到最健康的端點，傳輸錯誤、限流或節點落後時依序切換到下一個端點，
+// 成功時返回響應中的 result 字段
+func (c *SolanaClient) call(method string, params []interface{}) (json.RawMessage, error) {
+	var lastErr *RPCError
+	allLongTerm := true
+	for _, ep := range c.orderByAvailability(c.pool.candidates(), method) {
+		result, err := c.send(ep, method, params)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+		allLongTerm = allLongTerm && err.Kind == ErrLongTermStorage
+		if !err.Retryable() {
+			break
+		}
+	}
+	if lastErr != nil && allLongTerm {
+		lastErr.AllEndpoints = true
+	}
+
+	if lastErr == nil {
+		return nil, &RPCError{
+			Kind:    ErrTransport,
+			Method:  method,
+			Message: "no rpc endpoint configured",
+		}
+	}
+	return nil, lastErr
+}
+
+// orderByAvailability 將該方法仍處於 Retry-After 暫停期的端點移到最後
+func (c *SolanaClient) orderByAvailability(candidates []*rpcEndpoint, method string) []*rpcEndpoint {
+	available := make([]*rpcEndpoint, 0, len(candidates))
+	paused := make([]*rpcEndpoint, 0)
+	for _, ep := range candidates {
+		if ep.limiter.bucket(method).paused() {
+			paused = append(paused, ep)
+		} else {
+			available = append(available, ep)
+		}
+	}
+	return append(available, paused...)
+}
+
+// send 向指定端點發送請求並記錄其延遲與錯誤，發送前需先從該方法的令牌桶取得額度
+func (c *SolanaClient) send(ep *rpcEndpoint, method string, params []interface{}) (json.RawMessage, *RPCError) {
+	bucket := ep.limiter.bucket(method)
+	bucket.wait()
+
+	result, err := c.doSend(ep, method, params)
+	if err != nil && err.Kind == ErrRateLimited {
+		bucket.throttle(err.RetryAfter)
+	}
+	return result, err
+}
 
-	reqBody := `{
-        "jsonrpc": "2.0",
-        "id": 1,
-        "method": "getSlot",
-        "params": [{"commitment": "finalized"}]
-    }`
+func (c *SolanaClient) doSend(ep *rpcEndpoint, method string, params []interface{}) (json.RawMessage, *RPCError) {
+	reqBody, err := json.Marshal(rpcRequest{
+		Jsonrpc: "2.0",
+		Id:      1,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, &RPCError{Method: method, Err: fmt.Errorf("failed to marshal request: %v", err)}
+	}
 
 	req := fasthttp.AcquireRequest()
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseRequest(req)
 	defer fasthttp.ReleaseResponse(resp)
 
-	req.SetRequestURI(c.rpcURL)
+	req.SetRequestURI(ep.url)
 	req.Header.SetMethod("POST")
 	req.Header.SetContentType("application/json")
-	req.SetBodyString(reqBody)
+	req.SetBody(reqBody)
+
+	startTime := time.Now()
+	if err := fasthttp.DoTimeout(req, resp, c.config.RequestTimeout); err != nil {
+		c.pool.recordFailure(ep, time.Since(startTime))
+		return nil, &RPCError{Kind: ErrTransport, Method: method, Endpoint: ep.url, Err: err}
+	}
+	latency := time.Since(startTime)
+
+	// resp 會被回收，需複製內容
+	body := make([]byte, len(resp.Body()))
+	copy(body, resp.Body())
 
-	if err := fasthttp.Do(req, resp); err != nil {
-		return 0, fmt.Errorf("failed to send request: %v", err)
+	if resp.StatusCode() == fasthttp.StatusTooManyRequests {
+		c.pool.recordFailure(ep, latency)
+		return nil, &RPCError{
+			Kind:       ErrRateLimited,
+			Code:       resp.StatusCode(),
+			Method:     method,
+			Endpoint:   ep.url,
+			RetryAfter: parseRetryAfter(string(resp.Header.Peek("Retry-After"))),
+			Raw:        body,
+		}
 	}
 
-	var result struct {
-		Result uint64 `json:"result"`
+	if resp.StatusCode() != fasthttp.StatusOK {
+		c.pool.recordFailure(ep, latency)
+		return nil, &RPCError{
+			Kind:     ErrTransport,
+			Code:     resp.StatusCode(),
+			Method:   method,
+			Endpoint: ep.url,
+			Message:  fmt.Sprintf("unexpected http status %d", resp.StatusCode()),
+			Raw:      body,
+		}
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		c.pool.recordFailure(ep, latency)
+		return nil, &RPCError{
+			Kind:     ErrTransport,
+			Method:   method,
+			Endpoint: ep.url,
+			Raw:      body,
+			Err:      fmt.Errorf("failed to parse response: %v", err),
+		}
 	}
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return 0, fmt.Errorf("failed to parse response: %v", err)
+
+	if envelope.Error != nil {
+		rpcErr := &RPCError{
+			Kind:     classifyCode(envelope.Error.Code),
+			Code:     envelope.Error.Code,
+			Message:  envelope.Error.Message,
+			Method:   method,
+			Endpoint: ep.url,
+			Raw:      body,
+		}
+		// 只有端點自身的問題才計入健康度，區塊不存在等是正常的回應
+		if rpcErr.Retryable() {
+			c.pool.recordFailure(ep, latency)
+		} else {
+			c.pool.recordSuccess(ep, latency)
+		}
+		return nil, rpcErr
 	}
 
-	return result.Result, nil
+	c.pool.recordSuccess(ep, latency)
+	return envelope.Result, nil
 }
 
-func (c *SolanaClient) GetBlock(slot uint64) (*models.BlockResponse, error) {
-	<-c.rateLimit.C
+// GetLatestSlot 按配置的承諾級別獲取最新 slot
+func (c *SolanaClient) GetLatestSlot() (uint64, error) {
+	return c.GetSlotAt(c.config.Commitment)
+}
 
-	// 檢查緩存中是否為空槽
-	if _, ok := c.cache.Load(slot); ok {
-		return nil, fmt.Errorf("empty slot")
-	}
-
-	reqBody := fmt.Sprintf(`{
-        "jsonrpc": "2.0",
-        "id": 1,
-        "method": "getBlock",
-        "params": [
-            %d,
-            {
-                "encoding": "json",
-                "transactionDetails": "full",
-                "rewards": false,
-                "maxSupportedTransactionVersion": 0
-            }
-        ]
-    }`, slot)
+func (c *SolanaClient) GetSlotAt(commitment string) (uint64, error) {
+	result, err := c.call("getSlot", []interface{}{
+		map[string]interface{}{"commitment": commitment},
+	})
+	if err != nil {
+		return 0, err
+	}
 
-	req := fasthttp.AcquireRequest()
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseRequest(req)
-	defer fasthttp.ReleaseResponse(resp)
+	return parseSlot("getSlot", result)
+}
 
-	req.SetRequestURI(c.rpcURL)
-	req.Header.SetMethod("POST")
-	req.Header.SetContentType("application/json")
-	req.SetBodyString(reqBody)
+func (c *SolanaClient) GetBlock(slot uint64) (*models.BlockResponse, error) {
+	// 檢查緩存中是否為空槽
+	if c.cache.Has(slot) {
+		return nil, &RPCError{
+			Kind:    ErrSlotSkipped,
+			Method:  "getBlock",
+			Message: fmt.Sprintf("slot %d cached as skipped", slot),
+		}
+	}
+
+	result, err := c.call("getBlock", []interface{}{
+		slot,
+		map[string]interface{}{
+			"encoding":                       "json",
+			"transactionDetails":             "full",
+			"rewards":                        false,
+			"maxSupportedTransactionVersion": 0,
+			"commitment":                     c.config.BlockCommitment(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	if err := fasthttp.Do(req, resp); err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+	if isNull(result) {
+		return nil, &RPCError{
+			Kind:    ErrBlockNotAvailable,
+			Method:  "getBlock",
+			Message: fmt.Sprintf("empty result for slot %d", slot),
+		}
 	}
 
-	var blockResponse models.BlockResponse
-	if err := json.Unmarshal(resp.Body(), &blockResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+	blockResponse := models.BlockResponse{
+		Jsonrpc:    "2.0",
+		Id:         1,
+		Slot:       slot,
+		Commitment: c.config.BlockCommitment(),
+	}
+	if err := json.Unmarshal(result, &blockResponse.Result); err != nil {
+		return nil, &RPCError{
+			Kind:   ErrTransport,
+			Method: "getBlock",
+			Raw:    result,
+			Err:    fmt.Errorf("failed to parse block: %v", err),
+		}
 	}
 
 	return &blockResponse, nil
 }
 
-func (c *SolanaClient) CheckSlotStatus(slot uint64) (string, error) {
-	<-c.rateLimit.C
-
-	reqBody := fmt.Sprintf(`{
-        "jsonrpc": "2.0",
-        "id": 1,
-        "method": "getBlock",
-        "params": [
-            %d,
-            {
-                "encoding": "json",
-                "transactionDetails": "none",
-                "rewards": false
-            }
-        ]
-    }`, slot)
+// GetBlocks 返回 [startSlot, endSlot] 範圍內已產出區塊的 slot，
+// 超過單次請求上限的範圍會自動拆分
+func (c *SolanaClient) GetBlocks(startSlot, endSlot uint64) ([]uint64, error) {
+	return c.GetBlocksAt(startSlot, endSlot, c.config.BlockCommitment())
+}
 
-	req := fasthttp.AcquireRequest()
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseRequest(req)
-	defer fasthttp.ReleaseResponse(resp)
+func (c *SolanaClient) GetBlocksAt(startSlot, endSlot uint64, commitment string) ([]uint64, error) {
+	slots := make([]uint64, 0)
+	for start := startSlot; start <= endSlot; start += maxGetBlocksRange {
+		end := start + maxGetBlocksRange - 1
+		if end > endSlot {
+			end = endSlot
+		}
 
-	req.SetRequestURI(c.rpcURL)
-	req.Header.SetMethod("POST")
-	req.Header.SetContentType("application/json")
-	req.SetBodyString(reqBody)
+		result, err := c.call("getBlocks", []interface{}{
+			start,
+			end,
+			map[string]interface{}{"commitment": commitment},
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	if err := fasthttp.Do(req, resp); err != nil {
-		return "NOT_AVAILABLE", err
+		produced, err := parseSlots("getBlocks", result)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, produced...)
 	}
 
-	var result struct {
-		Error struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
+	return slots, nil
+}
+
+// GetBlocksWithLimit 返回從 startSlot 開始最多 limit 個已產出區塊的 slot
+func (c *SolanaClient) GetBlocksWithLimit(startSlot, limit uint64) ([]uint64, error) {
+	result, err := c.call("getBlocksWithLimit", []interface{}{
+		startSlot,
+		limit,
+		map[string]interface{}{"commitment": c.config.BlockCommitment()},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return "NOT_AVAILABLE", err
+	return parseSlots("getBlocksWithLimit", result)
+}
+
+// GetSlotLeaders 返回從 startSlot 開始 limit 個 slot 的出塊節點，
+// 只能查詢節點已知領導者排程的 epoch
+func (c *SolanaClient) GetSlotLeaders(startSlot, limit uint64) ([]string, error) {
+	result, err := c.call("getSlotLeaders", []interface{}{startSlot, limit})
+	if err != nil {
+		return nil, err
 	}
 
-	if result.Error.Code == -32004 ||
-		strings.Contains(result.Error.Message, "Block not available") {
+	var leaders []string
+	if err := json.Unmarshal(result, &leaders); err != nil {
+		return nil, &RPCError{
+			Kind:   ErrTransport,
+			Method: "getSlotLeaders",
+			Raw:    result,
+			Err:    fmt.Errorf("failed to parse leaders: %v", err),
+		}
+	}
+	return leaders, nil
+}
+
+// GetBlockSummary 獲取不含交易的區塊頭信息，用於校驗區塊是否被確認
+func (c *SolanaClient) GetBlockSummary(slot uint64, commitment string) (*models.BlockSummary, error) {
+	result, err := c.call("getBlock", []interface{}{
+		slot,
+		map[string]interface{}{
+			"encoding":                       "json",
+			"transactionDetails":             "none",
+			"rewards":                        false,
+			"maxSupportedTransactionVersion": 0,
+			"commitment":                     commitment,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if isNull(result) {
+		return nil, &RPCError{
+			Kind:    ErrBlockNotAvailable,
+			Method:  "getBlock",
+			Message: fmt.Sprintf("empty result for slot %d", slot),
+		}
+	}
+
+	summary := &models.BlockSummary{Slot: slot}
+	if err := json.Unmarshal(result, summary); err != nil {
+		return nil, &RPCError{
+			Kind:   ErrTransport,
+			Method: "getBlock",
+			Raw:    result,
+			Err:    fmt.Errorf("failed to parse block summary: %v", err),
+		}
+	}
+	summary.Slot = slot
+
+	return summary, nil
+}
+
+// CheckSlotStatus 返回 "EMPTY"、"NOT_AVAILABLE" 或 "CONFIRMED"
+func (c *SolanaClient) CheckSlotStatus(slot uint64) (string, error) {
+	_, err := c.call("getBlock", []interface{}{
+		slot,
+		map[string]interface{}{
+			"encoding":           "json",
+			"transactionDetails": "none",
+			"rewards":            false,
+			"commitment":         c.config.BlockCommitment(),
+		},
+	})
+
+	var rpcErr *RPCError
+	switch {
+	case err == nil:
+		return "CONFIRMED", nil
+	case errors.Is(err, ErrSlotSkipped):
 		// 將空槽加入緩存
-		c.cache.Store(slot, true)
+		c.cache.Add(slot)
+		return "EMPTY", nil
+	case errors.As(err, &rpcErr) && rpcErr.Kind == ErrLongTermStorage && rpcErr.AllEndpoints:
+		// 所有端點都沒有該區塊時才視為空槽，不加入緩存，之後有端點補齊歷史時仍可獲取
 		return "EMPTY", nil
+	case errors.Is(err, ErrBlockNotAvailable), errors.Is(err, ErrLongTermStorage):
+		return "NOT_AVAILABLE", nil
+	default:
+		return "NOT_AVAILABLE", err
+	}
+}
+
+func parseSlot(method string, result json.RawMessage) (uint64, error) {
+	var slot uint64
+	if err := json.Unmarshal(result, &slot); err != nil {
+		return 0, &RPCError{
+			Kind:   ErrTransport,
+			Method: method,
+			Raw:    result,
+			Err:    fmt.Errorf("failed to parse slot: %v", err),
+		}
 	}
 
-	return "CONFIRMED", nil
+	return slot, nil
+}
+
+func parseSlots(method string, result json.RawMessage) ([]uint64, error) {
+	var slots []uint64
+	if err := json.Unmarshal(result, &slots); err != nil {
+		return nil, &RPCError{
+			Kind:   ErrTransport,
+			Method: method,
+			Raw:    result,
+			Err:    fmt.Errorf("failed to parse slots: %v", err),
+		}
+	}
+
+	return slots, nil
+}
+
+func isNull(result json.RawMessage) bool {
+	return len(result) == 0 || string(result) == "null"
 }
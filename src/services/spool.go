@@ -0,0 +1,703 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"solana/src/models"
+)
+
+// ErrSpoolFull 本地緩存已達到磁盤配額，無法再接收數據
+var ErrSpoolFull = errors.New("spool is full")
+
+const (
+	spoolSegmentBytes   = 64 * 1024 * 1024 // 單個緩存文件的大小，超過後寫入新文件
+	spoolPositionFile   = "position.json"
+	spoolReplayInterval = time.Second
+	spoolReplayBatch    = 100 // 每重放多少條保存一次位置
+)
+
+// spoolRecord 緩存文件中的一行
+type spoolRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// spoolPosition 下一條待重放數據的位置
+type spoolPosition struct {
+	Segment int   `json:"segment"`
+	Offset  int64 `json:"offset"`
+}
+
+// SpoolStats 本地緩存狀態
+type SpoolStats struct {
+	Connected  bool   `json:"connected"` // 目標 Sink 是否可用
+	Records    int    `json:"records"`   // 等待重放的數據條數
+	Bytes      int64  `json:"bytes"`
+	QuotaBytes int64  `json:"quota_bytes"`
+	Segments   int    `json:"segments"`
+	Spooled    uint64 `json:"spooled"`
+	Replayed   uint64 `json:"replayed"`
+}
+
+// SpoolSink 在目標 Sink 不可用時把數據寫入本地緩存文件（預寫日誌），恢復後按寫入順序重放。
+// 緩存中還有數據時新數據也寫入緩存，保證順序；緩存超過配額時返回 ErrSpoolFull。
+// 異步目標最終發送失敗的數據也寫入緩存，寫入後才報告為已確認。
+// 重放是至少一次的，進程在保存位置前退出時部分數據會再發送一次
+type SpoolSink struct {
+	dir       string
+	quota     int64
+	async     bool
+	connect   func() (Sink, error)
+	target    Sink          // 連接成功前為 nil
+	connected atomic.Bool   // 發送失敗後為 false，重放成功後恢復
+	acks      *spoolTracker // 異步目標的確認，同步目標時為 nil
+	wake      chan struct{}
+	segments  []int // 現有的緩存文件編號，從舊到新
+	writer    *os.File
+	written   int64 // 最新文件的大小
+	reader    *os.File
+	buffered  *bufio.Reader
+	position  spoolPosition
+	records   int
+	bytes     int64
+	spooled   uint64
+	replayed  uint64
+	mutex     sync.Mutex
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewSpoolSink 創建緩存並嘗試連接目標，連接失敗時先寫入緩存，之後在後台重試。
+// async 表示目標是否異步確認消息
+func NewSpoolSink(dir string, quota int64, async bool, connect func() (Sink, error)) (*SpoolSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %v", err)
+	}
+
+	s := &SpoolSink{dir: dir, quota: quota, async: async, connect: connect, wake: make(chan struct{}, 1), stopChan: make(chan struct{})}
+	if async {
+		s.acks = newSpoolTracker(s.deliveryFailed)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	if s.records > 0 {
+		log.Printf("Spool has %d records (%d bytes) to replay", s.records, s.bytes)
+	}
+
+	s.reconnect()
+	s.wg.Add(1)
+	go s.replayLoop()
+	return s, nil
+}
+
+// open 讀取已有的緩存文件和重放位置，統計待重放的數據
+func (s *SpoolSink) open() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.spool"))
+	if err != nil {
+		return fmt.Errorf("failed to list spool segments: %v", err)
+	}
+	for _, path := range paths {
+		var segment int
+		if _, err := fmt.Sscanf(filepath.Base(path), "%d.spool", &segment); err == nil {
+			s.segments = append(s.segments, segment)
+		}
+	}
+	sort.Ints(s.segments)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolPositionFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read spool position: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.position); err != nil {
+			return fmt.Errorf("failed to parse spool position: %v", err)
+		}
+	}
+
+	// 刪除已重放完的文件
+	for len(s.segments) > 0 && s.segments[0] < s.position.Segment {
+		os.Remove(s.segmentPath(s.segments[0]))
+		s.segments = s.segments[1:]
+	}
+	if len(s.segments) == 0 {
+		s.position = spoolPosition{Segment: s.nextSegment()}
+		s.segments = []int{s.position.Segment}
+	} else if s.segments[0] != s.position.Segment {
+		// 沒有位置文件時從最舊的文件開始
+		s.position = spoolPosition{Segment: s.segments[0]}
+	}
+
+	for i, segment := range s.segments {
+		offset := int64(0)
+		if i == 0 {
+			offset = s.position.Offset
+		}
+		records, size, err := countRecords(s.segmentPath(segment), offset)
+		if err != nil {
+			return err
+		}
+		s.records += records
+		s.bytes += size
+	}
+
+	last := s.segments[len(s.segments)-1]
+	writer, err := os.OpenFile(s.segmentPath(last), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %v", err)
+	}
+	info, err := writer.Stat()
+	if err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to stat spool segment: %v", err)
+	}
+	s.writer = writer
+	s.written = info.Size()
+	return nil
+}
+
+// countRecords 統計文件中 offset 之後的完整行，不完整的末行（寫入時中斷）被截掉
+func countRecords(path string, offset int64) (int, int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open spool segment: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to seek spool segment: %v", err)
+	}
+
+	reader := bufio.NewReader(file)
+	records := 0
+	size := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				if err := file.Truncate(offset + size); err != nil {
+					return 0, 0, fmt.Errorf("failed to truncate spool segment: %v", err)
+				}
+			}
+			return records, size, nil
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read spool segment: %v", err)
+		}
+		records++
+		size += int64(len(line))
+	}
+}
+
+func (s *SpoolSink) segmentPath(segment int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%010d.spool", segment))
+}
+
+func (s *SpoolSink) nextSegment() int {
+	if len(s.segments) == 0 {
+		return s.position.Segment + 1
+	}
+	return s.segments[len(s.segments)-1] + 1
+}
+
+func (s *SpoolSink) PublishBlock(block *models.BlockMessage) error {
+	return s.publish(RecordBlock, block.Slot, block, func(target Sink) error { return target.PublishBlock(block) })
+}
+
+func (s *SpoolSink) PublishTransaction(transaction *models.TransactionMessage) error {
+	return s.publish(RecordTransaction, transaction.Slot, transaction, func(target Sink) error { return target.PublishTransaction(transaction) })
+}
+
+func (s *SpoolSink) PublishEvent(event *models.SlotEvent) error {
+	return s.publish(RecordEvent, event.Slot, event, func(target Sink) error { return target.PublishEvent(event) })
+}
+
+func (s *SpoolSink) PublishDeadLetter(letter *models.DeadLetter) error {
+	return s.publish(RecordDeadLetter, letter.Slot, letter, func(target Sink) error { return target.PublishDeadLetter(letter) })
+}
+
+// publish 緩存為空且目標可用時直接發送，發送失敗或緩存中還有數據時寫入緩存
+func (s *SpoolSink) publish(recordType string, slot uint64, value interface{}, send func(target Sink) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var line []byte
+	if s.target != nil && s.connected.Load() && s.records == 0 {
+		// 異步目標需要保留數據，確認失敗時寫入緩存
+		if s.acks != nil {
+			var err error
+			if line, err = spoolLine(recordType, value); err != nil {
+				return err
+			}
+		}
+		err := s.sendTo(s.target, slot, line, send)
+		if err == nil || !spoolable(err) {
+			return err
+		}
+		s.disconnect(err)
+	}
+
+	if line == nil {
+		var err error
+		if line, err = spoolLine(recordType, value); err != nil {
+			return err
+		}
+	}
+	return s.append(line)
+}
+
+func spoolLine(recordType string, value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s record: %v", recordType, err)
+	}
+	line, err := json.Marshal(spoolRecord{Type: recordType, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spool record: %v", err)
+	}
+	return append(line, '\n'), nil
+}
+
+// sendTo 把數據交給目標，異步目標同時跟蹤確認
+func (s *SpoolSink) sendTo(target Sink, slot uint64, line []byte, send func(target Sink) error) error {
+	if s.acks == nil {
+		return send(target)
+	}
+	return s.acks.send(slot, line, func() error { return send(target) })
+}
+
+// disconnect 發送失敗後新數據改為寫入緩存，直到重放成功
+func (s *SpoolSink) disconnect(err error) {
+	if s.connected.Swap(false) {
+		log.Printf("Sink unavailable, spooling to disk: %v", err)
+	}
+}
+
+// deliveryFailed 異步目標最終發送失敗，在發送協程中調用，只標記狀態並喚醒重放協程
+func (s *SpoolSink) deliveryFailed(err error) {
+	s.disconnect(err)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// spoolFailed 把異步目標發送失敗的數據寫入緩存，然後報告確認結果
+func (s *SpoolSink) spoolFailed() error {
+	if s.acks == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	failed := s.acks.takeFailed()
+	results := make([]error, len(failed))
+	var firstErr error
+	for i, p := range failed {
+		for _, line := range p.lines {
+			if results[i] = s.append(line); results[i] != nil {
+				break
+			}
+		}
+		if results[i] != nil && firstErr == nil {
+			firstErr = results[i]
+		}
+	}
+	s.mutex.Unlock()
+
+	for i, p := range failed {
+		if results[i] != nil {
+			log.Printf("Error spooling slot %d after failed delivery: %v", p.slot, results[i])
+		}
+		s.acks.resolve(p, results[i])
+	}
+	return firstErr
+}
+
+// spoolable 消息本身有問題時寫入緩存也無法發送
+func spoolable(err error) bool {
+	return !errors.Is(err, ErrMessageTooLarge)
+}
+
+// append 寫入並同步到磁盤，調用前需持有 mutex
+func (s *SpoolSink) append(line []byte) error {
+	if s.quota > 0 && s.bytes+int64(len(line)) > s.quota {
+		return fmt.Errorf("%w (%d of %d bytes used)", ErrSpoolFull, s.bytes, s.quota)
+	}
+
+	if s.written > 0 && s.written+int64(len(line)) > spoolSegmentBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.writer.Write(line); err != nil {
+		return fmt.Errorf("failed to write spool: %v", err)
+	}
+	if err := s.writer.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %v", err)
+	}
+	s.written += int64(len(line))
+	s.bytes += int64(len(line))
+	s.records++
+	s.spooled++
+	return nil
+}
+
+// rotate 調用前需持有 mutex
+func (s *SpoolSink) rotate() error {
+	segment := s.nextSegment()
+	writer, err := os.OpenFile(s.segmentPath(segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %v", err)
+	}
+	s.writer.Close()
+	s.writer = writer
+	s.written = 0
+	s.segments = append(s.segments, segment)
+	return nil
+}
+
+// reconnect 嘗試連接目標 Sink
+func (s *SpoolSink) reconnect() {
+	target, err := s.connect()
+	if err != nil {
+		log.Printf("Sink unavailable, spooling to disk: %v", err)
+		return
+	}
+	s.mutex.Lock()
+	if async, ok := target.(AsyncSink); ok && s.acks != nil {
+		async.SetTracker(s.acks)
+	}
+	s.target = target
+	s.connected.Store(true)
+	s.mutex.Unlock()
+	log.Printf("Sink connected")
+}
+
+func (s *SpoolSink) replayLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(spoolReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-s.stopChan:
+			return
+		}
+
+		if err := s.spoolFailed(); err != nil {
+			log.Printf("Error spooling failed deliveries: %v", err)
+		}
+
+		s.mutex.Lock()
+		hasTarget := s.target != nil
+		s.mutex.Unlock()
+
+		if !hasTarget {
+			s.reconnect()
+			continue
+		}
+		s.replay()
+	}
+}
+
+// replay 按順序重放緩存中的數據，直到緩存清空、發送失敗或停止。
+// 讀取和發送不持有 mutex，重放期間新數據照常寫入緩存
+func (s *SpoolSink) replay() {
+	unsaved := 0
+	defer func() {
+		if unsaved == 0 {
+			return
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if err := s.savePosition(); err != nil {
+			log.Printf("Error saving spool position: %v", err)
+		}
+		if s.records == 0 {
+			log.Printf("Spool drained")
+		}
+	}()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		s.mutex.Lock()
+		pending := s.records
+		target := s.target
+		s.mutex.Unlock()
+		if pending == 0 {
+			// 緩存清空且重放的數據都已確認後恢復直接發送
+			if s.acks != nil && !s.acks.settled() {
+				return
+			}
+			if !s.connected.Swap(true) {
+				log.Printf("Sink reconnected")
+			}
+			return
+		}
+
+		line, exhausted, err := s.readRecord()
+		if err != nil {
+			log.Printf("Spool replay paused: %v", err)
+			return
+		}
+		if exhausted {
+			s.mutex.Lock()
+			moved, err := s.nextReadSegment()
+			s.mutex.Unlock()
+			if err != nil {
+				log.Printf("Spool replay paused: %v", err)
+				return
+			}
+			if !moved {
+				return
+			}
+			unsaved = 0
+			continue
+		}
+		if line == nil {
+			return
+		}
+
+		if err := s.send(target, line); err != nil {
+			// 未發送的行下次從同一位置重新讀取
+			s.closeReader()
+			s.disconnect(err)
+			log.Printf("Spool replay paused: %v", err)
+			return
+		}
+
+		s.mutex.Lock()
+		s.position.Offset += int64(len(line))
+		s.bytes -= int64(len(line))
+		s.records--
+		s.replayed++
+		unsaved++
+		if unsaved >= spoolReplayBatch {
+			if err := s.savePosition(); err != nil {
+				log.Printf("Error saving spool position: %v", err)
+			}
+			unsaved = 0
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// readRecord 讀取當前位置的一行。當前文件讀完時 exhausted 為 true；
+// 遇到尚未寫完的行時返回 nil，下次從同一位置重新讀取。只由重放協程調用
+func (s *SpoolSink) readRecord() (line []byte, exhausted bool, err error) {
+	if s.reader == nil {
+		s.mutex.Lock()
+		position := s.position
+		s.mutex.Unlock()
+
+		reader, err := os.Open(s.segmentPath(position.Segment))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to open spool segment: %v", err)
+		}
+		if _, err := reader.Seek(position.Offset, io.SeekStart); err != nil {
+			reader.Close()
+			return nil, false, fmt.Errorf("failed to seek spool segment: %v", err)
+		}
+		s.reader = reader
+		s.buffered = bufio.NewReaderSize(reader, 1<<20)
+	}
+
+	line, err = s.buffered.ReadBytes('\n')
+	if err == io.EOF {
+		s.closeReader()
+		return nil, len(line) == 0, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read spool segment: %v", err)
+	}
+	return line, false, nil
+}
+
+// nextReadSegment 刪除已讀完的文件並移到下一個，當前文件仍在寫入時返回 false，調用前需持有 mutex
+func (s *SpoolSink) nextReadSegment() (bool, error) {
+	if len(s.segments) < 2 {
+		return false, nil
+	}
+
+	old := s.segments[0]
+	s.segments = s.segments[1:]
+	s.position = spoolPosition{Segment: s.segments[0]}
+	if err := s.savePosition(); err != nil {
+		return false, err
+	}
+	if err := os.Remove(s.segmentPath(old)); err != nil {
+		log.Printf("Error removing spool segment %d: %v", old, err)
+	}
+	return true, nil
+}
+
+func (s *SpoolSink) closeReader() {
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+		s.buffered = nil
+	}
+}
+
+// send 解碼並發送到目標
+func (s *SpoolSink) send(target Sink, line []byte) error {
+	var record spoolRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		// 損壞的行無法恢復，跳過
+		log.Printf("Skipping corrupt spool record: %v", err)
+		return nil
+	}
+
+	var err error
+	var slot uint64
+	var publish func(target Sink) error
+	switch record.Type {
+	case RecordBlock:
+		var block models.BlockMessage
+		err = json.Unmarshal(record.Data, &block)
+		slot, publish = block.Slot, func(target Sink) error { return target.PublishBlock(&block) }
+	case RecordTransaction:
+		var transaction models.TransactionMessage
+		err = json.Unmarshal(record.Data, &transaction)
+		slot, publish = transaction.Slot, func(target Sink) error { return target.PublishTransaction(&transaction) }
+	case RecordEvent:
+		var event models.SlotEvent
+		err = json.Unmarshal(record.Data, &event)
+		slot, publish = event.Slot, func(target Sink) error { return target.PublishEvent(&event) }
+	case RecordDeadLetter:
+		var letter models.DeadLetter
+		err = json.Unmarshal(record.Data, &letter)
+		slot, publish = letter.Slot, func(target Sink) error { return target.PublishDeadLetter(&letter) }
+	default:
+		log.Printf("Skipping spool record of unknown type %q", record.Type)
+		return nil
+	}
+	if err == nil {
+		err = s.sendTo(target, slot, line, publish)
+	}
+
+	if err != nil && !spoolable(err) {
+		log.Printf("Dropping spooled %s that cannot be sent: %v", record.Type, err)
+		return nil
+	}
+	return err
+}
+
+// savePosition 寫入臨時文件後重命名，保證位置文件完整
+func (s *SpoolSink) savePosition() error {
+	data, err := json.Marshal(s.position)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool position: %v", err)
+	}
+
+	path := filepath.Join(s.dir, spoolPositionFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write spool position: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save spool position: %v", err)
+	}
+	return nil
+}
+
+// Flush 數據寫入緩存時已同步到磁盤，只需要刷新目標。
+// 異步目標發送失敗的數據寫入緩存後不再作為錯誤返回
+func (s *SpoolSink) Flush() error {
+	s.mutex.Lock()
+	target := s.target
+	s.mutex.Unlock()
+
+	if target == nil {
+		return nil
+	}
+	err := target.Flush()
+	if s.acks == nil {
+		return err
+	}
+	if spoolErr := s.spoolFailed(); spoolErr != nil {
+		return spoolErr
+	}
+	if err != nil && spoolable(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *SpoolSink) Close() error {
+	close(s.stopChan)
+	s.wg.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closeReader()
+	err := s.writer.Close()
+	if s.target != nil {
+		if closeErr := s.target.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Async 實現 AsyncSink
+func (s *SpoolSink) Async() bool {
+	return s.async
+}
+
+// SetTracker 實現 AsyncSink，目標的確認先經過緩存再轉交給 tracker
+func (s *SpoolSink) SetTracker(tracker DeliveryTracker) {
+	if s.acks != nil {
+		s.acks.setTracker(tracker)
+	}
+}
+
+func (s *SpoolSink) Stats() SpoolStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return SpoolStats{
+		Connected:  s.target != nil && s.connected.Load(),
+		Records:    s.records,
+		Bytes:      s.bytes,
+		QuotaBytes: s.quota,
+		Segments:   len(s.segments),
+		Spooled:    s.spooled,
+		Replayed:   s.replayed,
+	}
+}
+
+// FindSpool 返回 sink 中的 SpoolSink，沒有時返回 nil
+func FindSpool(sink Sink) *SpoolSink {
+	switch s := sink.(type) {
+	case *SpoolSink:
+		return s
+	case *MultiSink:
+		for _, child := range s.sinks {
+			if spool := FindSpool(child); spool != nil {
+				return spool
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"solana/src/models"
+)
+
+// flakySink 可切換成功或失敗的目標，async 時失敗通過 tracker 報告
+type flakySink struct {
+	*MemorySink
+	async   bool
+	fail    bool
+	tracker DeliveryTracker
+	mutex   sync.Mutex
+}
+
+var errBrokerDown = errors.New("broker down")
+
+func (s *flakySink) PublishBlock(block *models.BlockMessage) error {
+	s.mutex.Lock()
+	fail, tracker := s.fail, s.tracker
+	s.mutex.Unlock()
+
+	if !s.async {
+		if fail {
+			return errBrokerDown
+		}
+		return s.MemorySink.PublishBlock(block)
+	}
+
+	tracker.Sent(block.Slot)
+	if fail {
+		tracker.Delivered(block.Slot, errBrokerDown)
+		return nil
+	}
+	s.MemorySink.PublishBlock(block)
+	tracker.Delivered(block.Slot, nil)
+	return nil
+}
+
+func (s *flakySink) setFail(fail bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fail = fail
+}
+
+func (s *flakySink) Async() bool { return s.async }
+
+func (s *flakySink) SetTracker(tracker DeliveryTracker) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tracker = tracker
+}
+
+// recordingTracker 記錄 Spool 轉交的確認
+type recordingTracker struct {
+	sent      map[uint64]int
+	delivered map[uint64][]error
+	mutex     sync.Mutex
+}
+
+func (t *recordingTracker) Sent(slot uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.sent[slot]++
+}
+
+func (t *recordingTracker) Delivered(slot uint64, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.delivered[slot] = append(t.delivered[slot], err)
+}
+
+func TestSpoolSinkSpoolsFailedSends(t *testing.T) {
+	tests := []struct {
+		name  string
+		async bool
+	}{
+		{name: "sync send error", async: false},
+		{name: "async delivery error", async: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &flakySink{MemorySink: NewMemorySink(0), async: tt.async}
+			spool, err := NewSpoolSink(t.TempDir(), 0, tt.async, func() (Sink, error) { return target, nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer spool.Close()
+			tracker := &recordingTracker{sent: make(map[uint64]int), delivered: make(map[uint64][]error)}
+			spool.SetTracker(tracker)
+
+			if err := spool.PublishBlock(&models.BlockMessage{Slot: 1}); err != nil {
+				t.Fatal(err)
+			}
+			target.setFail(true)
+			if err := spool.PublishBlock(&models.BlockMessage{Slot: 2}); err != nil {
+				t.Fatal(err)
+			}
+			if err := spool.Flush(); err != nil {
+				t.Fatalf("Flush() = %v, want nil once the failed block is spooled", err)
+			}
+
+			if spool.Stats().Connected {
+				t.Fatal("spool still connected after a failed send")
+			}
+
+			// 斷開後新數據寫入緩存，目標恢復前只收到第一個區塊
+			if err := spool.PublishBlock(&models.BlockMessage{Slot: 3}); err != nil {
+				t.Fatal(err)
+			}
+			if blocks := target.Blocks(); len(blocks) != 1 {
+				t.Fatalf("target received %d blocks before recovery, want 1", len(blocks))
+			}
+
+			if tt.async {
+				tracker.mutex.Lock()
+				got := tracker.delivered[2]
+				tracker.mutex.Unlock()
+				if len(got) == 0 {
+					t.Error("slot 2 not reported as delivered after spooling")
+				}
+				for _, err := range got {
+					if err != nil {
+						t.Errorf("slot 2 delivered with %v, want nil after spooling", err)
+					}
+				}
+			}
+
+			// 目標恢復後重放並恢復直接發送
+			target.setFail(false)
+			deadline := time.Now().Add(5 * time.Second)
+			for stats := spool.Stats(); !stats.Connected || stats.Records != 0; stats = spool.Stats() {
+				if time.Now().After(deadline) {
+					t.Fatalf("stats = %+v, want connected and drained", stats)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			// 異步發送失敗的數據重新寫入緩存末尾，順序可能改變
+			var slots []uint64
+			for _, block := range target.Blocks() {
+				slots = append(slots, block.Slot)
+			}
+			sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+			if !reflect.DeepEqual(slots, []uint64{1, 2, 3}) {
+				t.Errorf("target received %v, want [1 2 3]", slots)
+			}
+		})
+	}
+}
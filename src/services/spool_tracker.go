@@ -0,0 +1,143 @@
+package services
+
+import "sync"
+
+// spoolPending 異步目標中一個 slot 尚未確認的數據
+type spoolPending struct {
+	slot        uint64
+	lines       [][]byte // 已交給目標的緩存行，發送失敗時整個 slot 寫入緩存
+	sending     int      // 正在交給目標的調用數
+	outstanding int      // 已進入目標隊列、尚未確認的消息數
+	deferred    int      // 發送失敗、寫入緩存後才報告的確認數
+	err         error    // 第一個發送失敗
+}
+
+// spoolTracker 包裝異步目標的 DeliveryTracker。成功的確認直接轉交；
+// 最終發送失敗的 slot 等緩存寫入磁盤後再報告為已確認，寫入失敗時報告原來的錯誤
+type spoolTracker struct {
+	tracker   DeliveryTracker
+	pending   map[uint64]*spoolPending
+	failed    []*spoolPending
+	onFailure func(err error) // 在發送協程中調用，不能阻塞
+	mutex     sync.Mutex
+}
+
+func newSpoolTracker(onFailure func(err error)) *spoolTracker {
+	return &spoolTracker{pending: make(map[uint64]*spoolPending), onFailure: onFailure}
+}
+
+func (t *spoolTracker) setTracker(tracker DeliveryTracker) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.tracker = tracker
+}
+
+// send 調用 fn 把數據交給目標，成功時記下 line，直到該 slot 的消息全部確認
+func (t *spoolTracker) send(slot uint64, line []byte, fn func() error) error {
+	t.mutex.Lock()
+	t.entry(slot).sending++
+	t.mutex.Unlock()
+
+	err := fn()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	p := t.entry(slot)
+	if err == nil {
+		p.lines = append(p.lines, line)
+	}
+	p.sending--
+	t.settle(p)
+	return err
+}
+
+// Sent 實現 DeliveryTracker
+func (t *spoolTracker) Sent(slot uint64) {
+	t.mutex.Lock()
+	t.entry(slot).outstanding++
+	tracker := t.tracker
+	t.mutex.Unlock()
+
+	if tracker != nil {
+		tracker.Sent(slot)
+	}
+}
+
+// Delivered 實現 DeliveryTracker
+func (t *spoolTracker) Delivered(slot uint64, err error) {
+	t.mutex.Lock()
+	p := t.entry(slot)
+	p.outstanding--
+	deferred := err != nil && spoolable(err)
+	if deferred {
+		p.deferred++
+		if p.err == nil {
+			p.err = err
+		}
+	}
+	t.settle(p)
+	tracker := t.tracker
+	t.mutex.Unlock()
+
+	if deferred {
+		t.onFailure(err)
+		return
+	}
+	if tracker != nil {
+		tracker.Delivered(slot, err)
+	}
+}
+
+// entry 調用前需持有 mutex
+func (t *spoolTracker) entry(slot uint64) *spoolPending {
+	p, ok := t.pending[slot]
+	if !ok {
+		p = &spoolPending{slot: slot}
+		t.pending[slot] = p
+	}
+	return p
+}
+
+// settle slot 的消息全部確認後釋放數據，有發送失敗時移入待緩存列表，調用前需持有 mutex
+func (t *spoolTracker) settle(p *spoolPending) {
+	if p.sending > 0 || p.outstanding > 0 {
+		return
+	}
+	delete(t.pending, p.slot)
+	if p.deferred > 0 {
+		t.failed = append(t.failed, p)
+	}
+}
+
+// settled 沒有等待確認或等待寫入緩存的數據
+func (t *spoolTracker) settled() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.pending) == 0 && len(t.failed) == 0
+}
+
+// takeFailed 取出待寫入緩存的 slot
+func (t *spoolTracker) takeFailed() []*spoolPending {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	failed := t.failed
+	t.failed = nil
+	return failed
+}
+
+// resolve 報告寫入緩存的結果，err 為 nil 表示數據已在磁盤上
+func (t *spoolTracker) resolve(p *spoolPending, err error) {
+	t.mutex.Lock()
+	tracker := t.tracker
+	t.mutex.Unlock()
+
+	if tracker == nil {
+		return
+	}
+	if err != nil {
+		err = p.err
+	}
+	for i := 0; i < p.deferred; i++ {
+		tracker.Delivered(p.slot, err)
+	}
+}
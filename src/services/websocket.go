@@ -0,0 +1,343 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"solana/src/config"
+	"solana/src/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// SlotUpdate slotSubscribe 推送的 slot 信息
+type SlotUpdate struct {
+	Slot   uint64 `json:"slot"`
+	Parent uint64 `json:"parent"`
+	Root   uint64 `json:"root"`
+}
+
+// BlockUpdate blockSubscribe 推送的完整區塊
+type BlockUpdate struct {
+	Slot  uint64
+	Block *models.BlockResponse
+}
+
+// WebSocketStats WebSocket 連接狀態快照
+type WebSocketStats struct {
+	Connected      bool   `json:"connected"`
+	Healthy        bool   `json:"healthy"`
+	Reconnects     uint64 `json:"reconnects"`
+	LastSlot       uint64 `json:"last_slot"`
+	LastMessageAgo string `json:"last_message_ago"`
+}
+
+type WebSocketClient struct {
+	config      *config.WebSocketConfig
+	slotChan    chan SlotUpdate
+	blockChan   chan BlockUpdate
+	conn        *websocket.Conn
+	connected   bool
+	reconnects  uint64
+	lastSlot    uint64
+	lastMessage time.Time
+	mutex       sync.RWMutex
+	stopChan    chan struct{}
+	stopOnce    sync.Once
+	wg          sync.WaitGroup
+}
+
+type wsNotification struct {
+	Method string `json:"method"`
+	Params struct {
+		Result json.RawMessage `json:"result"`
+	} `json:"params"`
+	Id    *int `json:"id"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+const (
+	slotSubscribeId  = 1
+	blockSubscribeId = 2
+)
+
+func NewWebSocketClient(cfg *config.WebSocketConfig) *WebSocketClient {
+	return &WebSocketClient{
+		config:    cfg,
+		slotChan:  make(chan SlotUpdate, 100),
+		blockChan: make(chan BlockUpdate, 100),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Slots 返回 slot 更新通道
+func (c *WebSocketClient) Slots() <-chan SlotUpdate {
+	return c.slotChan
+}
+
+// Blocks 返回區塊推送通道，未啟用 blockSubscribe 時不會有數據
+func (c *WebSocketClient) Blocks() <-chan BlockUpdate {
+	return c.blockChan
+}
+
+// Start 在背景維持連接，斷線後以指數退避重連並重新訂閱
+func (c *WebSocketClient) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		delay := c.config.ReconnectDelay
+
+		for {
+			startTime := time.Now()
+			if err := c.run(); err != nil {
+				log.Printf("WebSocket connection error: %v", err)
+			}
+
+			select {
+			case <-c.stopChan:
+				return
+			default:
+			}
+
+			// 連接維持足夠久則重置退避時間
+			if time.Since(startTime) > c.config.MaxReconnectDelay {
+				delay = c.config.ReconnectDelay
+			}
+
+			c.mutex.Lock()
+			c.reconnects++
+			c.mutex.Unlock()
+
+			log.Printf("WebSocket reconnecting in %v", delay)
+			select {
+			case <-time.After(delay):
+			case <-c.stopChan:
+				return
+			}
+
+			delay *= 2
+			if delay > c.config.MaxReconnectDelay {
+				delay = c.config.MaxReconnectDelay
+			}
+		}
+	}()
+}
+
+func (c *WebSocketClient) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+		c.mutex.Lock()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		c.mutex.Unlock()
+	})
+	c.wg.Wait()
+}
+
+// Healthy 連接中且最近收到過消息
+func (c *WebSocketClient) Healthy() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.connected && time.Since(c.lastMessage) < c.config.HealthTimeout
+}
+
+func (c *WebSocketClient) Stats() WebSocketStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	lastMessageAgo := "never"
+	if !c.lastMessage.IsZero() {
+		lastMessageAgo = time.Since(c.lastMessage).Truncate(time.Millisecond).String()
+	}
+	return WebSocketStats{
+		Connected:      c.connected,
+		Healthy:        c.connected && time.Since(c.lastMessage) < c.config.HealthTimeout,
+		Reconnects:     c.reconnects,
+		LastSlot:       c.lastSlot,
+		LastMessageAgo: lastMessageAgo,
+	}
+}
+
+// run 建立連接、訂閱並讀取消息，直到連接斷開
+func (c *WebSocketClient) run() error {
+	conn, _, err := websocket.DefaultDialer.Dial(c.config.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to dial: %v", err)
+	}
+
+	c.mutex.Lock()
+	select {
+	case <-c.stopChan:
+		c.mutex.Unlock()
+		conn.Close()
+		return nil
+	default:
+	}
+	c.conn = conn
+	c.connected = true
+	c.lastMessage = time.Now()
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		c.connected = false
+		c.conn = nil
+		c.mutex.Unlock()
+		conn.Close()
+	}()
+
+	if err := c.subscribe(conn); err != nil {
+		return err
+	}
+
+	// 定期發送 ping，並以 pong 延長讀取期限
+	readTimeout := c.config.PingInterval + c.config.HealthTimeout
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(c.config.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				deadline := time.Now().Add(c.config.HealthTimeout)
+				if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("failed to read message: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+		c.mutex.Lock()
+		c.lastMessage = time.Now()
+		c.mutex.Unlock()
+
+		if err := c.handleMessage(data); err != nil {
+			log.Printf("WebSocket message error: %v", err)
+		}
+	}
+}
+
+func (c *WebSocketClient) subscribe(conn *websocket.Conn) error {
+	requests := []rpcRequest{{
+		Jsonrpc: "2.0",
+		Id:      slotSubscribeId,
+		Method:  "slotSubscribe",
+		Params:  []interface{}{},
+	}}
+
+	if c.config.SubscribeBlocks {
+		requests = append(requests, rpcRequest{
+			Jsonrpc: "2.0",
+			Id:      blockSubscribeId,
+			Method:  "blockSubscribe",
+			Params: []interface{}{
+				"all",
+				map[string]interface{}{
+					"commitment":                     c.config.Commitment,
+					"encoding":                       "json",
+					"transactionDetails":             "full",
+					"showRewards":                    false,
+					"maxSupportedTransactionVersion": 0,
+				},
+			},
+		})
+	}
+
+	for _, req := range requests {
+		if err := conn.WriteJSON(req); err != nil {
+			return fmt.Errorf("failed to send %s: %v", req.Method, err)
+		}
+	}
+	return nil
+}
+
+func (c *WebSocketClient) handleMessage(data []byte) error {
+	var msg wsNotification
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("failed to parse message: %v", err)
+	}
+
+	switch msg.Method {
+	case "slotNotification":
+		var update SlotUpdate
+		if err := json.Unmarshal(msg.Params.Result, &update); err != nil {
+			return fmt.Errorf("failed to parse slot notification: %v", err)
+		}
+
+		c.mutex.Lock()
+		c.lastSlot = update.Slot
+		c.mutex.Unlock()
+
+		// 通道滿時丟棄，監控器按範圍追趕不會因此漏掉 slot
+		select {
+		case c.slotChan <- update:
+		default:
+		}
+
+	case "blockNotification":
+		var result struct {
+			Value struct {
+				Slot  uint64          `json:"slot"`
+				Block json.RawMessage `json:"block"`
+				Err   interface{}     `json:"err"`
+			} `json:"value"`
+		}
+		if err := json.Unmarshal(msg.Params.Result, &result); err != nil {
+			return fmt.Errorf("failed to parse block notification: %v", err)
+		}
+		if result.Value.Err != nil || isNull(result.Value.Block) {
+			return nil
+		}
+
+		block := &models.BlockResponse{
+			Jsonrpc:    "2.0",
+			Id:         1,
+			Slot:       result.Value.Slot,
+			Commitment: c.config.Commitment,
+		}
+		if err := json.Unmarshal(result.Value.Block, &block.Result); err != nil {
+			return fmt.Errorf("failed to parse block %d: %v", result.Value.Slot, err)
+		}
+
+		select {
+		case c.blockChan <- BlockUpdate{Slot: result.Value.Slot, Block: block}:
+		default:
+		}
+
+	default:
+		if msg.Error != nil {
+			return fmt.Errorf("subscription %d failed (code %d): %s", derefId(msg.Id), msg.Error.Code, msg.Error.Message)
+		}
+	}
+
+	return nil
+}
+
+func derefId(id *int) int {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
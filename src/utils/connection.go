@@ -19,7 +19,7 @@ func NewTCPConnectionChecker(timeout time.Duration) *TCPConnectionChecker {
 }
 
 func (c *TCPConnectionChecker) TestConnection(host string, port string) error {
-	address := fmt.Sprintf("%s:%s", host, port)
+	address := net.JoinHostPort(host, port)
 	conn, err := net.DialTimeout("tcp", address, c.timeout)
 	if err != nil {
 		return fmt.Errorf("TCP connection to %s failed: %v", address, err)
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"sync"
+)
+
+// SlotWindowStats 滑動窗口狀態快照
+type SlotWindowStats struct {
+	Name     string `json:"name"`
+	Base     uint64 `json:"base"`
+	Size     uint64 `json:"size"`
+	Count    int    `json:"count"`
+	Evicted  uint64 `json:"evicted"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// SlotWindow 以環形位圖記錄 [base, base+size) 內的 slot，內存固定為 size/8 字節。
+// 淘汰策略：寫入超出上界的 slot 時窗口向前滑動，或由 Advance 顯式推進下界；
+// 低於下界的 slot 被視為已離開窗口，Has 返回 false，Add 直接拒絕
+type SlotWindow struct {
+	name     string
+	bits     []uint64
+	size     uint64
+	base     uint64
+	count    int
+	evicted  uint64
+	rejected uint64
+	mutex    sync.Mutex
+}
+
+func NewSlotWindow(name string, size uint64) *SlotWindow {
+	// 按 64 位對齊
+	if size < 64 {
+		size = 64
+	}
+	size = (size + 63) / 64 * 64
+
+	return &SlotWindow{
+		name: name,
+		bits: make([]uint64, size/64),
+		size: size,
+	}
+}
+
+// Add 記錄 slot，低於窗口下界時返回 false
+func (w *SlotWindow) Add(slot uint64) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if slot < w.base {
+		w.rejected++
+		return false
+	}
+	if slot >= w.base+w.size {
+		w.advance(slot - w.size + 1)
+	}
+
+	index, mask := w.position(slot)
+	if w.bits[index]&mask == 0 {
+		w.bits[index] |= mask
+		w.count++
+	}
+	return true
+}
+
+func (w *SlotWindow) Has(slot uint64) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if slot < w.base || slot >= w.base+w.size {
+		return false
+	}
+	index, mask := w.position(slot)
+	return w.bits[index]&mask != 0
+}
+
+func (w *SlotWindow) Remove(slot uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if slot < w.base || slot >= w.base+w.size {
+		return
+	}
+	index, mask := w.position(slot)
+	if w.bits[index]&mask != 0 {
+		w.bits[index] &^= mask
+		w.count--
+	}
+}
+
+// Advance 將窗口下界推進到 base，淘汰更舊的 slot
+func (w *SlotWindow) Advance(base uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.advance(base)
+}
+
+func (w *SlotWindow) Len() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.count
+}
+
+func (w *SlotWindow) Stats() SlotWindowStats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return SlotWindowStats{
+		Name:     w.name,
+		Base:     w.base,
+		Size:     w.size,
+		Count:    w.count,
+		Evicted:  w.evicted,
+		Rejected: w.rejected,
+	}
+}
+
+// advance 調用前需持有 mutex
+func (w *SlotWindow) advance(base uint64) {
+	if base <= w.base {
+		return
+	}
+
+	// 跨度超過整個窗口時直接清空
+	if base-w.base >= w.size {
+		for i := range w.bits {
+			w.bits[i] = 0
+		}
+		w.evicted += uint64(w.count)
+		w.count = 0
+		w.base = base
+		return
+	}
+
+	for slot := w.base; slot < base; slot++ {
+		index, mask := w.position(slot)
+		if w.bits[index]&mask != 0 {
+			w.bits[index] &^= mask
+			w.count--
+			w.evicted++
+		}
+	}
+	w.base = base
+}
+
+func (w *SlotWindow) position(slot uint64) (int, uint64) {
+	offset := slot % w.size
+	return int(offset / 64), 1 << (offset % 64)
+}
@@ -0,0 +1,118 @@
+package utils
+
+import "testing"
+
+func TestSlotWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		add      []uint64
+		advance  uint64
+		has      []uint64
+		missing  []uint64
+		base     uint64
+		count    int
+		evicted  uint64
+		rejected uint64
+	}{
+		{
+			name:    "within window",
+			add:     []uint64{0, 1, 63},
+			has:     []uint64{0, 1, 63},
+			missing: []uint64{2, 64},
+			count:   3,
+		},
+		{
+			name:    "slides forward past upper bound",
+			add:     []uint64{0, 10, 64, 65},
+			has:     []uint64{10, 64, 65},
+			missing: []uint64{0, 1},
+			base:    2,
+			count:   3,
+			evicted: 1,
+		},
+		{
+			// 65 和 1 映射到同一個位，滑動後舊位必須先清除
+			name:    "ring wraparound reuses cleared bits",
+			add:     []uint64{1, 65, 66},
+			has:     []uint64{65, 66},
+			missing: []uint64{1, 2, 129},
+			base:    3,
+			count:   2,
+			evicted: 1,
+		},
+		{
+			name:    "jump beyond whole window clears everything",
+			add:     []uint64{5, 6, 1000},
+			has:     []uint64{1000},
+			missing: []uint64{5, 6, 936},
+			base:    937,
+			count:   1,
+			evicted: 2,
+		},
+		{
+			name:     "rejects slots below base",
+			add:      []uint64{100, 10},
+			has:      []uint64{100},
+			missing:  []uint64{10},
+			base:     37,
+			count:    1,
+			rejected: 1,
+		},
+		{
+			name:    "explicit advance evicts older slots",
+			add:     []uint64{1, 2, 3},
+			advance: 3,
+			has:     []uint64{3},
+			missing: []uint64{1, 2},
+			base:    3,
+			count:   1,
+			evicted: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewSlotWindow(tt.name, 64)
+			for _, slot := range tt.add {
+				w.Add(slot)
+			}
+			if tt.advance > 0 {
+				w.Advance(tt.advance)
+			}
+
+			for _, slot := range tt.has {
+				if !w.Has(slot) {
+					t.Errorf("Has(%d) = false, want true", slot)
+				}
+			}
+			for _, slot := range tt.missing {
+				if w.Has(slot) {
+					t.Errorf("Has(%d) = true, want false", slot)
+				}
+			}
+
+			stats := w.Stats()
+			if stats.Base != tt.base || stats.Count != tt.count || stats.Evicted != tt.evicted || stats.Rejected != tt.rejected {
+				t.Errorf("stats = %+v, want base=%d count=%d evicted=%d rejected=%d",
+					stats, tt.base, tt.count, tt.evicted, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestSlotWindowRoundsSize(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want uint64
+	}{
+		{0, 64},
+		{64, 64},
+		{65, 128},
+		{1000, 1024},
+	}
+	for _, tt := range tests {
+		if got := NewSlotWindow("test", tt.size).Stats().Size; got != tt.want {
+			t.Errorf("NewSlotWindow(%d) size = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
@@ -9,43 +9,32 @@ import (
 	"strings"
 	"time"
 
+	"solana/src/models"
+	"solana/src/schema"
+	"solana/src/services"
+
 	"github.com/IBM/sarama"
 )
 
-type BlockMessage struct {
-	BlockHeight       uint64            `json:"blockHeight"`
-	BlockTime         *uint64           `json:"blockTime"`
-	Blockhash         string            `json:"blockhash"`
-	ParentSlot        uint64            `json:"parentSlot"`
-	PreviousBlockhash string            `json:"previousBlockhash"`
-	Transactions      []TransactionInfo `json:"transactions"`
-	Timestamp         int64             `json:"timestamp"`
-}
-
-type TransactionInfo struct {
-	Signature      string          `json:"signature"`
-	Status         string          `json:"status"`
-	Fee            uint64          `json:"fee"`
-	AccountKeys    []string        `json:"accountKeys"`
-	Instructions   []Instruction   `json:"instructions"`
-	BalanceChanges []BalanceChange `json:"balanceChanges"`
-}
-
-type Instruction struct {
-	ProgramId string   `json:"programId"`
-	Data      string   `json:"data"`
-	Accounts  []string `json:"accounts"`
-}
+// decodeBlockMessage 按消息格式解碼區塊：JSON、帶 schema ID 的 protobuf 或不帶 schema ID 的 protobuf
+func decodeBlockMessage(value []byte) (*models.BlockMessage, error) {
+	if len(value) > 0 && value[0] == '{' {
+		var msg models.BlockMessage
+		if err := json.Unmarshal(value, &msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
 
-type BalanceChange struct {
-	Account     string `json:"account"`
-	PreBalance  uint64 `json:"preBalance"`
-	PostBalance uint64 `json:"postBalance"`
-	Change      int64  `json:"change"`
+	if _, _, payload, err := schema.Unframe(value); err == nil {
+		value = payload
+	}
+	return schema.UnmarshalBlock(value)
 }
 
-func prettyPrintBlockMessage(msg BlockMessage) {
+func prettyPrintBlockMessage(msg *models.BlockMessage) {
 	fmt.Println("\n=== Block Information ===")
+	fmt.Printf("Slot: %d (%s)\n", msg.Slot, msg.Commitment)
 	fmt.Printf("Block Height: %d\n", msg.BlockHeight)
 	if msg.BlockTime != nil {
 		fmt.Printf("Block Time: %v\n", time.Unix(int64(*msg.BlockTime), 0))
@@ -62,6 +51,7 @@ func prettyPrintBlockMessage(msg BlockMessage) {
 		fmt.Printf("  Signature: %s\n", tx.Signature)
 		fmt.Printf("  Status: %s\n", tx.Status)
 		fmt.Printf("  Fee: %d lamports (%.9f SOL)\n", tx.Fee, float64(tx.Fee)/1e9)
+		fmt.Printf("  Compute Units: %d\n", tx.ComputeUnits)
 
 		if len(tx.Instructions) > 0 {
 			fmt.Printf("\n  Instructions:\n")
@@ -85,6 +75,22 @@ func prettyPrintBlockMessage(msg BlockMessage) {
 			}
 		}
 
+		if len(tx.TokenBalances) > 0 {
+			fmt.Printf("\n  Token Balances:\n")
+			for _, balance := range tx.TokenBalances {
+				fmt.Printf("    Mint: %s\n", balance.Mint)
+				fmt.Printf("      Owner : %s\n", balance.Owner)
+				fmt.Printf("      Amount: %s\n", balance.UITokenAmount.UIAmountString)
+			}
+		}
+
+		if len(tx.LogMessages) > 0 {
+			fmt.Printf("\n  Log Messages (%d):\n", len(tx.LogMessages))
+			for _, line := range tx.LogMessages {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+
 		fmt.Println("  " + strings.Repeat("-", 50))
 	}
 	fmt.Println("\n=== End of Block ===")
@@ -196,6 +202,9 @@ func main() {
 	fmt.Println("Press Ctrl+C to exit")
 	fmt.Println("----------------------------------------")
 
+	// 超過大小限制的區塊會分塊發送，收齊後再解析
+	assembler := services.NewChunkAssembler(16)
+
 	consumed := 0
 ConsumerLoop:
 	for {
@@ -205,8 +214,18 @@ ConsumerLoop:
 			fmt.Printf("\nReceived message at offset %d:\n", msg.Offset)
 
 			// 解析消息
-			var blockMsg BlockMessage
-			if err := json.Unmarshal(msg.Value, &blockMsg); err != nil {
+			value, err := assembler.Add(msg.Headers, msg.Value)
+			if err != nil {
+				log.Printf("Error reassembling message: %v\n", err)
+				continue
+			}
+			if value == nil {
+				fmt.Printf("Chunk received, waiting for the rest (%d incomplete)\n", assembler.Pending())
+				continue
+			}
+
+			blockMsg, err := decodeBlockMessage(value)
+			if err != nil {
 				log.Printf("Error parsing message: %v\n", err)
 				continue
 			}